@@ -0,0 +1,136 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+// loadCertificate loads the certificate of the TLS server or
+// generates one depending on the configured TLS mode.
+func loadCertificate(cfg *config.Web) (tls.Certificate, error) {
+	now := time.Now()
+	validity := cfg.TLS.Validity
+	switch cfg.TLS.Mode {
+	case config.TLSModeSelfSigned:
+		return generateCertificate(cfg.Host, now.Add(-time.Hour), now.Add(validity))
+	case config.TLSModeExpired:
+		return generateCertificate(cfg.Host, now.Add(-2*validity), now.Add(-validity))
+	default:
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("cannot load certificate: %w", err)
+		}
+		return cert, nil
+	}
+}
+
+// generateCertificate generates a self-signed certificate for the
+// given host and the loopback addresses with the given validity.
+func generateCertificate(host string, notBefore, notAfter time.Time) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("generating key failed: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("generating serial number failed: %w", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: host},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = append(template.IPAddresses, ip)
+	} else if host != "localhost" {
+		template.DNSNames = append(template.DNSNames, host)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("creating certificate failed: %w", err)
+	}
+	slog.Info("Generated self-signed certificate",
+		"host", host,
+		"not_before", notBefore.Format(time.RFC3339),
+		"not_after", notAfter.Format(time.RFC3339))
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// stapler staples an OCSP response read from a file to a certificate.
+type stapler struct {
+	base tls.Certificate
+	file string
+	cert atomic.Pointer[tls.Certificate]
+}
+
+// newStapler creates a stapler with an initially loaded OCSP response.
+func newStapler(base tls.Certificate, file string) (*stapler, error) {
+	s := &stapler{base: base, file: file}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reads the OCSP response and staples it to a copy of the certificate.
+func (s *stapler) load() error {
+	staple, err := os.ReadFile(s.file)
+	if err != nil {
+		return fmt.Errorf("cannot load OCSP staple: %w", err)
+	}
+	cert := s.base
+	cert.OCSPStaple = staple
+	s.cert.Store(&cert)
+	return nil
+}
+
+// refresh periodically reloads the OCSP response.
+// Meant to be run in a Go routine.
+func (s *stapler) refresh(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.load(); err != nil {
+				slog.Error("refreshing OCSP staple failed", "error", err)
+			}
+		}
+	}
+}
+
+// getCertificate implements [tls.Config.GetCertificate].
+func (s *stapler) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return s.cert.Load(), nil
+}
@@ -0,0 +1,170 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+// serveTLS serves a fixed answer over TLS with the given config
+// until the end of the test and returns the address.
+func serveTLS(t *testing.T, tlsConfig *tls.Config) string {
+	t.Helper()
+	l, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		io.WriteString(rw, "ok")
+	})}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+	return l.Addr().String()
+}
+
+func TestExpiredCertificate(t *testing.T) {
+	cert, err := loadCertificate(&config.Web{
+		Host: "localhost",
+		TLS:  config.TLS{Mode: config.TLSModeExpired, Validity: 24 * time.Hour},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if now := time.Now(); !leaf.NotAfter.Before(now) || !leaf.NotBefore.Before(leaf.NotAfter) {
+		t.Errorf("certificate valid from %s to %s is not expired", leaf.NotBefore, leaf.NotAfter)
+	}
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+
+	// Clients checking the certificate reject it.
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+	_, err = tls.Dial("tcp", addr, &tls.Config{RootCAs: roots, ServerName: "localhost"})
+	var invalid x509.CertificateInvalidError
+	if !errors.As(err, &invalid) || invalid.Reason != x509.Expired {
+		t.Errorf("got error %v, want an expired certificate", err)
+	}
+
+	// The others are still served.
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+	res, err := client.Get("https://" + addr + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if body, _ := io.ReadAll(res.Body); res.StatusCode != http.StatusOK || string(body) != "ok" {
+		t.Errorf("got status %d, body %q", res.StatusCode, body)
+	}
+}
+
+func TestALPN(t *testing.T) {
+	now := time.Now()
+	cert, err := generateCertificate("localhost", now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsConfig, err := (&config.TLS{ALPN: []string{"h2", "http/1.1", "x-test"}}).Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsConfig.Certificates = []tls.Certificate{cert}
+	addr := serveTLS(t, tlsConfig)
+
+	for _, check := range []struct {
+		offered []string
+		want    string
+	}{
+		{[]string{"x-test"}, "x-test"},
+		{[]string{"http/1.1", "h2"}, "h2"},
+		{nil, ""},
+	} {
+		conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true, NextProtos: check.offered})
+		if err != nil {
+			t.Errorf("client offering %q: %v", check.offered, err)
+			continue
+		}
+		if got := conn.ConnectionState().NegotiatedProtocol; got != check.want {
+			t.Errorf("client offering %q: negotiated %q, want %q", check.offered, got, check.want)
+		}
+		conn.Close()
+	}
+	// Clients offering none of the protocols are rejected.
+	if conn, err := tls.Dial("tcp", addr, &tls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         []string{"x-unknown"},
+	}); err == nil {
+		conn.Close()
+		t.Error("client without a common protocol is accepted")
+	}
+}
+
+func TestOCSPStaple(t *testing.T) {
+	now := time.Now()
+	cert, err := generateCertificate("localhost", now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "ocsp.der")
+	if err := os.WriteFile(file, []byte("first response"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	st, err := newStapler(cert, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go st.refresh(t.Context(), 10*time.Millisecond)
+	addr := serveTLS(t, &tls.Config{GetCertificate: st.getCertificate})
+
+	staple := func() []byte {
+		t.Helper()
+		conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().OCSPResponse
+	}
+	if got := staple(); string(got) != "first response" {
+		t.Errorf("got staple %q", got)
+	}
+
+	// The refreshed response is stapled to the following handshakes.
+	if err := os.WriteFile(file, []byte("second response"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for got := staple(); !bytes.Equal(got, []byte("second response")); got = staple() {
+		if time.Now().After(deadline) {
+			t.Fatalf("got staple %q after refresh", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := newStapler(cert, filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("missing staple is accepted")
+	}
+}
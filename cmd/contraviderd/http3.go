@@ -0,0 +1,63 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+//go:build http3
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// http3Server is an HTTP/3 server with the UDP connection it serves on.
+type http3Server struct {
+	srv  *http3.Server
+	conn net.PacketConn
+}
+
+func (s *http3Server) serve() error { return s.srv.Serve(s.conn) }
+
+func (s *http3Server) shutdown(ctx context.Context) error {
+	defer s.conn.Close()
+	return s.srv.Shutdown(ctx)
+}
+
+// listenHTTP3 listens on the UDP port of the given address to serve
+// the handler over HTTP/3. The returned handler announces the
+// HTTP/3 server with an Alt-Svc header.
+func listenHTTP3(
+	addr string,
+	tlsConfig *tls.Config,
+	handler http.Handler,
+) (runner, http.Handler, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot listen to udp: %w", err)
+	}
+	srv := &http3.Server{
+		Addr:      addr,
+		Handler:   handler,
+		TLSConfig: http3.ConfigureTLSConfig(tlsConfig),
+	}
+	altSvc := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if err := srv.SetQUICHeaders(rw.Header()); err != nil {
+			slog.Warn("cannot set Alt-Svc header", "error", err)
+		}
+		handler.ServeHTTP(rw, req)
+	})
+	return &http3Server{srv: srv, conn: conn}, altSvc, nil
+}
@@ -0,0 +1,24 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+//go:build !http3
+
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+)
+
+// listenHTTP3 fails as the HTTP/3 support is not built in.
+func listenHTTP3(string, *tls.Config, http.Handler) (runner, http.Handler, error) {
+	return nil, nil, errors.New("HTTP/3 is not supported, build with -tags http3")
+}
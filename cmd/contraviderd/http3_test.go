@@ -0,0 +1,77 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+//go:build http3
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+func TestHTTP3(t *testing.T) {
+	now := time.Now()
+	cert, err := generateCertificate("localhost", now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h3, handler, err := listenHTTP3("127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}},
+		http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+			io.WriteString(rw, "advisory")
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go h3.serve()
+	t.Cleanup(func() { h3.shutdown(t.Context()) })
+
+	addr := h3.(*http3Server).conn.LocalAddr().String()
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+	transport := &http3.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}
+	defer transport.Close()
+	res, err := (&http.Client{Transport: transport}).Get("https://" + addr + "/white/advisory.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ProtoMajor != 3 || string(body) != "advisory" {
+		t.Errorf("got %s response %q", res.Proto, body)
+
+	}
+
+	// The other listeners announce the running HTTP/3 server.
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	_, port, _ := net.SplitHostPort(addr)
+	if got, want := rec.Header().Get("Alt-Svc"), fmt.Sprintf(`h3=":%s"`, port); !strings.HasPrefix(got, want) {
+		t.Errorf("got Alt-Svc %q, want %s", got, want)
+	}
+}
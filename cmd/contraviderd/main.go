@@ -14,6 +14,7 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
@@ -24,12 +25,14 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/csaf-testsuite/contravider/pkg/config"
 	"github.com/csaf-testsuite/contravider/pkg/providers"
 	"github.com/csaf-testsuite/contravider/pkg/version"
 	"github.com/csaf-testsuite/contravider/pkg/web"
+	"golang.org/x/net/netutil"
 )
 
 func check(err error) {
@@ -39,103 +42,331 @@ func check(err error) {
 	}
 }
 
-func run(cfg *config.Config) error {
+// validate reports all problems found in the configuration to stderr
+// and returns the exit code.
+func validate(cfg *config.Config, loadErr error) int {
+	if loadErr != nil {
+		fmt.Fprintf(os.Stderr, "loading configuration failed:\n%v\n", loadErr)
+		return 1
+	}
+	if err := errors.Join(cfg.Validate(), providers.Check(cfg)); err != nil {
+		fmt.Fprintf(os.Stderr, "configuration is invalid:\n%v\n", err)
+		return 1
+	}
+	fmt.Println("configuration is valid")
+	return 0
+}
+
+// limitConnections limits the number of simultaneous connections
+// accepted by the listener. Zero means no limit.
+func limitConnections(l net.Listener, maxConns int) net.Listener {
+	if maxConns > 0 {
+		return netutil.LimitListener(l, maxConns)
+	}
+	return l
+}
+
+// runner is a server which serves until it is shut down.
+type runner interface {
+	serve() error
+	shutdown(ctx context.Context) error
+}
+
+// server is a web server with the listener it serves on.
+type server struct {
+	srv      *http.Server
+	listener net.Listener
+}
+
+func (s *server) serve() error                       { return s.srv.Serve(s.listener) }
+func (s *server) shutdown(ctx context.Context) error { return s.srv.Shutdown(ctx) }
+
+// listenUnix listens on a unix domain socket with the given permissions.
+func listenUnix(path string, perm os.FileMode) (net.Listener, error) {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen on domain socket: %w", err)
+	}
+	if err := os.Chmod(path, perm); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("cannot change rights on socket: %w", err)
+	}
+	return l, nil
+}
+
+// listenLoopback listens on a TCP address which has to be a loopback one.
+func listenLoopback(addr string) (net.Listener, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen: %w", err)
+	}
+	if tcp, ok := l.Addr().(*net.TCPAddr); !ok || !tcp.IP.IsLoopback() {
+		l.Close()
+		return nil, fmt.Errorf("admin address %q is not a loopback address", addr)
+	}
+	return l, nil
+}
+
+// reloadConfig reloads the profiles and the credentials from the
+// configuration. An invalid configuration is returned as error
+// and the old one stays in use.
+func reloadConfig(
+	ctx context.Context,
+	cfgFile string,
+	sys *providers.System,
+	ctrl *web.Controller,
+) error {
+	cfg, err := config.Load(cfgFile)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err == nil {
+		err = sys.UpdateProfiles(ctx, cfg.Providers.Profiles)
+	}
+	if err != nil {
+		slog.Error("reloading configuration failed", "error", err)
+		return err
+	}
+	sys.UpdateGitCredentials(&cfg.Providers)
+	ctrl.UpdateAdminCredentials(cfg.Web.AdminUser, cfg.Web.AdminPassword)
+	slog.Info("reloaded configuration", "profiles", len(cfg.Providers.Profiles))
+	return nil
+}
+
+// reloadOnHUP reloads the configuration whenever a SIGHUP is received.
+func reloadOnHUP(
+	ctx context.Context,
+	cfgFile string,
+	sys *providers.System,
+	ctrl *web.Controller,
+) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			reloadConfig(ctx, cfgFile, sys, ctrl)
+		}
+	}
+}
+
+// dumpState writes a snapshot of the state of the system into
+// a file in the temporary directory whenever a SIGUSR1 is received.
+func dumpState(ctx context.Context, sys *providers.System) {
+	usr1 := make(chan os.Signal, 1)
+	signal.Notify(usr1, syscall.SIGUSR1)
+	defer signal.Stop(usr1)
+	fname := filepath.Join(os.TempDir(), fmt.Sprintf("contravider-state-%d.txt", os.Getpid()))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-usr1:
+			f, err := os.Create(fname)
+			if err == nil {
+				err = errors.Join(sys.Dump(f), f.Close())
+			}
+			if err != nil {
+				slog.Error("dumping state failed", "error", err)
+				continue
+			}
+			slog.Info("dumped state", "file", fname)
+		}
+	}
+}
+
+func run(cfgFile string, cfg *config.Config) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGKILL, syscall.SIGTERM)
 	defer stop()
 
-	sys, err := providers.NewSystem(cfg)
+	slog.Info("Using result directory", "dir", cfg.Providers.Result)
+
+	sys, err := providers.NewSystem(ctx, cfg)
 	if err != nil {
 		return fmt.Errorf("booting system failed: %w", err)
 	}
-	go sys.Run(ctx)
+	// Wait for the system to store its state and to remove
+	// the retired exports before exiting.
+	running := make(chan struct{})
+	go func() {
+		defer close(running)
+		sys.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-running
+	}()
+	go dumpState(ctx, sys)
 
 	ctrl, err := web.NewController(cfg, sys)
 	if err != nil {
 		return err
 	}
+	ctrl.SetReload(func(ctx context.Context) error {
+		return reloadConfig(ctx, cfgFile, sys, ctrl)
+	})
+	go reloadOnHUP(ctx, cfgFile, sys, ctrl)
 
 	addr := cfg.Web.Addr()
 	slog.Info("Starting web server", "address", addr)
 	srv := &http.Server{
 		Addr:    addr,
 		Handler: ctrl.Bind(),
+		// Extended per response by the budget of the handler.
+		WriteTimeout: cfg.Web.ResponseBudget,
 	}
 
 	// Check if we should serve on an unix domain socket.
-	var listener net.Listener
+	var (
+		listener net.Listener
+		h3Server runner
+	)
 	if host := cfg.Web.Host; filepath.IsAbs(host) {
 		host = strings.ReplaceAll(host, "{port}", strconv.Itoa(cfg.Web.Port))
-		l, err := net.Listen("unix", host)
+		// Enable writing to socket
+		l, err := listenUnix(host, 0777)
 		if err != nil {
-			return fmt.Errorf("cannot listen on domain socket: %w", err)
+			return err
 		}
 		defer func() {
 			l.Close()
 			// Cleanup socket file
 			os.Remove(host)
 		}()
-		// Enable writing to socket
-		if err := os.Chmod(host, 0777); err != nil {
-			return fmt.Errorf("cannot change rights on socket: %w", err)
-		}
 		listener = l
-	} else if c, k := cfg.Web.CertFile, cfg.Web.KeyFile; c != " " && k != "" {
+	} else if cfg.Web.TLSEnabled() {
 		// TLS server?
-		cert, err := tls.LoadX509KeyPair(c, k)
+		cert, err := loadCertificate(&cfg.Web)
 		if err != nil {
-			return fmt.Errorf("cannot load certificate: %w", err)
+			return err
+		}
+		tlsConfig, err := cfg.Web.TLS.Config()
+		if err != nil {
+			return fmt.Errorf("cannot configure tls: %w", err)
+		}
+		if staple := cfg.Web.TLS.OCSPStaple; staple != "" {
+			st, err := newStapler(cert, staple)
+			if err != nil {
+				return err
+			}
+			if refresh := cfg.Web.TLS.OCSPRefresh; refresh > 0 {
+				go st.refresh(ctx, refresh)
+			}
+			tlsConfig.GetCertificate = st.getCertificate
+		} else {
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		// Serve the same over HTTP/3 and announce it.
+		if cfg.Web.HTTP3 {
+			h3, handler, err := listenHTTP3(addr, tlsConfig, srv.Handler)
+			if err != nil {
+				return err
+			}
+			slog.Info("Starting HTTP/3 server", "address", addr)
+			srv.Handler = handler
+			h3Server = h3
 		}
-		tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
 		l, err := tls.Listen("tcp", cfg.Web.Addr(), tlsConfig)
 		if err != nil {
 			return fmt.Errorf("cannot listen to tls: %w", err)
 		}
 		defer l.Close()
 		listener = l
+	} else {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("cannot listen: %w", err)
+		}
+		defer l.Close()
+		listener = l
 	}
 
-	srvErrors := make(chan error)
+	// Limit the number of simultaneous connections.
+	listener = limitConnections(listener, cfg.Web.MaxConnections)
 
-	done := make(chan struct{})
-	go func() {
-		defer close(done)
-		serve := srv.ListenAndServe
-		if listener != nil {
-			serve = func() error { return srv.Serve(listener) }
+	servers := []runner{&server{srv, listener}}
+	if h3Server != nil {
+		servers = append(servers, h3Server)
+	}
+
+	// Serve the admin endpoints on an additional domain socket.
+	if socket := cfg.Web.AdminSocket; socket != "" {
+		l, err := listenUnix(socket, 0660)
+		if err != nil {
+			return err
 		}
-		if err := serve(); err != http.ErrServerClosed {
-			srvErrors <- err
+		defer func() {
+			l.Close()
+			os.Remove(socket)
+		}()
+		slog.Info("Starting admin server", "socket", socket)
+		servers = append(servers, &server{&http.Server{Handler: ctrl.BindAdmin()}, l})
+	}
+	// Or on an additional loopback address.
+	if addr := cfg.Web.AdminAddress; addr != "" {
+		l, err := listenLoopback(addr)
+		if err != nil {
+			return err
 		}
-	}()
+		defer l.Close()
+		slog.Info("Starting admin server", "address", addr)
+		servers = append(servers, &server{&http.Server{Handler: ctrl.BindAdmin()}, l})
+	}
+
+	srvErrors := make(chan error, len(servers))
+
+	var wg sync.WaitGroup
+	for _, s := range servers {
+		wg.Go(func() {
+			if err := s.serve(); err != http.ErrServerClosed {
+				srvErrors <- err
+			}
+		})
+	}
 
 	select {
 	case <-ctx.Done():
 		slog.Info("Shutting down")
-		srv.Shutdown(ctx)
 	case err = <-srvErrors:
 	}
-	<-done
+	for _, s := range servers {
+		s.shutdown(ctx)
+	}
+	wg.Wait()
 	return err
 }
 
 func main() {
 	var (
-		cfgFile     string
-		showVersion bool
+		cfgFile        string
+		showVersion    bool
+		validateConfig bool
 	)
 	flag.StringVar(&cfgFile, "config", config.DefaultConfigFile, "configuration file")
 	flag.StringVar(&cfgFile, "c", config.DefaultConfigFile, "configuration file (shorthand)")
 	flag.BoolVar(&showVersion, "version", false, "show version")
 	flag.BoolVar(&showVersion, "V", false, "show version (shorthand)")
+	flag.BoolVar(&validateConfig, "validate-config", false, "validate configuration and exit")
 	flag.Parse()
 	if showVersion {
 		fmt.Printf("%s version: %s\n", os.Args[0], version.SemVersion)
 		os.Exit(0)
 	}
 	cfg, err := config.Load(cfgFile)
+	if validateConfig {
+		os.Exit(validate(cfg, err))
+	}
 	check(err)
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "configuration is invalid:\n%v\n", err)
+		os.Exit(1)
+	}
 	check(cfg.Log.Config())
-	check(run(cfg))
+	cfg.PresetDefaults()
+	check(run(cfgFile, cfg))
 }
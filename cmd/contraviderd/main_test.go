@@ -0,0 +1,101 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// readStatus reads the status line of a response within the timeout.
+func readStatus(conn net.Conn, r *bufio.Reader, timeout time.Duration) (string, error) {
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	return r.ReadString('\n')
+}
+
+func TestLimitConnections(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})}
+	go srv.Serve(limitConnections(l, 1))
+	t.Cleanup(func() { srv.Close() })
+
+	dial := func() (net.Conn, *bufio.Reader) {
+		t.Helper()
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: test\r\n\r\n")); err != nil {
+			t.Fatal(err)
+		}
+		return conn, bufio.NewReader(conn)
+	}
+	first, r1 := dial()
+	if _, err := readStatus(first, r1, 5*time.Second); err != nil {
+		t.Fatalf("first connection is not served: %v", err)
+	}
+	// The first connection is kept alive and takes the only slot.
+	second, r2 := dial()
+	if _, err := readStatus(second, r2, 200*time.Millisecond); !os.IsTimeout(err) {
+		t.Fatalf("connection beyond the limit is served: %v", err)
+	}
+	first.Close()
+	if status, err := readStatus(second, r2, 5*time.Second); err != nil {
+		t.Fatalf("second connection is not served after closing the first: %v", err)
+	} else if status != "HTTP/1.1 200 OK\r\n" {
+		t.Errorf("got status line %q", status)
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "admin.sock")
+	l, err := listenUnix(socket, 0o660)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0o660 {
+		t.Errorf("got socket mode %v, want %v", info.Mode(), os.ModeSocket|0o660)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		io.WriteString(rw, "admin")
+	})}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return new(net.Dialer).DialContext(ctx, "unix", socket)
+		},
+	}}
+	res, err := client.Get("http://admin/admin/rebuild")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if body, _ := io.ReadAll(res.Body); string(body) != "admin" {
+		t.Errorf("got body %q over the socket", body)
+	}
+}
@@ -14,11 +14,14 @@ package config
 import (
 	"fmt"
 	"log/slog"
+	"maps"
 	"net"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
-
-	"github.com/BurntSushi/toml"
 )
 
 // DefaultConfigFile is the name of the default config file.
@@ -32,27 +35,175 @@ const (
 )
 
 const (
-	defaultWebHost     = "localhost"
-	defaultWebPort     = 8083
-	defaultWebProtocol = "https"
-	defaultWebRoot     = "web"
-	defaultWebCertFile = ""
-	defaultWebKeyFile  = ""
+	defaultWebHost            = "localhost"
+	defaultWebPort            = 8083
+	defaultWebProtocol        = "https"
+	defaultWebRoot            = "web"
+	defaultWebCertFile        = ""
+	defaultWebKeyFile         = ""
+	defaultWebProfileHeader   = ""
+	defaultWebIndexTitle      = "Contravider"
+	defaultWebIndexLang       = "en"
+	defaultWebAdminUser       = ""
+	defaultWebAdminPassword   = ""
+	defaultWebAdminSocket     = ""
+	defaultWebAdminAddress    = ""
+	defaultWebRobotsTxt       = ""
+	defaultWebMaxConnections  = 0
+	defaultWebHTTP3           = false
+	defaultWebTLSMode         = TLSModeFiles
+	defaultWebTLSValidity     = 24 * time.Hour
+	defaultWebLockoutAttempts = 0
+	defaultWebLockoutWindow   = time.Minute
+	defaultWebLockoutCooldown = 5 * time.Minute
+	defaultWebIdempotency     = 10 * time.Minute
+	defaultWebResponseBudget  = 0
+	defaultWebSessionsMaxAge  = time.Duration(0)
+	defaultWebCompress        = false
+)
+
+// defaultWebContentTypes are the content types of the signatures and
+// hashes, which the extension based detection of Go does not know.
+func defaultWebContentTypes() map[string]string {
+	return map[string]string{
+		".asc":    "application/pgp-signature",
+		".sha256": "text/plain; charset=utf-8",
+		".sha512": "text/plain; charset=utf-8",
+	}
+}
+
+const (
+	defaultProvidersGitURL          = "https://github.com/csaf-testsuite/distribution.git"
+	defaultProvidersBaseURL         = "{protocol}://{host}:{port}/{profile}"
+	defaultProvidersWorkDir         = "checkout"
+	defaultProvidersUpdate          = 5 * time.Minute
+	defaultProvidersGitCheck        = time.Minute
+	defaultProvidersGitCheckTimeout = 10 * time.Second
+	defaultProvidersPreviewTTL      = time.Hour
+	defaultProvidersPreviewGrace    = 5 * time.Minute
+	defaultProvidersDeleteGrace     = time.Duration(0)
+	defaultProvidersMaxTemplateMB   = 64
+	defaultProvidersCanonicalize    = false
+	defaultProvidersMaxCached       = 0
+	defaultProvidersMinFreeMB       = 0
+	defaultProvidersKeepExports     = 0
+	defaultProvidersPrewarm         = false
+	defaultProvidersGCDryRun        = false
+	defaultProvidersVerifyOnStart   = false
+	defaultProvidersDedup           = false
+	defaultProvidersMinRebuild      = 0
+	defaultProvidersGitAuthor       = "Contravider"
+	defaultProvidersGitEmail        = "contravider@localhost"
+	defaultProvidersMergeNoFF       = false
+	defaultProvidersShallowDepth    = 0
+	defaultProvidersSingleBranch    = false
+)
+
+const (
+	defaultSigningKey        = "privatekey.asc"
+	defaultPassphrase        = ""
+	defaultSigningHashFormat = HashFormatCoreutils
+	defaultSigningMode       = SigningModeDetached
+	defaultSigningVerify     = true
+	defaultSigningManifest   = false
+	defaultSigningMaxConc    = 0
+	defaultSigningInMemoryMB = 0
+	defaultSigningExpiryWarn = 30 * 24 * time.Hour
+	defaultSigningRefuseExp  = false
+	defaultProvidersResult   = "."
+)
+
+const (
+	defaultMetricsEnabled = false
+)
+
+const (
+	defaultDebugPprof = false
+)
+
+// Formats of the lines in the written hash files.
+const (
+	// HashFormatCoreutils separates hash and file name by two spaces
+	// like sha256sum does.
+	HashFormatCoreutils = "coreutils"
+	// HashFormatSingleSpace separates hash and file name by a single space.
+	HashFormatSingleSpace = "single-space"
+	// HashFormatBare only writes the hash.
+	HashFormatBare = "bare"
 )
 
+// Modes of the signatures.
 const (
-	defaultProvidersGitURL  = "https://github.com/csaf-testsuite/distribution.git"
-	defaultProvidersBaseURL = "{protocol}://{host}:{port}/{profile}"
-	defaultProvidersWorkDir = "checkout"
-	defaultProvidersUpdate  = 5 * time.Minute
+	// SigningModeDetached writes detached signatures.
+	SigningModeDetached = "detached"
+	// SigningModeClearsign writes clearsigned files.
+	SigningModeClearsign = "clearsign"
 )
 
+// Hash algorithms of the written hash files.
 const (
-	defaultSigningKey      = "privatekey.asc"
-	defaultPassphrase      = ""
-	defaultProvidersResult = "."
+	// HashSHA256 writes the .sha256 files.
+	HashSHA256 = "sha256"
+	// HashSHA512 writes the .sha512 files.
+	HashSHA512 = "sha512"
 )
 
+// checkHashes checks if all given hash algorithms are known.
+func checkHashes(hashes []string) error {
+	for _, hash := range hashes {
+		if hash != HashSHA256 && hash != HashSHA512 {
+			return fmt.Errorf("unknown hash algorithm %q", hash)
+		}
+	}
+	return nil
+}
+
+// baseURLPlaceholders removes the known placeholders of a base URL.
+var baseURLPlaceholders = strings.NewReplacer(
+	"{protocol}", "",
+	"{host}", "",
+	"{port}", "",
+	"{profile}", "",
+)
+
+// checkBaseURL checks if a base URL only uses the known placeholders.
+func checkBaseURL(baseURL string) error {
+	if strings.ContainsAny(baseURLPlaceholders.Replace(baseURL), "{}") {
+		return fmt.Errorf("unknown placeholder in %q", baseURL)
+	}
+	return nil
+}
+
+// templateFields are the fields of the template data filled in
+// by the contravider. Variables must not be named like them so
+// that $(( .Vars.BaseURL ))$ is not mistaken for $(( .BaseURL ))$.
+var templateFields = []string{
+	"BaseURL",
+	"PublicOpenPGPKeyFingerprint",
+	"PublicOpenPGPKeyURL",
+	"CanonicalURL",
+	"DistributionURL",
+	"PublisherNamespaceURL",
+	"Vars",
+}
+
+// templateVarRe matches the names usable as $(( .Vars.name ))$.
+var templateVarRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// checkTemplateVars checks if the names of template variables
+// can be used in templates and are not reserved.
+func checkTemplateVars(vars map[string]string) error {
+	for _, name := range slices.Sorted(maps.Keys(vars)) {
+		switch {
+		case !templateVarRe.MatchString(name):
+			return fmt.Errorf("template variable %q is not an identifier", name)
+		case slices.Contains(templateFields, name):
+			return fmt.Errorf("template variable %q is reserved", name)
+		}
+	}
+	return nil
+}
+
 // Log are the config options for the logging.
 type Log struct {
 	File   string     `toml:"file"`
@@ -61,31 +212,129 @@ type Log struct {
 	JSON   bool       `toml:"json"`
 }
 
+// AuthLockout are the config options to lock out clients
+// after repeated failed authentications.
+type AuthLockout struct {
+	Attempts int           `toml:"attempts"`
+	Window   time.Duration `toml:"window"`
+	Cooldown time.Duration `toml:"cooldown"`
+}
+
 // Web are the config options for the web interface.
 type Web struct {
-	Host     string `toml:"host"`
-	Port     int    `toml:"port"`
-	Protocol string `toml:"protocol"`
-	Root     string `toml:"root"`
-	CertFile string `toml:"cert_file"`
-	KeyFile  string `toml:"key_file"`
+	Host              string        `toml:"host"`
+	Port              int           `toml:"port"`
+	Protocol          string        `toml:"protocol"`
+	Root              string        `toml:"root"`
+	CertFile          string        `toml:"cert_file"`
+	KeyFile           string        `toml:"key_file"`
+	ProfileHeader     string        `toml:"profile_header"`
+	IndexTitle        string        `toml:"index_title"`
+	IndexLang         string        `toml:"index_lang"`
+	AdminUser         string        `toml:"admin_user"`
+	AdminPassword     string        `toml:"admin_password"`
+	AdminSocket       string        `toml:"admin_socket"`
+	AdminAddress      string        `toml:"admin_address"`
+	RobotsTxt         string        `toml:"robots_txt"`
+	MaxConnections    int           `toml:"max_connections"`
+	IdempotencyWindow time.Duration `toml:"idempotency_window"`
+	ResponseBudget    time.Duration `toml:"response_budget"`
+	PublicFiles       []string      `toml:"public_files"`
+	AuthLockout       AuthLockout   `toml:"auth_lockout"`
+	Sessions          Sessions      `toml:"sessions"`
+	TLS               TLS           `toml:"tls"`
+	HTTP3             bool          `toml:"http3"`
+	Compress          bool          `toml:"compress"`
+
+	ContentTypes map[string]string `toml:"content_types"`
 }
 
 // Signing are the options needed to sign the advisories.
 type Signing struct {
+	Key             string        `toml:"key"`
+	Passphrase      string        `toml:"passphrase"`
+	HashFormat      string        `toml:"hash_format"`
+	Hashes          []string      `toml:"hashes"`
+	Mode            string        `toml:"mode"`
+	VerifyAfterSign bool          `toml:"verify_after_sign"`
+	Manifest        bool          `toml:"manifest"`
+	MaxConcurrency  int           `toml:"max_concurrency"`
+	InMemoryMB      int           `toml:"in_memory_mb"`
+	ExpiryWarning   time.Duration `toml:"expiry_warning"`
+	RefuseExpired   bool          `toml:"refuse_expired"`
+	Subkey          string        `toml:"subkey"`
+	FixedTimestamp  string        `toml:"fixed_timestamp"`
+	ArmorVersion    string        `toml:"armor_version"`
+	ArmorComment    string        `toml:"armor_comment"`
+	// Keys are additional keys the profiles can be signed with.
+	Keys []SigningKey `toml:"keys"`
+}
+
+// SignTime returns the fixed creation time of the signatures.
+// It is zero if the creation times are not fixed.
+func (sg *Signing) SignTime() (time.Time, error) {
+	if sg.FixedTimestamp == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, sg.FixedTimestamp)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("signing.fixed_timestamp: %w", err)
+	}
+	return t, nil
+}
+
+// SigningKey is an additional signing key selected by its name.
+type SigningKey struct {
+	Name       string `toml:"name"`
 	Key        string `toml:"key"`
 	Passphrase string `toml:"passphrase"`
+	Subkey     string `toml:"subkey"`
 }
 
 // Providers are the config options for the served provider profiles.
 type Providers struct {
-	GitURL       string        `toml:"git_url"`
-	BaseURL      string        `toml:"base_url"`
-	ProfilesFile string        `toml:"profiles_file"`
-	Profiles     Profiles      `toml:"profiles"`
-	WorkDir      string        `toml:"workdir"`
-	Update       time.Duration `toml:"update"`
-	Result       string        `toml:"result"`
+	GitURL             string        `toml:"git_url"`
+	GitAuthor          string        `toml:"git_author"`
+	GitEmail           string        `toml:"git_email"`
+	GitUsername        string        `toml:"git_username"`
+	GitToken           string        `toml:"git_token"`
+	MergeNoFF          bool          `toml:"merge_no_ff"`
+	ShallowDepth       int           `toml:"shallow_depth"`
+	SingleBranch       bool          `toml:"single_branch"`
+	BaseURL            string        `toml:"base_url"`
+	ProfilesFile       string        `toml:"profiles_file"`
+	Profiles           Profiles      `toml:"profiles"`
+	WorkDir            string        `toml:"workdir"`
+	Update             time.Duration `toml:"update"`
+	GitCheck           time.Duration `toml:"git_check"`
+	GitCheckTimeout    time.Duration `toml:"git_check_timeout"`
+	PreviewTTL         time.Duration `toml:"preview_ttl"`
+	PreviewGrace       time.Duration `toml:"preview_grace"`
+	DeleteGrace        time.Duration `toml:"delete_grace"`
+	MaxCachedProfiles  int           `toml:"max_cached_profiles"`
+	MinFreeMB          int           `toml:"min_free_mb"`
+	KeepExports        int           `toml:"keep_exports"`
+	Prewarm            bool          `toml:"prewarm"`
+	GCDryRun           bool          `toml:"gc_dry_run"`
+	VerifyOnStart      bool          `toml:"verify_on_start"`
+	VerbatimExtensions []string      `toml:"verbatim_extensions"`
+	MaxTemplateMB      int           `toml:"max_template_mb"`
+	CanonicalizeJSON   bool          `toml:"canonicalize_json"`
+	Dedup              bool          `toml:"dedup"`
+	MinRebuildInterval time.Duration `toml:"min_rebuild_interval"`
+	Result             string        `toml:"result"`
+
+	TemplateVars map[string]string `toml:"template_vars"`
+}
+
+// Metrics are the config options of the Prometheus metrics.
+type Metrics struct {
+	Enabled bool `toml:"enabled"`
+}
+
+// Debug are the config options for debugging the contravider.
+type Debug struct {
+	Pprof bool `toml:"pprof"`
 }
 
 // Config are all the configuration options.
@@ -94,6 +343,8 @@ type Config struct {
 	Web       Web       `toml:"web"`
 	Signing   Signing   `toml:"signing"`
 	Providers Providers `toml:"providers"`
+	Metrics   Metrics   `toml:"metrics"`
+	Debug     Debug     `toml:"debug"`
 }
 
 // Addr returns the combined address the web server should bind to.
@@ -102,7 +353,8 @@ func (w *Web) Addr() string {
 }
 
 // Load loads the configuration from a given file. An empty string
-// resorts to the default configuration.
+// resorts to the default configuration. Files with a ".json"
+// extension are decoded as JSON, all others as TOML.
 func Load(file string) (*Config, error) {
 	cfg := &Config{
 		Log: Log{
@@ -112,27 +364,88 @@ func Load(file string) (*Config, error) {
 			JSON:   defaultLogJSON,
 		},
 		Web: Web{
-			Host:     defaultWebHost,
-			Port:     defaultWebPort,
-			Protocol: defaultWebProtocol,
-			Root:     defaultWebRoot,
-			CertFile: defaultWebCertFile,
-			KeyFile:  defaultWebKeyFile,
+			Host:              defaultWebHost,
+			Port:              defaultWebPort,
+			Protocol:          defaultWebProtocol,
+			Root:              defaultWebRoot,
+			CertFile:          defaultWebCertFile,
+			KeyFile:           defaultWebKeyFile,
+			ProfileHeader:     defaultWebProfileHeader,
+			IndexTitle:        defaultWebIndexTitle,
+			IndexLang:         defaultWebIndexLang,
+			AdminUser:         defaultWebAdminUser,
+			AdminPassword:     defaultWebAdminPassword,
+			AdminSocket:       defaultWebAdminSocket,
+			AdminAddress:      defaultWebAdminAddress,
+			RobotsTxt:         defaultWebRobotsTxt,
+			MaxConnections:    defaultWebMaxConnections,
+			IdempotencyWindow: defaultWebIdempotency,
+			ResponseBudget:    defaultWebResponseBudget,
+			HTTP3:             defaultWebHTTP3,
+			Compress:          defaultWebCompress,
+			ContentTypes:      defaultWebContentTypes(),
+			TLS: TLS{
+				Mode:     defaultWebTLSMode,
+				Validity: defaultWebTLSValidity,
+			},
+			AuthLockout: AuthLockout{
+				Attempts: defaultWebLockoutAttempts,
+				Window:   defaultWebLockoutWindow,
+				Cooldown: defaultWebLockoutCooldown,
+			},
+			Sessions: Sessions{
+				MaxAge: defaultWebSessionsMaxAge,
+			},
 		},
 		Signing: Signing{
-			Key:        defaultSigningKey,
-			Passphrase: defaultPassphrase,
+			Key:             defaultSigningKey,
+			Passphrase:      defaultPassphrase,
+			HashFormat:      defaultSigningHashFormat,
+			Mode:            defaultSigningMode,
+			Hashes:          []string{HashSHA256, HashSHA512},
+			VerifyAfterSign: defaultSigningVerify,
+			Manifest:        defaultSigningManifest,
+			MaxConcurrency:  defaultSigningMaxConc,
+			InMemoryMB:      defaultSigningInMemoryMB,
+			ExpiryWarning:   defaultSigningExpiryWarn,
+			RefuseExpired:   defaultSigningRefuseExp,
 		},
 		Providers: Providers{
-			GitURL:  defaultProvidersGitURL,
-			BaseURL: defaultProvidersBaseURL,
-			WorkDir: defaultProvidersWorkDir,
-			Result:  defaultProvidersResult,
-			Update:  defaultProvidersUpdate,
+			GitURL:             defaultProvidersGitURL,
+			GitAuthor:          defaultProvidersGitAuthor,
+			GitEmail:           defaultProvidersGitEmail,
+			MergeNoFF:          defaultProvidersMergeNoFF,
+			ShallowDepth:       defaultProvidersShallowDepth,
+			SingleBranch:       defaultProvidersSingleBranch,
+			BaseURL:            defaultProvidersBaseURL,
+			WorkDir:            defaultProvidersWorkDir,
+			Result:             defaultProvidersResult,
+			Update:             defaultProvidersUpdate,
+			GitCheck:           defaultProvidersGitCheck,
+			GitCheckTimeout:    defaultProvidersGitCheckTimeout,
+			PreviewTTL:         defaultProvidersPreviewTTL,
+			PreviewGrace:       defaultProvidersPreviewGrace,
+			DeleteGrace:        defaultProvidersDeleteGrace,
+			MaxTemplateMB:      defaultProvidersMaxTemplateMB,
+			CanonicalizeJSON:   defaultProvidersCanonicalize,
+			MaxCachedProfiles:  defaultProvidersMaxCached,
+			MinFreeMB:          defaultProvidersMinFreeMB,
+			KeepExports:        defaultProvidersKeepExports,
+			Prewarm:            defaultProvidersPrewarm,
+			GCDryRun:           defaultProvidersGCDryRun,
+			VerifyOnStart:      defaultProvidersVerifyOnStart,
+			Dedup:              defaultProvidersDedup,
+			MinRebuildInterval: defaultProvidersMinRebuild,
+		},
+		Metrics: Metrics{
+			Enabled: defaultMetricsEnabled,
+		},
+		Debug: Debug{
+			Pprof: defaultDebugPprof,
 		},
 	}
 	if file != "" {
-		md, err := toml.DecodeFile(file, cfg)
+		md, err := decodeFile(file, cfg)
 		if err != nil {
 			return nil, err
 		}
@@ -144,15 +457,16 @@ func Load(file string) (*Config, error) {
 	if err := cfg.fillFromEnv(); err != nil {
 		return nil, err
 	}
+	if err := cfg.Providers.resolveResult(file); err != nil {
+		return nil, err
+	}
 	if cfg.Providers.ProfilesFile != "" {
 		var profiles Profiles
-		if _, err := toml.DecodeFile(cfg.Providers.ProfilesFile, &profiles); err != nil {
+		if _, err := decodeFile(cfg.Providers.ProfilesFile, &profiles); err != nil {
 			return nil, fmt.Errorf("failed to load profiles from %q: %w", cfg.Providers.ProfilesFile, err)
 		}
 		if len(cfg.Providers.Profiles) != 0 {
-			if err := cfg.Providers.Profiles.Merge(profiles); err != nil {
-				return nil, fmt.Errorf("merging profiles failed: %w", err)
-			}
+			cfg.Providers.Profiles.Merge(profiles)
 		} else {
 			cfg.Providers.Profiles = profiles
 		}
@@ -160,6 +474,28 @@ func Load(file string) (*Config, error) {
 	return cfg, nil
 }
 
+// PresetDefaults fills in the defaults which are generated once
+// at startup. It is called after the configuration is validated.
+func (cfg *Config) PresetDefaults() {
+	cfg.Web.Sessions.presetSecret()
+}
+
+// resolveResult makes the result directory an absolute path.
+// Relative paths are interpreted relative to the directory of
+// the config file or the current working directory if there is none.
+func (p *Providers) resolveResult(file string) error {
+	result := p.Result
+	if !filepath.IsAbs(result) && file != "" {
+		result = filepath.Join(filepath.Dir(file), result)
+	}
+	abs, err := filepath.Abs(result)
+	if err != nil {
+		return fmt.Errorf("config: cannot resolve result directory %q: %w", p.Result, err)
+	}
+	p.Result = abs
+	return nil
+}
+
 func (cfg *Config) fillFromEnv() error {
 	var (
 		storeString   = store(noparse)
@@ -167,6 +503,8 @@ func (cfg *Config) fillFromEnv() error {
 		storeBool     = store(strconv.ParseBool)
 		storeLevel    = store(storeLevel)
 		storeDuration = store(time.ParseDuration)
+		storeProfiles = store(parseProfiles)
+		storeList     = store(splitList)
 	)
 	return storeFromEnv(
 		envStore{"CONTRAVIDER_LOG_FILE", storeString(&cfg.Log.File)},
@@ -179,10 +517,77 @@ func (cfg *Config) fillFromEnv() error {
 		envStore{"CONTRAVIDER_WEB_ROOT", storeString(&cfg.Web.Root)},
 		envStore{"CONTRAVIDER_WEB_CERT_FILE", storeString(&cfg.Web.CertFile)},
 		envStore{"CONTRAVIDER_WEB_KEY_FILE", storeString(&cfg.Web.KeyFile)},
+		envStore{"CONTRAVIDER_WEB_PROFILE_HEADER", storeString(&cfg.Web.ProfileHeader)},
+		envStore{"CONTRAVIDER_WEB_INDEX_TITLE", storeString(&cfg.Web.IndexTitle)},
+		envStore{"CONTRAVIDER_WEB_INDEX_LANG", storeString(&cfg.Web.IndexLang)},
+		envStore{"CONTRAVIDER_WEB_ADMIN_USER", storeString(&cfg.Web.AdminUser)},
+		envStore{"CONTRAVIDER_WEB_ADMIN_PASSWORD", storeString(&cfg.Web.AdminPassword)},
+		envStore{"CONTRAVIDER_WEB_ADMIN_SOCKET", storeString(&cfg.Web.AdminSocket)},
+		envStore{"CONTRAVIDER_WEB_ADMIN_ADDRESS", storeString(&cfg.Web.AdminAddress)},
+		envStore{"CONTRAVIDER_WEB_ROBOTS_TXT", storeString(&cfg.Web.RobotsTxt)},
+		envStore{"CONTRAVIDER_WEB_MAX_CONNECTIONS", storeInt(&cfg.Web.MaxConnections)},
+		envStore{"CONTRAVIDER_WEB_IDEMPOTENCY_WINDOW", storeDuration(&cfg.Web.IdempotencyWindow)},
+		envStore{"CONTRAVIDER_WEB_RESPONSE_BUDGET", storeDuration(&cfg.Web.ResponseBudget)},
+		envStore{"CONTRAVIDER_WEB_AUTH_LOCKOUT_ATTEMPTS", storeInt(&cfg.Web.AuthLockout.Attempts)},
+		envStore{"CONTRAVIDER_WEB_AUTH_LOCKOUT_WINDOW", storeDuration(&cfg.Web.AuthLockout.Window)},
+		envStore{"CONTRAVIDER_WEB_AUTH_LOCKOUT_COOLDOWN", storeDuration(&cfg.Web.AuthLockout.Cooldown)},
+		envStore{"CONTRAVIDER_WEB_SESSIONS_MAX_AGE", storeDuration(&cfg.Web.Sessions.MaxAge)},
+		envStore{"CONTRAVIDER_WEB_SESSIONS_SECRET", storeString(&cfg.Web.Sessions.Secret)},
+		envStore{"CONTRAVIDER_WEB_HTTP3", storeBool(&cfg.Web.HTTP3)},
+		envStore{"CONTRAVIDER_WEB_COMPRESS", storeBool(&cfg.Web.Compress)},
+		envStore{"CONTRAVIDER_WEB_TLS_MODE", storeString(&cfg.Web.TLS.Mode)},
+		envStore{"CONTRAVIDER_WEB_TLS_VALIDITY", storeDuration(&cfg.Web.TLS.Validity)},
+		envStore{"CONTRAVIDER_WEB_TLS_MIN_VERSION", storeString(&cfg.Web.TLS.MinVersion)},
+		envStore{"CONTRAVIDER_WEB_TLS_MAX_VERSION", storeString(&cfg.Web.TLS.MaxVersion)},
+		envStore{"CONTRAVIDER_WEB_TLS_CIPHER_SUITES", storeList(&cfg.Web.TLS.CipherSuites)},
+		envStore{"CONTRAVIDER_WEB_TLS_CURVE_PREFERENCES", storeList(&cfg.Web.TLS.CurvePreferences)},
+		envStore{"CONTRAVIDER_WEB_TLS_ALPN", storeList(&cfg.Web.TLS.ALPN)},
+		envStore{"CONTRAVIDER_WEB_TLS_OCSP_STAPLE", storeString(&cfg.Web.TLS.OCSPStaple)},
+		envStore{"CONTRAVIDER_WEB_TLS_OCSP_REFRESH", storeDuration(&cfg.Web.TLS.OCSPRefresh)},
 		envStore{"CONTRAVIDER_SIGNING_KEY", storeString(&cfg.Signing.Key)},
+		envStore{"CONTRAVIDER_SIGNING_HASH_FORMAT", storeString(&cfg.Signing.HashFormat)},
+		envStore{"CONTRAVIDER_SIGNING_MODE", storeString(&cfg.Signing.Mode)},
+		envStore{"CONTRAVIDER_SIGNING_HASHES", storeList(&cfg.Signing.Hashes)},
+		envStore{"CONTRAVIDER_SIGNING_VERIFY_AFTER_SIGN", storeBool(&cfg.Signing.VerifyAfterSign)},
+		envStore{"CONTRAVIDER_SIGNING_MANIFEST", storeBool(&cfg.Signing.Manifest)},
+		envStore{"CONTRAVIDER_SIGNING_MAX_CONCURRENCY", storeInt(&cfg.Signing.MaxConcurrency)},
+		envStore{"CONTRAVIDER_SIGNING_IN_MEMORY_MB", storeInt(&cfg.Signing.InMemoryMB)},
+		envStore{"CONTRAVIDER_SIGNING_EXPIRY_WARNING", storeDuration(&cfg.Signing.ExpiryWarning)},
+		envStore{"CONTRAVIDER_SIGNING_REFUSE_EXPIRED", storeBool(&cfg.Signing.RefuseExpired)},
+		envStore{"CONTRAVIDER_SIGNING_SUBKEY", storeString(&cfg.Signing.Subkey)},
+		envStore{"CONTRAVIDER_SIGNING_FIXED_TIMESTAMP", storeString(&cfg.Signing.FixedTimestamp)},
+		envStore{"CONTRAVIDER_SIGNING_ARMOR_VERSION", storeString(&cfg.Signing.ArmorVersion)},
+		envStore{"CONTRAVIDER_SIGNING_ARMOR_COMMENT", storeString(&cfg.Signing.ArmorComment)},
 		envStore{"CONTRAVIDER_PROVIDERS_GIT_URL", storeString(&cfg.Providers.GitURL)},
+		envStore{"CONTRAVIDER_PROVIDERS_GIT_USERNAME", storeString(&cfg.Providers.GitUsername)},
+		envStore{"CONTRAVIDER_PROVIDERS_GIT_TOKEN", storeString(&cfg.Providers.GitToken)},
+		envStore{"CONTRAVIDER_PROVIDERS_GIT_AUTHOR", storeString(&cfg.Providers.GitAuthor)},
+		envStore{"CONTRAVIDER_PROVIDERS_GIT_EMAIL", storeString(&cfg.Providers.GitEmail)},
+		envStore{"CONTRAVIDER_PROVIDERS_MERGE_NO_FF", storeBool(&cfg.Providers.MergeNoFF)},
+		envStore{"CONTRAVIDER_PROVIDERS_SHALLOW_DEPTH", storeInt(&cfg.Providers.ShallowDepth)},
+		envStore{"CONTRAVIDER_PROVIDERS_SINGLE_BRANCH", storeBool(&cfg.Providers.SingleBranch)},
 		envStore{"CONTRAVIDER_PROVIDERS_BASE_URL", storeString(&cfg.Providers.BaseURL)},
 		envStore{"CONTRAVIDER_PROVIDERS_UPDATE", storeDuration(&cfg.Providers.Update)},
+		envStore{"CONTRAVIDER_PROVIDERS_GIT_CHECK", storeDuration(&cfg.Providers.GitCheck)},
+		envStore{"CONTRAVIDER_PROVIDERS_GIT_CHECK_TIMEOUT", storeDuration(&cfg.Providers.GitCheckTimeout)},
+		envStore{"CONTRAVIDER_PROVIDERS_PREVIEW_TTL", storeDuration(&cfg.Providers.PreviewTTL)},
+		envStore{"CONTRAVIDER_PROVIDERS_PREVIEW_GRACE", storeDuration(&cfg.Providers.PreviewGrace)},
+		envStore{"CONTRAVIDER_PROVIDERS_DELETE_GRACE", storeDuration(&cfg.Providers.DeleteGrace)},
+		envStore{"CONTRAVIDER_PROVIDERS_MAX_CACHED_PROFILES", storeInt(&cfg.Providers.MaxCachedProfiles)},
+		envStore{"CONTRAVIDER_PROVIDERS_MIN_FREE_MB", storeInt(&cfg.Providers.MinFreeMB)},
+		envStore{"CONTRAVIDER_PROVIDERS_KEEP_EXPORTS", storeInt(&cfg.Providers.KeepExports)},
+		envStore{"CONTRAVIDER_PROVIDERS_PREWARM", storeBool(&cfg.Providers.Prewarm)},
+		envStore{"CONTRAVIDER_PROVIDERS_GC_DRY_RUN", storeBool(&cfg.Providers.GCDryRun)},
+		envStore{"CONTRAVIDER_PROVIDERS_VERIFY_ON_START", storeBool(&cfg.Providers.VerifyOnStart)},
+		envStore{"CONTRAVIDER_PROVIDERS_VERBATIM_EXTENSIONS", storeList(&cfg.Providers.VerbatimExtensions)},
+		envStore{"CONTRAVIDER_PROVIDERS_MAX_TEMPLATE_MB", storeInt(&cfg.Providers.MaxTemplateMB)},
+		envStore{"CONTRAVIDER_PROVIDERS_CANONICALIZE_JSON", storeBool(&cfg.Providers.CanonicalizeJSON)},
+		envStore{"CONTRAVIDER_PROVIDERS_DEDUP", storeBool(&cfg.Providers.Dedup)},
+		envStore{"CONTRAVIDER_PROVIDERS_MIN_REBUILD_INTERVAL", storeDuration(&cfg.Providers.MinRebuildInterval)},
 		envStore{"CONTRAVIDER_PROVIDERS_FILE", storeString(&cfg.Providers.ProfilesFile)},
+		envStore{"CONTRAVIDER_PROVIDERS_PROFILES", storeProfiles(&cfg.Providers.Profiles)},
+		envStore{"CONTRAVIDER_PROVIDERS_RESULT", storeString(&cfg.Providers.Result)},
+		envStore{"CONTRAVIDER_METRICS_ENABLED", storeBool(&cfg.Metrics.Enabled)},
+		envStore{"CONTRAVIDER_DEBUG_PPROF", storeBool(&cfg.Debug.Pprof)},
 	)
 }
@@ -0,0 +1,162 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadResolvesResult(t *testing.T) {
+	file := writeConfig(t, `
+[providers]
+result = "out"
+`)
+	want := filepath.Join(filepath.Dir(file), "out")
+	// The working directory does not matter.
+	for _, check := range []struct {
+		cwd  string
+		name string
+	}{
+		{t.TempDir(), file},
+		{filepath.Dir(file), file},
+		{filepath.Dir(file), filepath.Base(file)},
+	} {
+		t.Chdir(check.cwd)
+		cfg, err := Load(check.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Providers.Result != want {
+			t.Errorf("cwd %s, config %s: got result %q, want %q",
+				check.cwd, check.name, cfg.Providers.Result, want)
+		}
+	}
+
+	abs := filepath.Join(t.TempDir(), "absolute")
+	cfg, err := Load(writeConfig(t, "[providers]\nresult = \""+filepath.ToSlash(abs)+"\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Providers.Result != abs {
+		t.Errorf("got result %q, want %q", cfg.Providers.Result, abs)
+	}
+
+	// Without a config file it is relative to the working directory.
+	cwd := t.TempDir()
+	t.Chdir(cwd)
+	if cfg, err = Load(""); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Providers.Result != cwd {
+		t.Errorf("without config file: got result %q, want %q", cfg.Providers.Result, cwd)
+	}
+}
+
+func TestLoadJSON(t *testing.T) {
+	dir := t.TempDir()
+	load := func(name, content string) (*Config, error) {
+		t.Helper()
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(content), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		return Load(file)
+	}
+	fromTOML, err := load("contravider.toml", `
+[log]
+level = "debug"
+
+[web]
+port = 8443
+index_title = "Test"
+
+[signing]
+hash_format = "bare"
+
+[providers]
+update = "5m"
+keep_exports = 3
+
+[providers.profiles]
+main = ["main"]
+all = { branches = ["#main", "extra"], crawlable = true }
+`)
+	if err != nil {
+		t.Fatalf("loading TOML failed: %v", err)
+	}
+	fromJSON, err := load("contravider.json", `{
+	"log": {"level": "debug"},
+	"web": {"port": 8443, "index_title": "Test"},
+	"signing": {"hash_format": "bare"},
+	"providers": {
+		"update": "5m",
+		"keep_exports": 3,
+		"profiles": {
+			"main": ["main"],
+			"all": {"branches": ["#main", "extra"], "crawlable": true}
+		}
+	}
+}`)
+	if err != nil {
+		t.Fatalf("loading JSON failed: %v", err)
+	}
+	if !reflect.DeepEqual(fromJSON, fromTOML) {
+		t.Errorf("JSON and TOML configs differ:\n%+v\n%+v", fromJSON, fromTOML)
+	}
+
+	// Unknown keys are rejected like in TOML.
+	if _, err := load("unknown.json", `{"web": {"prot": 8443}}`); err == nil ||
+		!strings.Contains(err.Error(), "could not parse") {
+		t.Errorf("unknown key: got error %v", err)
+	}
+	if _, err := load("trailing.json", `{} {}`); err == nil {
+		t.Error("trailing data is accepted")
+	}
+}
+
+func TestPresetDefaultsSessionSecret(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `
+[web.sessions]
+max_age = "1h"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cfg.Web.Sessions.Key(); err == nil {
+		t.Fatal("got a key without a secret")
+	}
+	cfg.PresetDefaults()
+	first, err := cfg.Web.Sessions.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The generated key is kept for all controllers of the server.
+	second, err := cfg.Web.Sessions.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != SessionSecretSize || !bytes.Equal(first, second) {
+		t.Errorf("got keys %x and %x", first, second)
+	}
+
+	// A configured secret is kept.
+	secret := strings.Repeat("ab", SessionSecretSize)
+	cfg.Web.Sessions.Secret = secret
+	cfg.PresetDefaults()
+	if cfg.Web.Sessions.Secret != secret {
+		t.Errorf("got secret %q, want %q", cfg.Web.Sessions.Secret, secret)
+	}
+}
@@ -11,8 +11,11 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 )
 
 // envStore maps an env to a store function.
@@ -26,6 +29,27 @@ func storeLevel(s string) (slog.Level, error) {
 	return level, level.UnmarshalText([]byte(s))
 }
 
+// parseProfiles parses profiles given as JSON object. The profiles
+// are encoded like in TOML, either as lists or as objects.
+func parseProfiles(s string) (Profiles, error) {
+	var profiles Profiles
+	if err := json.Unmarshal([]byte(s), &profiles); err != nil {
+		return nil, fmt.Errorf("invalid profiles: %w", err)
+	}
+	return profiles, nil
+}
+
+// splitList splits a comma separated list.
+func splitList(s string) ([]string, error) {
+	var list []string
+	for item := range strings.SplitSeq(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list, nil
+}
+
 // noparse returns an unparsed string.
 func noparse(s string) (string, error) {
 	return s, nil
@@ -0,0 +1,60 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEnvProfiles(t *testing.T) {
+	fromTOML, err := Load(writeConfig(t, `
+[providers.profiles]
+main = ["main"]
+all = { branches = ["#main", "extra"], crawlable = true }
+`))
+	if err != nil {
+		t.Fatalf("loading TOML failed: %v", err)
+	}
+	t.Setenv("CONTRAVIDER_PROVIDERS_PROFILES", `{
+		"main": ["main"],
+		"all": {"branches": ["#main", "extra"], "crawlable": true}
+	}`)
+	fromEnv, err := Load("")
+	if err != nil {
+		t.Fatalf("loading from environment failed: %v", err)
+	}
+	if !reflect.DeepEqual(fromEnv.Providers.Profiles, fromTOML.Providers.Profiles) {
+		t.Errorf("got profiles %+v, want %+v", fromEnv.Providers.Profiles, fromTOML.Providers.Profiles)
+	}
+	if err := fromEnv.Validate(); err != nil {
+		t.Errorf("valid profiles do not validate: %v", err)
+	}
+
+	// Invalid profiles are reported like the ones in TOML.
+	t.Setenv("CONTRAVIDER_PROVIDERS_PROFILES", `{"main": {"branches": ["main"], "crawlable": "yes"}}`)
+	if _, err := Load(""); err == nil || !strings.Contains(err.Error(), `"crawlable"`) {
+		t.Errorf("invalid option: got error %v", err)
+	}
+	t.Setenv("CONTRAVIDER_PROVIDERS_PROFILES", `{"cyclic": ["#cyclic"]}`)
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("loading from environment failed: %v", err)
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), `self recursive definition "cyclic"`) {
+		t.Errorf("cyclic profile: got validation error %v", err)
+	}
+	t.Setenv("CONTRAVIDER_PROVIDERS_PROFILES", `[`)
+	if _, err := Load(""); err == nil {
+		t.Error("invalid JSON is accepted")
+	}
+}
@@ -0,0 +1,109 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// UnmarshalJSON implements [json.Unmarshaler].
+// The profiles are encoded like in TOML.
+func (p *Profiles) UnmarshalJSON(data []byte) error {
+	doc, err := decodeJSON(data)
+	if err != nil {
+		return err
+	}
+	return p.UnmarshalTOML(doc)
+}
+
+// decodeFile decodes a file into v. Files with a ".json" extension
+// are decoded as JSON, all others as TOML.
+func decodeFile(file string, v any) (toml.MetaData, error) {
+	if !strings.EqualFold(filepath.Ext(file), ".json") {
+		return toml.DecodeFile(file, v)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return toml.MetaData{}, err
+	}
+	doc, err := decodeJSON(data)
+	if err != nil {
+		return toml.MetaData{}, fmt.Errorf("invalid JSON in %q: %w", file, err)
+	}
+	// Take the detour over TOML to decode exactly like a TOML file,
+	// e.g. durations given as strings and the detection of unknown keys.
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(doc); err != nil {
+		return toml.MetaData{}, fmt.Errorf("converting %q failed: %w", file, err)
+	}
+	return toml.Decode(buf.String(), v)
+}
+
+// decodeJSON decodes a JSON object into the generic
+// representation used by TOML.
+func decodeJSON(data []byte) (map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after JSON object")
+	}
+	if err := fromJSON(doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// fromJSON replaces the JSON numbers with integers or floats in place.
+func fromJSON(data any) error {
+	convert := func(v any) (any, error) {
+		switch x := v.(type) {
+		case json.Number:
+			if i, err := x.Int64(); err == nil {
+				return i, nil
+			}
+			return x.Float64()
+		case nil:
+			return nil, fmt.Errorf("null values are not supported")
+		default:
+			return v, fromJSON(v)
+		}
+	}
+	switch x := data.(type) {
+	case map[string]any:
+		for k, v := range x {
+			c, err := convert(v)
+			if err != nil {
+				return fmt.Errorf("%q: %w", k, err)
+			}
+			x[k] = c
+		}
+	case []any:
+		for i, v := range x {
+			c, err := convert(v)
+			if err != nil {
+				return fmt.Errorf("%d: %w", i, err)
+			}
+			x[i] = c
+		}
+	}
+	return nil
+}
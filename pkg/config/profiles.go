@@ -11,14 +11,60 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"maps"
 	"slices"
 	"strings"
 )
 
+// Profile is a profile served by this contravider.
+type Profile struct {
+	// Branches are the branches merged into this profile.
+	// Entries starting with '#' reference other profiles.
+	Branches []string
+	// Key is an optional signing key used instead of the default one.
+	Key string
+	// Passphrase is the passphrase of Key.
+	Passphrase string
+	// SigningKey is the name of one of the keys configured
+	// in the signing section used instead of the default one.
+	// It excludes Key.
+	SigningKey string
+	// Passthrough serves the files as they are without
+	// signing and hashing them.
+	Passthrough bool
+	// Crawlable allows crawlers to index this profile.
+	Crawlable bool
+	// Tags group the profiles.
+	Tags []string
+	// Hashes override the hash algorithms of the written
+	// hash files if not nil.
+	Hashes []string
+	// DirectoryLayout files the advisories under year or
+	// year/month folders if not empty.
+	DirectoryLayout string
+	// ShuffleSeed shuffles the entries of the generated
+	// index files with this seed if not nil.
+	ShuffleSeed *uint64
+	// BaseURL overrides the base URL of the providers
+	// section if not empty.
+	BaseURL string
+	// TemplateVars are merged over the template variables
+	// of the providers section.
+	TemplateVars map[string]string
+}
+
+// Layouts of the directory distributions.
+const (
+	// LayoutYear files the advisories under <year>/.
+	LayoutYear = "year"
+	// LayoutMonth files the advisories under <year>/<month>/.
+	LayoutMonth = "month"
+)
+
 // Profiles are the profiles served by this contravider.
-type Profiles map[string][]string
+type Profiles map[string]*Profile
 
 // UnmarshalTOML implements [toml.Unmarshaler].
 func (p *Profiles) UnmarshalTOML(data any) error {
@@ -28,34 +74,147 @@ func (p *Profiles) UnmarshalTOML(data any) error {
 	}
 	pr := make(Profiles, len(m))
 	for k, v := range m {
-		l, ok := v.([]any)
-		if !ok {
-			return fmt.Errorf("unexpected type %T", v)
+		profile, err := unmarshalProfile(v)
+		if err != nil {
+			return fmt.Errorf("profile %q: %w", k, err)
 		}
-		list := make([]string, 0, len(l))
-		for _, s := range l {
-			str, ok := s.(string)
-			if !ok {
-				return fmt.Errorf("unexpected type %T", s)
+		pr[k] = profile
+	}
+	*p = pr
+	return nil
+}
+
+// unmarshalProfile decodes a profile either given as list of
+// branches or as a table with the branches and additional options.
+func unmarshalProfile(data any) (*Profile, error) {
+	switch v := data.(type) {
+	case []any:
+		branches, err := unmarshalStrings(v)
+		if err != nil {
+			return nil, err
+		}
+		return &Profile{Branches: branches}, nil
+	case map[string]any:
+		var profile Profile
+		for key, value := range v {
+			var err error
+			switch key {
+			case "branches":
+				l, ok := value.([]any)
+				if !ok {
+					return nil, fmt.Errorf("unexpected type %T of %q", value, key)
+				}
+				profile.Branches, err = unmarshalStrings(l)
+			case "key":
+				profile.Key, err = unmarshalString(value)
+			case "passphrase":
+				profile.Passphrase, err = unmarshalString(value)
+			case "signing_key":
+				profile.SigningKey, err = unmarshalString(value)
+			case "passthrough":
+				profile.Passthrough, err = unmarshalBool(value)
+			case "crawlable":
+				profile.Crawlable, err = unmarshalBool(value)
+			case "tags":
+				l, ok := value.([]any)
+				if !ok {
+					return nil, fmt.Errorf("unexpected type %T of %q", value, key)
+				}
+				profile.Tags, err = unmarshalStrings(l)
+			case "directory_layout":
+				if profile.DirectoryLayout, err = unmarshalString(value); err == nil {
+					switch profile.DirectoryLayout {
+					case LayoutYear, LayoutMonth:
+					default:
+						err = fmt.Errorf("unknown layout %q", profile.DirectoryLayout)
+					}
+				}
+			case "shuffle_seed":
+				var seed int64
+				if seed, err = unmarshalInt(value); err == nil {
+					if seed < 0 {
+						err = fmt.Errorf("seed %d must not be negative", seed)
+					} else {
+						useed := uint64(seed)
+						profile.ShuffleSeed = &useed
+					}
+				}
+			case "base_url":
+				profile.BaseURL, err = unmarshalString(value)
+			case "template_vars":
+				m, ok := value.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("unexpected type %T of %q", value, key)
+				}
+				profile.TemplateVars = make(map[string]string, len(m))
+				for k, v := range m {
+					if profile.TemplateVars[k], err = unmarshalString(v); err != nil {
+						err = fmt.Errorf("%q: %w", k, err)
+						break
+					}
+				}
+			case "hashes":
+				l, ok := value.([]any)
+				if !ok {
+					return nil, fmt.Errorf("unexpected type %T of %q", value, key)
+				}
+				profile.Hashes, err = unmarshalStrings(l)
+			default:
+				return nil, fmt.Errorf("unknown option %q", key)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%q: %w", key, err)
 			}
-			list = append(list, str)
 		}
-		pr[k] = list
+		return &profile, nil
+	default:
+		return nil, fmt.Errorf("unexpected type %T", data)
 	}
-	if err := pr.check(); err != nil {
-		return err
+}
+
+func unmarshalString(data any) (string, error) {
+	str, ok := data.(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected type %T", data)
 	}
-	*p = pr
-	return nil
+	return str, nil
+}
+
+func unmarshalBool(data any) (bool, error) {
+	b, ok := data.(bool)
+	if !ok {
+		return false, fmt.Errorf("unexpected type %T", data)
+	}
+	return b, nil
+}
+
+func unmarshalInt(data any) (int64, error) {
+	i, ok := data.(int64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected type %T", data)
+	}
+	return i, nil
+}
+
+func unmarshalStrings(data []any) ([]string, error) {
+	list := make([]string, 0, len(data))
+	for _, s := range data {
+		str, err := unmarshalString(s)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, str)
+	}
+	return list, nil
 }
 
 // Merge merges the given profiles into these.
-func (p Profiles) Merge(o Profiles) error {
+func (p Profiles) Merge(o Profiles) {
 	maps.Copy(p, o)
-	return p.check()
 }
 
-// check checks for cyclic and undefined definitions.
+// check checks for cyclic and undefined definitions and
+// for profiles setting both key and signing_key.
 func (p Profiles) check() error {
 	checkProfile := func(name string, branches []string) error {
 		seen := map[string]bool{name: true}
@@ -70,11 +229,11 @@ func (p Profiles) check() error {
 				return fmt.Errorf("self recursive definition %q", def)
 			}
 			seen[def] = true
-			branches, ok := p[def]
+			profile, ok := p[def]
 			if !ok {
 				return fmt.Errorf("undefined defintion %q", def)
 			}
-			for _, branch := range branches {
+			for _, branch := range profile.Branches {
 				if err := resolve(branch); err != nil {
 					return err
 				}
@@ -88,20 +247,32 @@ func (p Profiles) check() error {
 		}
 		return nil
 	}
-	for name, branches := range p {
-		if err := checkProfile(name, branches); err != nil {
-			return err
+	// Report the problems of all profiles in a deterministic order.
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(p)) {
+		if err := checkProfile(name, p[name].Branches); err != nil {
+			errs = append(errs, fmt.Errorf("profile %q: %w", name, err))
+		}
+		// A profile is signed either with its own key or a named one.
+		if p[name].Key != "" && p[name].SigningKey != "" {
+			errs = append(errs, fmt.Errorf("profile %q: key and signing_key exclude each other", name))
 		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
 func (p Profiles) collectBranches(all, branches []string) []string {
+	// Cyclic definitions are reported by check. They
+	// must not let an unchecked config recurse forever.
+	seen := map[string]bool{}
 	var collect func(branches []string)
 	collect = func(branches []string) {
 		for _, branch := range branches {
 			if strings.HasPrefix(branch, "#") {
-				collect(p[branch[1:]])
+				if name := branch[1:]; !seen[name] {
+					seen[name] = true
+					collect(p.profileBranches(name))
+				}
 			} else if !slices.Contains(all, branch) {
 				all = append(all, branch)
 			}
@@ -111,21 +282,42 @@ func (p Profiles) collectBranches(all, branches []string) []string {
 	return all
 }
 
+// profileBranches returns the unresolved branches of a given profile.
+func (p Profiles) profileBranches(name string) []string {
+	if profile := p[name]; profile != nil {
+		return profile.Branches
+	}
+	return nil
+}
+
 // Branches returns the branches for a given profile.
 func (p Profiles) Branches(name string) []string {
-	return p.collectBranches(nil, p[name])
+	return p.collectBranches(nil, p.profileBranches(name))
 }
 
 // AllBranches returns a list of all branches which are relevant for the contravider.
 func (p Profiles) AllBranches() []string {
 	var all []string
-	for _, branches := range p {
-		all = p.collectBranches(all, branches)
+	for _, profile := range p {
+		all = p.collectBranches(all, profile.Branches)
 	}
 	slices.Sort(all) // to make it deterimistic.
 	return all
 }
 
+// Tagged returns the sorted names of the profiles with the given tag.
+// An empty tag returns all profiles.
+func (p Profiles) Tagged(tag string) []string {
+	var names []string
+	for name, profile := range p {
+		if tag == "" || slices.Contains(profile.Tags, tag) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
 // DependingProfiles returns the profiles that depend on the given branches.
 func (p Profiles) DependingProfiles(branches []string) []string {
 	var profiles []string
@@ -0,0 +1,65 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// SessionSecretSize is the minimal size in bytes of the
+// secret the session cookies are signed with.
+const SessionSecretSize = 32
+
+// Sessions are the config options of the sessions issued after
+// successful authentications to protected folders.
+type Sessions struct {
+	MaxAge time.Duration `toml:"max_age"`
+	Secret string        `toml:"secret"`
+}
+
+// Enabled checks if sessions are issued.
+func (s *Sessions) Enabled() bool {
+	return s.MaxAge > 0
+}
+
+// presetSecret generates a random secret if sessions are issued
+// without a configured one. The sessions end with the server then.
+func (s *Sessions) presetSecret() {
+	if !s.Enabled() || s.Secret != "" {
+		return
+	}
+	key := make([]byte, SessionSecretSize)
+	rand.Read(key)
+	s.Secret = hex.EncodeToString(key)
+	slog.Warn("web.sessions.secret is not set, " +
+		"using a random one so the sessions do not survive restarts")
+}
+
+// Key returns the hex decoded secret.
+func (s *Sessions) Key() ([]byte, error) {
+	if s.Secret == "" {
+		return nil, errors.New("web.sessions.secret is not set")
+	}
+	key, err := hex.DecodeString(s.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("web.sessions.secret is not hex encoded: %w", err)
+	}
+	if len(key) < SessionSecretSize {
+		return nil, fmt.Errorf("web.sessions.secret needs at least %d bytes, got %d",
+			SessionSecretSize, len(key))
+	}
+	return key, nil
+}
@@ -0,0 +1,139 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TLS modes selecting the certificate of the TLS server.
+const (
+	// TLSModeFiles uses the certificate given by the
+	// cert and key files.
+	TLSModeFiles = ""
+	// TLSModeSelfSigned generates a self-signed certificate.
+	TLSModeSelfSigned = "self-signed"
+	// TLSModeExpired generates an already expired self-signed certificate.
+	TLSModeExpired = "expired"
+)
+
+// TLS are the config options of the TLS server.
+// Empty options resort to the defaults of Go.
+type TLS struct {
+	Mode             string        `toml:"mode"`
+	Validity         time.Duration `toml:"validity"`
+	MinVersion       string        `toml:"min_version"`
+	MaxVersion       string        `toml:"max_version"`
+	CipherSuites     []string      `toml:"cipher_suites"`
+	CurvePreferences []string      `toml:"curve_preferences"`
+	ALPN             []string      `toml:"alpn"`
+	OCSPStaple       string        `toml:"ocsp_staple"`
+	OCSPRefresh      time.Duration `toml:"ocsp_refresh"`
+}
+
+// TLSEnabled checks if the web server serves over TLS.
+func (w *Web) TLSEnabled() bool {
+	return w.TLS.Mode != TLSModeFiles || (w.CertFile != "" && w.KeyFile != "")
+}
+
+// tlsVersions are the known TLS versions.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsCurves are the known curves by their common names.
+var tlsCurves = map[string]tls.CurveID{
+	"X25519":         tls.X25519,
+	"P-256":          tls.CurveP256,
+	"P-384":          tls.CurveP384,
+	"P-521":          tls.CurveP521,
+	"X25519MLKEM768": tls.X25519MLKEM768,
+}
+
+// parseVersion parses a TLS version like "1.2".
+func parseVersion(name, version string) (uint16, error) {
+	if version == "" {
+		return 0, nil
+	}
+	v, ok := tlsVersions[version]
+	if !ok {
+		return 0, fmt.Errorf("web.tls.%s %q is unknown", name, version)
+	}
+	return v, nil
+}
+
+// Config returns a TLS configuration with the configured options.
+func (t *TLS) Config() (*tls.Config, error) {
+	var errs []error
+	minVersion, err := parseVersion("min_version", t.MinVersion)
+	errs = append(errs, err)
+	maxVersion, err := parseVersion("max_version", t.MaxVersion)
+	errs = append(errs, err)
+	if minVersion != 0 && maxVersion != 0 && minVersion > maxVersion {
+		errs = append(errs, fmt.Errorf(
+			"web.tls.min_version %q is greater than web.tls.max_version %q",
+			t.MinVersion, t.MaxVersion))
+	}
+
+	var suites []uint16
+	if len(t.CipherSuites) > 0 {
+		known := map[string]uint16{}
+		for _, s := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+			known[s.Name] = s.ID
+		}
+		for _, name := range t.CipherSuites {
+			id, ok := known[name]
+			if !ok {
+				errs = append(errs, fmt.Errorf("web.tls.cipher_suites: %q is unknown", name))
+				continue
+			}
+			suites = append(suites, id)
+		}
+	}
+
+	var curves []tls.CurveID
+	for _, name := range t.CurvePreferences {
+		id, ok := tlsCurves[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("web.tls.curve_preferences: %q is unknown", name))
+			continue
+		}
+		curves = append(curves, id)
+	}
+
+	seen := map[string]bool{}
+	for _, proto := range t.ALPN {
+		switch {
+		case proto == "" || len(proto) > 255:
+			errs = append(errs, fmt.Errorf("web.tls.alpn: %q has to be 1 to 255 bytes long", proto))
+		case seen[proto]:
+			errs = append(errs, fmt.Errorf("web.tls.alpn: %q is given twice", proto))
+		}
+		seen[proto] = true
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:       minVersion,
+		MaxVersion:       maxVersion,
+		CipherSuites:     suites,
+		CurvePreferences: curves,
+		NextProtos:       t.ALPN,
+	}, nil
+}
@@ -0,0 +1,88 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package config
+
+import (
+	"crypto/tls"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTLSConfig(t *testing.T) {
+	file := writeConfig(t, `
+[web.tls]
+min_version = "1.2"
+max_version = "1.3"
+cipher_suites = ["TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_RSA_WITH_RC4_128_SHA"]
+curve_preferences = ["X25519", "P-256"]
+
+[providers]
+result = "."
+
+[providers.profiles]
+main = ["main"]
+`)
+	cfg, err := Load(file)
+	if err != nil {
+		t.Fatalf("loading failed: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("valid config does not validate: %v", err)
+	}
+	tc, err := cfg.Web.TLS.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.MinVersion != tls.VersionTLS12 || tc.MaxVersion != tls.VersionTLS13 {
+		t.Errorf("got versions %x-%x", tc.MinVersion, tc.MaxVersion)
+	}
+	// Insecure suites can be configured to test clients rejecting them.
+	if want := []uint16{
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_RSA_WITH_RC4_128_SHA,
+	}; !slices.Equal(tc.CipherSuites, want) {
+		t.Errorf("got cipher suites %x, want %x", tc.CipherSuites, want)
+	}
+	if want := []tls.CurveID{tls.X25519, tls.CurveP256}; !slices.Equal(tc.CurvePreferences, want) {
+		t.Errorf("got curves %v, want %v", tc.CurvePreferences, want)
+	}
+
+	file = writeConfig(t, `
+[web.tls]
+min_version = "1.3"
+max_version = "1.2"
+cipher_suites = ["TLS_UNKNOWN"]
+curve_preferences = ["P-128"]
+
+[providers]
+result = "."
+
+[providers.profiles]
+main = ["main"]
+`)
+	if cfg, err = Load(file); err != nil {
+		t.Fatalf("loading failed: %v", err)
+	}
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatal("invalid config validates")
+	}
+	for _, want := range []string{
+		`web.tls.min_version "1.3" is greater than web.tls.max_version "1.2"`,
+		`web.tls.cipher_suites: "TLS_UNKNOWN" is unknown`,
+		`web.tls.curve_preferences: "P-128" is unknown`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing problem %q in:\n%v", want, err)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"mime"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CheckPublicFile checks if a public file is a regular file
+// inside the result directory and usable as a route.
+func CheckPublicFile(result, file string) error {
+	if file == "" || path.Clean("/"+file) != "/"+file || strings.ContainsAny(file, "{} ") {
+		return fmt.Errorf("%q is not a valid relative path", file)
+	}
+	info, err := os.Stat(filepath.Join(result, filepath.FromSlash(file)))
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%q is not a regular file", file)
+	}
+	return nil
+}
+
+// isLoopback checks if a host:port address is a loopback address.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isKeyID checks if id is a hexadecimal key id with an optional 0x prefix.
+func isKeyID(id string) bool {
+	_, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(id), "0x"), 16, 64)
+	return err == nil
+}
+
+// Validate checks the configuration for semantic problems.
+// All found problems are returned joined together.
+func (cfg *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	if cfg.Web.Port < 0 || cfg.Web.Port > 65535 {
+		add("web.port %d is out of range", cfg.Web.Port)
+	}
+	if cfg.Web.MaxConnections < 0 {
+		add("web.max_connections must not be negative")
+	}
+	if cfg.Web.IdempotencyWindow < 0 {
+		add("web.idempotency_window must not be negative, got %s", cfg.Web.IdempotencyWindow)
+	}
+	if cfg.Web.ResponseBudget < 0 {
+		add("web.response_budget must not be negative, got %s", cfg.Web.ResponseBudget)
+	}
+	if cfg.Web.Root == "" {
+		add("web.root must not be empty")
+	}
+	if (cfg.Web.CertFile == "") != (cfg.Web.KeyFile == "") {
+		add("web.cert_file and web.key_file have to be set together")
+	}
+	if (cfg.Web.AdminUser == "") != (cfg.Web.AdminPassword == "") {
+		add("web.admin_user and web.admin_password have to be set together")
+	}
+	if s := cfg.Web.AdminSocket; s != "" && !filepath.IsAbs(s) {
+		add("web.admin_socket %q is not an absolute path", s)
+	}
+	if a := cfg.Web.AdminAddress; a != "" && !isLoopback(a) {
+		add("web.admin_address %q is not a loopback address", a)
+	}
+	if _, err := cfg.Web.TLS.Config(); err != nil {
+		errs = append(errs, err)
+	}
+	switch cfg.Web.TLS.Mode {
+	case TLSModeFiles:
+	case TLSModeSelfSigned, TLSModeExpired:
+		if cfg.Web.CertFile != "" {
+			add("web.tls.mode %q and web.cert_file exclude each other", cfg.Web.TLS.Mode)
+		}
+		if cfg.Web.TLS.Validity <= 0 {
+			add("web.tls.validity has to be positive, got %s", cfg.Web.TLS.Validity)
+		}
+	default:
+		add("web.tls.mode %q is unknown", cfg.Web.TLS.Mode)
+	}
+	if staple := cfg.Web.TLS.OCSPStaple; staple != "" {
+		if !cfg.Web.TLSEnabled() {
+			add("web.tls.ocsp_staple needs a TLS server")
+		}
+		if _, err := os.Stat(staple); err != nil {
+			add("web.tls.ocsp_staple: %w", err)
+		}
+	}
+	if cfg.Web.TLS.OCSPRefresh < 0 {
+		add("web.tls.ocsp_refresh must not be negative, got %s", cfg.Web.TLS.OCSPRefresh)
+	}
+	if cfg.Web.HTTP3 {
+		if !cfg.Web.TLSEnabled() || filepath.IsAbs(cfg.Web.Host) {
+			add("web.http3 needs a TLS server")
+		}
+		if v := cfg.Web.TLS.MaxVersion; v != "" && v != "1.3" {
+			add("web.http3 needs TLS 1.3, web.tls.max_version is %q", v)
+		}
+	}
+	for _, file := range cfg.Web.PublicFiles {
+		if err := CheckPublicFile(cfg.Providers.Result, file); err != nil {
+			errs = append(errs, fmt.Errorf("web.public_files: %w", err))
+		}
+	}
+	if lo := &cfg.Web.AuthLockout; lo.Attempts < 0 {
+		add("web.auth_lockout.attempts must not be negative")
+	} else if lo.Attempts > 0 && (lo.Window <= 0 || lo.Cooldown <= 0) {
+		add("web.auth_lockout.window and web.auth_lockout.cooldown have to be positive")
+	}
+	for _, ext := range slices.Sorted(maps.Keys(cfg.Web.ContentTypes)) {
+		if !strings.HasPrefix(ext, ".") {
+			add("web.content_types: extension %q does not start with a dot", ext)
+		}
+		if _, _, err := mime.ParseMediaType(cfg.Web.ContentTypes[ext]); err != nil {
+			add("web.content_types: invalid content type of %q: %w", ext, err)
+		}
+	}
+	if cfg.Web.Sessions.MaxAge < 0 {
+		add("web.sessions.max_age must not be negative, got %s", cfg.Web.Sessions.MaxAge)
+	}
+	if cfg.Web.Sessions.Secret != "" {
+		if _, err := cfg.Web.Sessions.Key(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if cfg.Signing.Key == "" {
+		add("signing.key must not be empty")
+	}
+	switch cfg.Signing.HashFormat {
+	case HashFormatCoreutils, HashFormatSingleSpace, HashFormatBare:
+	default:
+		add("signing.hash_format %q is unknown", cfg.Signing.HashFormat)
+	}
+	switch cfg.Signing.Mode {
+	case SigningModeDetached, SigningModeClearsign:
+	default:
+		add("signing.mode %q is unknown", cfg.Signing.Mode)
+	}
+	if cfg.Signing.MaxConcurrency < 0 {
+		add("signing.max_concurrency must not be negative, got %d", cfg.Signing.MaxConcurrency)
+	}
+	if cfg.Signing.InMemoryMB < 0 {
+		add("signing.in_memory_mb must not be negative, got %d", cfg.Signing.InMemoryMB)
+	}
+	if cfg.Signing.ExpiryWarning < 0 {
+		add("signing.expiry_warning must not be negative, got %s", cfg.Signing.ExpiryWarning)
+	}
+	if id := cfg.Signing.Subkey; id != "" && !isKeyID(id) {
+		add("signing.subkey %q is not a key id", id)
+	}
+	if strings.ContainsAny(cfg.Signing.ArmorVersion+cfg.Signing.ArmorComment, "\r\n") {
+		add("signing.armor_version and signing.armor_comment must not contain line breaks")
+	}
+	if _, err := cfg.Signing.SignTime(); err != nil {
+		errs = append(errs, err)
+	}
+	names := map[string]bool{}
+	for i, key := range cfg.Signing.Keys {
+		switch {
+		case key.Name == "":
+			add("signing.keys[%d].name must not be empty", i)
+		case names[key.Name]:
+			add("signing.keys[%d].name %q is not unique", i, key.Name)
+		}
+		names[key.Name] = true
+		if key.Key == "" {
+			add("signing.keys[%d].key must not be empty", i)
+		}
+		if key.Subkey != "" && !isKeyID(key.Subkey) {
+			add("signing.keys[%d].subkey %q is not a key id", i, key.Subkey)
+		}
+	}
+	if err := checkHashes(cfg.Signing.Hashes); err != nil {
+		add("signing.hashes: %w", err)
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.Providers.Profiles)) {
+		profile := cfg.Providers.Profiles[name]
+		if err := checkBaseURL(profile.BaseURL); err != nil {
+			add("profile %q: base_url: %w", name, err)
+		}
+		if err := checkTemplateVars(profile.TemplateVars); err != nil {
+			add("profile %q: template_vars: %w", name, err)
+		}
+		if err := checkHashes(profile.Hashes); err != nil {
+			add("profile %q: hashes: %w", name, err)
+		}
+		// Setting both key and signing_key is reported by check.
+		switch {
+		case profile.SigningKey == "" || profile.Key != "":
+		case !names[profile.SigningKey]:
+			add("profile %q: signing key %q is not configured", name, profile.SigningKey)
+		}
+	}
+	if err := checkBaseURL(cfg.Providers.BaseURL); err != nil {
+		add("providers.base_url: %w", err)
+	}
+	if err := checkTemplateVars(cfg.Providers.TemplateVars); err != nil {
+		add("providers.template_vars: %w", err)
+	}
+	if cfg.Providers.GitURL == "" {
+		add("providers.git_url must not be empty")
+	}
+	if strings.ContainsAny(cfg.Providers.GitUsername+cfg.Providers.GitToken, "\r\n:") {
+		add("providers.git_username and providers.git_token must not contain line breaks or colons")
+	}
+	if cfg.Providers.GitAuthor == "" || cfg.Providers.GitEmail == "" {
+		add("providers.git_author and providers.git_email must not be empty")
+	}
+	if cfg.Providers.ShallowDepth < 0 {
+		add("providers.shallow_depth must not be negative, got %d", cfg.Providers.ShallowDepth)
+	}
+	if cfg.Providers.WorkDir == "" {
+		add("providers.workdir must not be empty")
+	}
+	if cfg.Providers.Update <= 0 {
+		add("providers.update has to be positive, got %s", cfg.Providers.Update)
+	}
+	if cfg.Providers.GitCheck <= 0 || cfg.Providers.GitCheckTimeout <= 0 {
+		add("providers.git_check and providers.git_check_timeout have to be positive")
+	}
+	if cfg.Providers.PreviewTTL <= 0 {
+		add("providers.preview_ttl has to be positive, got %s", cfg.Providers.PreviewTTL)
+	}
+	if cfg.Providers.MaxCachedProfiles < 0 {
+		add("providers.max_cached_profiles must not be negative")
+	}
+	if cfg.Providers.KeepExports < 0 {
+		add("providers.keep_exports must not be negative")
+	}
+	if cfg.Providers.MinFreeMB < 0 {
+		add("providers.min_free_mb must not be negative")
+	}
+	if cfg.Providers.PreviewGrace < 0 {
+		add("providers.preview_grace must not be negative, got %s", cfg.Providers.PreviewGrace)
+	}
+	if cfg.Providers.MinRebuildInterval < 0 {
+		add("providers.min_rebuild_interval must not be negative, got %s", cfg.Providers.MinRebuildInterval)
+	}
+	if cfg.Providers.MaxTemplateMB <= 0 {
+		add("providers.max_template_mb has to be positive, got %d", cfg.Providers.MaxTemplateMB)
+	}
+	if cfg.Providers.DeleteGrace < 0 {
+		add("providers.delete_grace must not be negative, got %s", cfg.Providers.DeleteGrace)
+	}
+	if info, err := os.Stat(cfg.Providers.Result); err != nil {
+		add("providers.result: %w", err)
+	} else if !info.IsDir() {
+		add("providers.result %q is not a directory", cfg.Providers.Result)
+	}
+	if len(cfg.Providers.Profiles) == 0 {
+		add("no profiles configured")
+	}
+	if err := cfg.Providers.Profiles.check(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
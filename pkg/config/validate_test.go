@@ -0,0 +1,198 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes a config file into a temporary directory.
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "contravider.toml")
+	if err := os.WriteFile(file, []byte(content), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestValidateHashFormat(t *testing.T) {
+	for _, check := range []struct {
+		format string
+		valid  bool
+	}{
+		{HashFormatCoreutils, true},
+		{HashFormatSingleSpace, true},
+		{HashFormatBare, true},
+		{"sha256sum", false},
+	} {
+		file := writeConfig(t, `
+[signing]
+hash_format = "`+check.format+`"
+
+[providers]
+result = "."
+
+[providers.profiles]
+main = ["main"]
+`)
+		cfg, err := Load(file)
+		if err != nil {
+			t.Fatalf("%s: loading failed: %v", check.format, err)
+		}
+		if err := cfg.Validate(); (err == nil) != check.valid {
+			t.Errorf("%s: got validation error %v", check.format, err)
+		}
+	}
+}
+
+func TestValidateTemplateVars(t *testing.T) {
+	for _, check := range []struct {
+		name string
+		want string
+	}{
+		{"publisher", ""},
+		{"_vendor2", ""},
+		{"BaseURL", "is reserved"},
+		{"Vars", "is reserved"},
+		{"not-an-identifier", "is not an identifier"},
+		{"1st", "is not an identifier"},
+	} {
+		problem := "template variable " + strconv.Quote(check.name) + " " + check.want
+		cfg, err := Load(writeConfig(t, `
+[providers]
+result = "."
+template_vars = { "`+check.name+`" = "global" }
+
+[providers.profiles]
+main = { branches = ["main"], template_vars = { "`+check.name+`" = "profile" } }
+`))
+		if err != nil {
+			t.Fatalf("%s: loading failed: %v", check.name, err)
+		}
+		err = cfg.Validate()
+		if check.want == "" {
+			if err != nil {
+				t.Errorf("%s: valid name does not validate: %v", check.name, err)
+			}
+			continue
+		}
+		for _, prefix := range []string{
+			"providers.template_vars: ",
+			`profile "main": template_vars: `,
+		} {
+			if err == nil || !strings.Contains(err.Error(), prefix+problem) {
+				t.Errorf("%s: got validation error %v, want %q", check.name, err, prefix+problem)
+			}
+		}
+	}
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	file := writeConfig(t, `
+[web]
+port = 70000
+
+[signing]
+hashes = ["md5"]
+
+[providers]
+result = "."
+
+[providers.profiles]
+cyclic = ["#cyclic"]
+undefined = ["main", "#missing"]
+hashes = { branches = ["main"], hashes = ["sha1"] }
+url = { branches = ["main"], base_url = "https://{unknown}/" }
+`)
+	cfg, err := Load(file)
+	if err != nil {
+		t.Fatalf("loading failed: %v", err)
+	}
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatal("invalid config validates")
+	}
+	for _, want := range []string{
+		"web.port 70000 is out of range",
+		`signing.hashes: unknown hash algorithm "md5"`,
+		`profile "cyclic": self recursive definition "cyclic"`,
+		`profile "undefined": undefined defintion "missing"`,
+		`profile "hashes": hashes: unknown hash algorithm "sha1"`,
+		`profile "url": base_url: unknown placeholder`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing problem %q in:\n%v", want, err)
+		}
+	}
+	// The cyclic definition must not hang the branch resolution.
+	if got := cfg.Providers.Profiles.Branches("cyclic"); len(got) != 0 {
+		t.Errorf("cyclic profile has branches %q", got)
+	}
+}
+
+func TestValidateDefault(t *testing.T) {
+	file := writeConfig(t, `
+[providers]
+result = "."
+
+[providers.profiles]
+main = ["main"]
+`)
+	cfg, err := Load(file)
+	if err != nil {
+		t.Fatalf("loading failed: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("valid config does not validate: %v", err)
+	}
+}
+
+func TestValidateProfileKeys(t *testing.T) {
+	file := writeConfig(t, `
+[[signing.keys]]
+name = "named"
+key = "named.asc"
+
+[providers]
+result = "."
+
+[providers.profiles]
+both = { branches = ["main"], key = "own.asc", signing_key = "named" }
+unknown = { branches = ["main"], signing_key = "missing" }
+own = { branches = ["main"], key = "own.asc" }
+named = { branches = ["main"], signing_key = "named" }
+`)
+	cfg, err := Load(file)
+	if err != nil {
+		t.Fatalf("loading failed: %v", err)
+	}
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatal("invalid config validates")
+	}
+	want := []string{
+		`profile "both": key and signing_key exclude each other`,
+		`profile "unknown": signing key "missing" is not configured`,
+	}
+	for _, w := range want {
+		if !strings.Contains(err.Error(), w) {
+			t.Errorf("missing problem %q in:\n%v", w, err)
+		}
+	}
+	if n := strings.Count(err.Error(), "\n") + 1; n != len(want) {
+		t.Errorf("got %d problems, want %d:\n%v", n, len(want), err)
+	}
+}
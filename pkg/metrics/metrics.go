@@ -0,0 +1,111 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+// Package metrics contains the Prometheus metrics of the contravider.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// registry holds all metrics of the contravider.
+var registry = prometheus.NewRegistry()
+
+var (
+	gitDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "contravider",
+		Subsystem: "git",
+		Name:      "operation_duration_seconds",
+		Help:      "Duration of the git operations.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+	gitFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "contravider",
+		Subsystem: "git",
+		Name:      "operation_failures_total",
+		Help:      "Number of failed git operations.",
+	}, []string{"operation"})
+	gitUpdate = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "contravider",
+		Subsystem: "git",
+		Name:      "update_seconds",
+		Help:      "Duration of the updates of the branches.",
+		Buckets:   prometheus.DefBuckets,
+	})
+	buildDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "contravider",
+		Subsystem: "profile",
+		Name:      "build_seconds",
+		Help:      "Duration of the builds of the profiles.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"profile"})
+	buildFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "contravider",
+		Subsystem: "profile",
+		Name:      "build_failures_total",
+		Help:      "Number of failed builds of the profiles.",
+	}, []string{"profile"})
+	profilesServed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "contravider",
+		Subsystem: "profiles",
+		Name:      "served_total",
+		Help:      "Number of the requests of the profiles prepared to be served.",
+	}, []string{"profile"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		gitDuration,
+		gitFailures,
+		gitUpdate,
+		buildDuration,
+		buildFailures,
+		profilesServed,
+	)
+}
+
+// Handler returns an http.Handler serving the metrics.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// ObserveGit records the duration of a git operation
+// and counts it as failed if err is not nil.
+func ObserveGit(operation string, duration time.Duration, err error) {
+	gitDuration.WithLabelValues(operation).Observe(duration.Seconds())
+	if err != nil {
+		gitFailures.WithLabelValues(operation).Inc()
+	}
+}
+
+// ObserveUpdate records the duration of an update of the branches.
+func ObserveUpdate(duration time.Duration) {
+	gitUpdate.Observe(duration.Seconds())
+}
+
+// ObserveBuild records the duration of a build of a profile
+// and counts it as failed if err is not nil.
+func ObserveBuild(profile string, duration time.Duration, err error) {
+	buildDuration.WithLabelValues(profile).Observe(duration.Seconds())
+	if err != nil {
+		buildFailures.WithLabelValues(profile).Inc()
+	}
+}
+
+// ProfileServed counts a profile prepared to be served.
+func ProfileServed(profile string) {
+	profilesServed.WithLabelValues(profile).Inc()
+}
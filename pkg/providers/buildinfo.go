@@ -0,0 +1,61 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// buildInfoFile stores the build information in the root of an export.
+const buildInfoFile = ".buildinfo.json"
+
+// BuildInfo is the build information of an export.
+type BuildInfo struct {
+	Profile string    `json:"profile"`
+	Hash    string    `json:"hash,omitempty"`
+	Created time.Time `json:"created"`
+	// The following are only set for previews.
+	Token   string            `json:"token,omitempty"`
+	Refs    map[string]string `json:"refs,omitempty"`
+	Expires time.Time         `json:"expires,omitzero"`
+}
+
+// Expired checks if a preview is expired at the given time.
+func (bi *BuildInfo) Expired(now time.Time) bool {
+	return !now.Before(bi.Expires)
+}
+
+// writeBuildInfo stores the build information of an export.
+func writeBuildInfo(fname string, bi *BuildInfo) error {
+	f, err := os.Create(fname)
+	if err != nil {
+		return fmt.Errorf("creating build info failed: %w", err)
+	}
+	return errors.Join(json.NewEncoder(f).Encode(bi), f.Close())
+}
+
+// loadBuildInfo loads the build information of an export.
+func loadBuildInfo(fname string) (*BuildInfo, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var bi BuildInfo
+	if err := json.NewDecoder(f).Decode(&bi); err != nil {
+		return nil, fmt.Errorf("loading build info failed: %w", err)
+	}
+	return &bi, nil
+}
@@ -0,0 +1,137 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// instantiated checks if a profile is currently exported.
+func (s *System) instantiated(profile string) bool {
+	info, err := os.Lstat(path.Join(s.cfg.Web.Root, profile))
+	return err == nil && info.Mode()&os.ModeSymlink == os.ModeSymlink
+}
+
+// CurrentExport returns the hash of the current export of a profile.
+// It returns false if the profile is not instantiated.
+func (s *System) CurrentExport(profile string) (string, bool) {
+	dir, err := os.Readlink(path.Join(s.cfg.Web.Root, profile))
+	if err != nil {
+		return "", false
+	}
+	return filepath.Base(dir), true
+}
+
+// removeProfile removes the export of a profile and the symlink to it.
+// If grace is positive the export is only scheduled to be removed
+// after it so that running downloads can finish.
+func (s *System) removeProfile(profile string, grace time.Duration) {
+	link := path.Join(s.cfg.Web.Root, profile)
+	info, err := os.Lstat(link)
+	// Delete only the exported profile with symlinks to them.
+	if err != nil || info.Mode()&os.ModeSymlink != os.ModeSymlink {
+		return
+	}
+	exported, err := filepath.EvalSymlinks(link)
+	if err != nil {
+		slog.Error("evaluating symlink failed", "error", err)
+		return
+	}
+	// Remove the link itself.
+	if err := os.Remove(link); err != nil {
+		slog.Error("removing link to profile failed", "error", err, "branch", profile)
+	}
+	s.removeExport(profile, filepath.Base(exported), grace)
+}
+
+// removeExport removes an export of a profile. If grace is positive
+// the export is only scheduled to be removed after it so that running
+// downloads can finish.
+func (s *System) removeExport(profile, hash string, grace time.Duration) {
+	if grace > 0 {
+		slog.Debug("scheduling removal of export", "profile", profile, "hash", hash)
+		s.retired[hash] = time.Now().Add(grace)
+		return
+	}
+	delete(s.retired, hash)
+	if err := os.RemoveAll(filepath.Join(s.cfg.Web.Root, hash)); err != nil {
+		slog.Error("removing export failed", "error", err, "profile", profile, "hash", hash)
+	}
+}
+
+// removeRetired removes the exports scheduled for removal
+// whose grace period is over. If all is true all of them
+// are removed regardless of their grace periods.
+func (s *System) removeRetired(all bool) {
+	now := time.Now()
+	for hash, due := range s.retired {
+		if !all && now.Before(due) {
+			continue
+		}
+		slog.Debug("removing retired export", "hash", hash)
+		if err := os.RemoveAll(filepath.Join(s.cfg.Web.Root, hash)); err != nil {
+			slog.Error("removing retired export failed", "hash", hash, "error", err)
+			continue
+		}
+		delete(s.retired, hash)
+	}
+}
+
+// Rebuild removes the current export of a profile and builds it again.
+func (s *System) Rebuild(ctx context.Context, profile string) error {
+	if _, ok := s.Profiles()[profile]; !ok {
+		return ErrProfileNotFound
+	}
+	done := make(chan struct{})
+	s.fns <- func(s *System) {
+		defer close(done)
+		slog.Debug("rebuilding profile", "profile", profile)
+		s.removeProfile(profile, 0)
+	}
+	<-done
+	return s.Serve(ctx, profile)
+}
+
+// evictProfiles removes the least recently served profiles if there
+// are more instantiated profiles than configured. The current profile
+// is never evicted. The served times are kept over restarts in the
+// state file of the web root.
+func (s *System) evictProfiles(current string) {
+	limit := s.cfg.Providers.MaxCachedProfiles
+	if limit <= 0 {
+		return
+	}
+	var cached []string
+	for profile := range s.Profiles() {
+		if profile != current && s.instantiated(profile) {
+			cached = append(cached, profile)
+		}
+	}
+	// The current profile occupies one place.
+	if len(cached) < limit {
+		return
+	}
+	slices.SortFunc(cached, func(a, b string) int {
+		return cmp.Or(s.served[a].Compare(s.served[b]), cmp.Compare(a, b))
+	})
+	for _, profile := range cached[:len(cached)-limit+1] {
+		slog.Debug("evicting profile", "profile", profile)
+		s.removeProfile(profile, s.cfg.Providers.DeleteGrace)
+		s.forgetServed(profile)
+	}
+}
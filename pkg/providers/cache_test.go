@@ -0,0 +1,55 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+func TestEvictProfiles(t *testing.T) {
+	origin := testOrigin(t, map[string]map[string]string{
+		"main": {"white/advisory.json": `{}`},
+	})
+	main := []string{"main"}
+	cfg := testConfig(t, origin, testKey(t), config.Profiles{
+		"a": {Branches: main},
+		"b": {Branches: main},
+		"c": {Branches: main},
+	})
+	cfg.Providers.MaxCachedProfiles = 2
+	s := startSystem(t, cfg)
+
+	check := func(want ...string) {
+		t.Helper()
+		var got []string
+		for _, profile := range []string{"a", "b", "c"} {
+			if s.instantiated(profile) {
+				got = append(got, profile)
+			}
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("got instantiated %q, want %q", got, want)
+		}
+	}
+	serve(t, s, "a")
+	serve(t, s, "b")
+	check("a", "b")
+	// Serving a again makes b the least recently served.
+	serve(t, s, "a")
+	serve(t, s, "c")
+	check("a", "c")
+	// The evicted profile is built again when requested.
+	serve(t, s, "b")
+	check("b", "c")
+}
@@ -0,0 +1,61 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+// Check validates the parts of the configuration which cannot be checked
+// without looking at the signing key and the git repository.
+// The signing key only has to be parseable, it is not unlocked.
+// All found problems are returned joined together.
+func Check(cfg *config.Config) error {
+	var errs []error
+	if _, err := readKey(cfg.Signing.Key); err != nil {
+		errs = append(errs, fmt.Errorf("signing key %q: %w", cfg.Signing.Key, err))
+	}
+	for _, nk := range cfg.Signing.Keys {
+		if nk.Key == "" {
+			continue
+		}
+		if _, err := readKey(nk.Key); err != nil {
+			errs = append(errs, fmt.Errorf("signing key %q: %w", nk.Name, err))
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.Providers.Profiles)) {
+		if key := cfg.Providers.Profiles[name].Key; key != "" {
+			if _, err := readKey(key); err != nil {
+				errs = append(errs, fmt.Errorf(
+					"signing key %q of profile %q: %w", key, name, err))
+			}
+		}
+	}
+	git := newGitClient(&cfg.Providers)
+	available, err := git.remoteBranches(context.Background(), cfg.Providers.GitURL, cfg.Providers.WorkDir, newCheckoutOptions(&cfg.Providers))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("git repository %q: %w", git.scrubURL(cfg.Providers.GitURL), err))
+		return errors.Join(errs...)
+	}
+	for _, branch := range cfg.Providers.Profiles.AllBranches() {
+		if !slices.Contains(available, branch) {
+			errs = append(errs, fmt.Errorf(
+				"branch %q is not in git repository %q", branch, git.scrubURL(cfg.Providers.GitURL)))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,73 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import "path/filepath"
+
+// contentCache keeps the contents of extracted files in memory up to
+// a limit so they don't have to be read again for signing and hashing.
+// A nil cache keeps nothing.
+type contentCache struct {
+	// limit is the maximum number of bytes kept.
+	limit int
+	size  int
+	// want reports if the content of a file is needed.
+	want     func(name string) bool
+	contents map[string][]byte
+}
+
+// newContentCache returns a cache keeping the contents of the files
+// the pattern actions are applied to. It returns nil if limit is not
+// positive.
+func newContentCache(limit int, patterns PatternActions) *contentCache {
+	if limit <= 0 {
+		return nil
+	}
+	return &contentCache{
+		limit:    limit,
+		want:     patterns.hasActions,
+		contents: map[string][]byte{},
+	}
+}
+
+// wants reports if the content of a file should be kept.
+func (cc *contentCache) wants(name string) bool {
+	return cc != nil && cc.want(filepath.Base(name))
+}
+
+// store keeps the content of a file if it fits into the limit.
+func (cc *contentCache) store(name string, content []byte) {
+	if cc == nil || cc.size+len(content) > cc.limit {
+		return
+	}
+	cc.contents[name] = content
+	cc.size += len(content)
+}
+
+// rename moves the kept content of a file to a new name.
+func (cc *contentCache) rename(oldName, newName string) {
+	if cc == nil {
+		return
+	}
+	if content, ok := cc.contents[oldName]; ok {
+		delete(cc.contents, oldName)
+		cc.contents[newName] = content
+	}
+}
+
+// load returns the kept content of a file.
+func (cc *contentCache) load(name string) ([]byte, bool) {
+	if cc == nil {
+		return nil, false
+	}
+	content, ok := cc.contents[name]
+	return content, ok
+}
@@ -12,16 +12,21 @@ package providers
 
 import (
 	"archive/tar"
+	"bufio"
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"html/template"
 	"io"
 	"log/slog"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 )
 
 // templateData is a collection of strings which need to
@@ -30,11 +35,48 @@ type templateData struct {
 	BaseURL                     string
 	PublicOpenPGPKeyFingerprint string
 	PublicOpenPGPKeyURL         string
+	// CanonicalURL is the URL of the provider-metadata.json.
+	CanonicalURL string
+	// DistributionURL is the URL of the folder
+	// holding the directory distributions.
+	DistributionURL string
+	// PublisherNamespaceURL is the origin of the base URL.
+	PublisherNamespaceURL string
+	// Vars are the configured template variables.
+	Vars map[string]string
+}
+
+// validate checks that the required fields of the template data
+// are filled in and that the URLs are absolute. Vars is optional.
+// The other URLs are derived from the base URL, so only a broken
+// base URL is reported if it is one.
+func (td *templateData) validate() error {
+	check := func(name, value string, isURL bool) error {
+		if value == "" {
+			return fmt.Errorf("invalid template data: %s is empty", name)
+		}
+		if u, err := url.Parse(value); isURL && (err != nil || u.Scheme == "" || u.Host == "") {
+			return fmt.Errorf("invalid template data: %s %q is no absolute URL", name, value)
+		}
+		return nil
+	}
+	if err := check("BaseURL", td.BaseURL, true); err != nil {
+		return err
+	}
+	return errors.Join(
+		check("PublicOpenPGPKeyFingerprint", td.PublicOpenPGPKeyFingerprint, false),
+		check("PublicOpenPGPKeyURL", td.PublicOpenPGPKeyURL, true),
+		check("CanonicalURL", td.CanonicalURL, true),
+		check("DistributionURL", td.DistributionURL, true),
+		check("PublisherNamespaceURL", td.PublisherNamespaceURL, true))
 }
 
 type (
 	// Action is a function to be applied to files matching a regex.
-	Action func(path string, info os.FileInfo) error
+	// It reads the content of the file from r. Small files are read
+	// only once for all actions, larger ones are streamed from the
+	// disk to every action.
+	Action func(path string, r io.Reader) error
 	// PatternAction describes functions are applied on which regex.
 	PatternAction struct {
 		Pattern *regexp.Regexp
@@ -45,15 +87,28 @@ type (
 )
 
 // templateFromTar deserializes files from a tar stream as templates
-// and instantiate them with the given template data.
+// and instantiate them with the given template data. The progress
+// is reported for each entry read with the number of entries read
+// so far and the total number of entries of the stream.
+// The instantiated files wanted by the cache are kept in it.
+// The files for which verbatim returns true and binary files
+// are copied as they are. Files larger than maxTemplate bytes
+// are streamed into the target directory without reading them
+// into memory and are not instantiated. If canonicalize is true
+// the instantiated JSON files are written in canonical form.
 func templateFromTar(
 	targetDir string,
+	maxTemplate int64,
+	canonicalize bool,
 	data *templateData,
 	directives func([]string, io.Reader) error,
-) func(io.Reader) error {
-	return func(r io.Reader) error {
+	verbatim func([]string) bool,
+	progress func(done, total int),
+	cache *contentCache,
+) func(io.Reader, int) error {
+	return func(r io.Reader, total int) error {
 		tr := tar.NewReader(r)
-		for {
+		for done := 0; ; {
 			hdr, err := tr.Next()
 			if errors.Is(err, io.EOF) {
 				break
@@ -61,6 +116,11 @@ func templateFromTar(
 			if err != nil {
 				return fmt.Errorf("untaring failed: %w", err)
 			}
+			if hdr.Typeflag == tar.TypeXGlobalHeader {
+				continue
+			}
+			done++
+			progress(done, max(done, total))
 			parts := strings.Split(hdr.Name, "/")
 			if len(parts) < 3 || parts[0] != "data" {
 				slog.Debug("ignore tar entry", "name", hdr.Name)
@@ -78,10 +138,26 @@ func templateFromTar(
 					// directives files are not stored in the export.
 					continue
 				}
+				if hdr.Size > maxTemplate {
+					if err := streamFile(name, tr, os.FileMode(hdr.Mode), verbatim(parts[1:])); err != nil {
+						return fmt.Errorf("cannot copy %q: %w", name, err)
+					}
+					continue
+				}
 				content, err := io.ReadAll(tr)
 				if err != nil {
 					return fmt.Errorf("cannot read data of %q: %w", hdr.Name, err)
 				}
+				if verbatim(parts[1:]) || isBinary(content) {
+					slog.Debug("copy verbatim", "path", hdr.Name)
+					if err := os.WriteFile(name, content, os.FileMode(hdr.Mode)); err != nil {
+						return fmt.Errorf("cannot copy %q: %w", name, err)
+					}
+					if cache.wants(name) {
+						cache.store(name, content)
+					}
+					continue
+				}
 				// Parse the template data.
 				tmpl, err := template.New(parts[len(parts)-1]).
 					Delims("$((", "))$").
@@ -89,6 +165,24 @@ func templateFromTar(
 				if err != nil {
 					return fmt.Errorf("parsing %q as template failed: %w", hdr.Name, err)
 				}
+				canonical := canonicalize && path.Ext(name) == ".json"
+				if cache.wants(name) || canonical {
+					var buf bytes.Buffer
+					if err := tmpl.Execute(&buf, data); err != nil {
+						return fmt.Errorf("writing templated data to %q failed: %w", name, err)
+					}
+					content := buf.Bytes()
+					if canonical {
+						content = canonicalJSON(name, content)
+					}
+					if err := os.WriteFile(name, content, os.FileMode(hdr.Mode)); err != nil {
+						return fmt.Errorf("writing templated data to %q failed: %w", name, err)
+					}
+					if cache.wants(name) {
+						cache.store(name, content)
+					}
+					continue
+				}
 				f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.FileMode(hdr.Mode))
 				if err != nil {
 					return fmt.Errorf("cannot create file %q: %w", name, err)
@@ -102,15 +196,117 @@ func templateFromTar(
 				if err := os.MkdirAll(name, os.FileMode(hdr.Mode)); err != nil {
 					return fmt.Errorf("creating directory %q failed: %w", name, err)
 				}
+
+			default:
+				// Symlinks, devices, FIFOs and the like are not exported.
+				slog.Warn("skipping non-regular tar entry",
+					"name", hdr.Name, "type", string(hdr.Typeflag))
 			}
 		}
 		return nil
 	}
 }
 
+// streamFile copies a file too large to be a template. Text files
+// not copied verbatim on purpose are reported as their templates
+// are not filled in.
+func streamFile(name string, r io.Reader, mode os.FileMode, verbatim bool) error {
+	br := bufio.NewReader(r)
+	if !verbatim {
+		if head, _ := br.Peek(8000); !isBinary(head) {
+			slog.Warn("file too large to be a template, copied as it is", "file", name)
+		}
+	}
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, br); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// canonicalJSON returns a JSON document with sorted keys and
+// without insignificant whitespace so that documents only
+// differing in these get the same hashes and signatures. The
+// numbers are kept as they are written. Documents which are
+// no valid JSON, e.g. of negative tests, are returned unchanged.
+func canonicalJSON(name string, content []byte) []byte {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil || dec.More() {
+		slog.Debug("not canonicalizing invalid JSON", "file", name)
+		return content
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return content
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// isBinary checks like git if the content of a file is binary
+// by looking for a NUL byte in its first 8000 bytes.
+func isBinary(content []byte) bool {
+	return bytes.IndexByte(content[:min(len(content), 8000)], 0) != -1
+}
+
+// readBuffers are the buffers the files are read into to be
+// given to the actions. They are reused to save allocations
+// if many small files are processed.
+var readBuffers = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// maxPooledBuffer is the capacity of the largest buffer kept in
+// readBuffers. A few large files must not pin their memory.
+const maxPooledBuffer = 256 << 10
+
+// readFile reads a file into a buffer from the pool.
+// The buffer has to be put back with putReadBuffer after use.
+func readFile(fname string) (*bytes.Buffer, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf := readBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	if _, err := buf.ReadFrom(f); err != nil {
+		putReadBuffer(buf)
+		return nil, err
+	}
+	return buf, nil
+}
+
+// putReadBuffer puts a buffer back into the pool unless it is too large.
+func putReadBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBuffer {
+		readBuffers.Put(buf)
+	}
+}
+
+// hasActions reports if actions are applied to a file with the given name.
+func (pa PatternActions) hasActions(fname string) bool {
+	for _, p := range pa {
+		if p.Pattern.MatchString(fname) {
+			return len(p.Actions) > 0
+		}
+	}
+	return false
+}
+
 // Apply walks recursively over a given directory and
-// applies all matching actions to the files.
-func (pa PatternActions) Apply(inputDir string) error {
+// applies all matching actions to the files. The contents
+// of the files kept in the cache are not read again. Files
+// larger than maxInMemory bytes are not read into memory but
+// streamed to every action.
+func (pa PatternActions) Apply(inputDir string, maxInMemory int64, cache *contentCache) error {
 	return filepath.Walk(
 		inputDir,
 		func(path string, info os.FileInfo, err error,
@@ -125,8 +321,21 @@ func (pa PatternActions) Apply(inputDir string) error {
 			fname := info.Name()
 			for _, p := range pa {
 				if p.Pattern.MatchString(fname) {
+					if len(p.Actions) == 0 {
+						break
+					}
+					data, ok := cache.load(path)
+					if !ok && info.Size() <= maxInMemory {
+						buf, err := readFile(path)
+						if err != nil {
+							return fmt.Errorf("failed to read file: %w", err)
+						}
+						// The actions must not keep the data.
+						defer putReadBuffer(buf)
+						data, ok = buf.Bytes(), true
+					}
 					for _, action := range p.Actions {
-						if err := action(path, info); err != nil {
+						if err := applyAction(action, path, data, ok); err != nil {
 							return fmt.Errorf(
 								"apply pattern %q failed: %w", p.Pattern, err)
 						}
@@ -137,3 +346,17 @@ func (pa PatternActions) Apply(inputDir string) error {
 			return nil
 		})
 }
+
+// applyAction applies an action to a file. If inMemory is
+// false the content is streamed from the file instead of data.
+func applyAction(action Action, path string, data []byte, inMemory bool) error {
+	if inMemory {
+		return action(path, bytes.NewReader(data))
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	defer f.Close()
+	return action(path, f)
+}
@@ -0,0 +1,603 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+// testActions returns the hashing and the signing actions. The
+// signatures are made at the creation time of the key to make
+// them comparable.
+func testActions(t testing.TB, key *crypto.Key) (Action, Action) {
+	t.Helper()
+	signTime := key.GetEntity().PrimaryKey.CreationTime
+	signing, err := encloseSignFile(
+		key, config.SigningModeDetached, false, signTime, armorHeaders{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashing := encloseHashFile(config.HashFormatCoreutils,
+		[]string{config.HashSHA256, config.HashSHA512})
+	return hashing, signing
+}
+
+// jsonActions applies the actions to the JSON files.
+func jsonActions(actions ...Action) PatternActions {
+	return PatternActions{
+		{regexp.MustCompile(`provider-metadata\.json$`), nil},
+		{regexp.MustCompile(`\.json$`), actions},
+	}
+}
+
+// testAdvisories returns n advisories in a folder as slash
+// separated paths with their contents.
+func testAdvisories(n int) map[string]string {
+	files := map[string]string{
+		"white/provider-metadata.json": `{"role":"csaf_provider"}`,
+		"white/README.txt":             "not signed",
+	}
+	for i := range n {
+		files[fmt.Sprintf("white/2025/advisory-%d.json", i)] =
+			fmt.Sprintf(`{"document":{"tracking":{"id":"advisory-%d"}}}`, i)
+	}
+	return files
+}
+
+// readTree returns the contents of the files in dir
+// by their slash separated paths.
+func readTree(t testing.TB, dir string) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		files[filepath.ToSlash(rel)] = string(data)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestApplyOnePass(t *testing.T) {
+	files := testAdvisories(3)
+	hashing, signing := testActions(t, testKey(t))
+
+	one, two := t.TempDir(), t.TempDir()
+	writeFiles(t, one, files)
+	writeFiles(t, two, files)
+	if err := jsonActions(hashing, signing).Apply(one, 1<<20, nil); err != nil {
+		t.Fatal(err)
+	}
+	// Each action in an own walk reading the files again.
+	for _, action := range []Action{hashing, signing} {
+		if err := jsonActions(action).Apply(two, 1<<20, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, want := readTree(t, one), readTree(t, two)
+	if !maps.Equal(got, want) {
+		t.Errorf("one pass wrote %q,\ntwo passes wrote %q",
+			slices.Sorted(maps.Keys(got)), slices.Sorted(maps.Keys(want)))
+	}
+	for _, file := range []string{
+		"white/2025/advisory-0.json.asc",
+		"white/2025/advisory-0.json.sha256",
+		"white/2025/advisory-0.json.sha512",
+	} {
+		if _, ok := got[file]; !ok {
+			t.Errorf("missing %s", file)
+		}
+	}
+	for _, file := range []string{
+		"white/provider-metadata.json.asc",
+		"white/README.txt.sha256",
+	} {
+		if _, ok := got[file]; ok {
+			t.Errorf("unexpected %s", file)
+		}
+	}
+}
+
+func BenchmarkApply(b *testing.B) {
+	files := testAdvisories(500)
+	// Without signing the costs of reading the files dominate.
+	var (
+		sha256 = encloseHashFile(config.HashFormatCoreutils, []string{config.HashSHA256})
+		sha512 = encloseHashFile(config.HashFormatCoreutils, []string{config.HashSHA512})
+	)
+	for _, bench := range []struct {
+		name   string
+		passes []PatternActions
+	}{
+		{"one pass", []PatternActions{jsonActions(sha256, sha512)}},
+		{"two passes", []PatternActions{jsonActions(sha256), jsonActions(sha512)}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			for b.Loop() {
+				b.StopTimer()
+				dir := b.TempDir()
+				writeFiles(b, dir, files)
+				b.StartTimer()
+				for _, pass := range bench.passes {
+					if err := pass.Apply(dir, 1<<20, nil); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestTemplateProgress(t *testing.T) {
+	stream := testTar(t, testAdvisories(3))
+	var entries int
+	for tr := tar.NewReader(bytes.NewReader(stream)); ; entries++ {
+		if _, err := tr.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, total := range []int{entries, 0} {
+		var fractions []float64
+		untar := templateFromTar(
+			t.TempDir(), 1<<20, false,
+			&templateData{BaseURL: "https://example.com"},
+			func([]string, io.Reader) error { return nil },
+			func([]string) bool { return false },
+			func(done, total int) { fractions = append(fractions, float64(done)/float64(total)) },
+			nil)
+		if err := untar(bytes.NewReader(stream), total); err != nil {
+			t.Fatal(err)
+		}
+		if len(fractions) != entries {
+			t.Errorf("total %d: got %d reports, want %d", total, len(fractions), entries)
+			continue
+		}
+		for i, f := range fractions {
+			if f <= 0 || f > 1 || i > 0 && total > 0 && f <= fractions[i-1] {
+				t.Errorf("total %d: got progress %v", total, fractions)
+				break
+			}
+		}
+		if last := fractions[len(fractions)-1]; last != 1 {
+			t.Errorf("total %d: got final progress %v, want 1", total, last)
+		}
+	}
+}
+
+func TestApplyHashesReusedBuffers(t *testing.T) {
+	// A large file is followed by smaller ones so that stale
+	// content in a reused buffer would show up in the hashes.
+	files := map[string]string{
+		"white/0-large.json":  strings.Repeat("large", 10000),
+		"white/1-small.json":  "small",
+		"white/2-medium.json": strings.Repeat("medium", 100),
+		"white/3-empty.json":  "",
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+	hashing := encloseHashFile(config.HashFormatBare,
+		[]string{config.HashSHA256, config.HashSHA512})
+	for range 2 {
+		if err := jsonActions(hashing).Apply(dir, 1<<20, nil); err != nil {
+			t.Fatal(err)
+		}
+		got := readTree(t, dir)
+		for file, content := range files {
+			sum256 := sha256.Sum256([]byte(content))
+			sum512 := sha512.Sum512([]byte(content))
+			for ext, sum := range map[string][]byte{".sha256": sum256[:], ".sha512": sum512[:]} {
+				if want := hex.EncodeToString(sum) + "\n"; got[file+ext] != want {
+					t.Errorf("%s%s: got %q, want %q", file, ext, got[file+ext], want)
+				}
+			}
+		}
+		// Hash again with the buffers already in the pool.
+		for file := range files {
+			for _, ext := range []string{".sha256", ".sha512"} {
+				if err := os.Remove(filepath.Join(dir, filepath.FromSlash(file+ext))); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkReadFile(b *testing.B) {
+	dir := b.TempDir()
+	writeFiles(b, dir, testAdvisories(100))
+	var fnames []string
+	for file := range testAdvisories(100) {
+		fnames = append(fnames, filepath.Join(dir, filepath.FromSlash(file)))
+	}
+	b.Run("os.ReadFile", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			for _, fname := range fnames {
+				if _, err := os.ReadFile(fname); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("pool", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			for _, fname := range fnames {
+				buf, err := readFile(fname)
+				if err != nil {
+					b.Fatal(err)
+				}
+				putReadBuffer(buf)
+			}
+		}
+	})
+}
+
+// testTar returns a tar stream of files below the data folder
+// like the merged branches are fed into templateFromTar.
+func testTar(t testing.TB, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	dirs := map[string]bool{}
+	for _, file := range slices.Sorted(maps.Keys(files)) {
+		name := path.Join("data", file)
+		var parents []string
+		for dir := path.Dir(name); dir != "." && !dirs[dir]; dir = path.Dir(dir) {
+			dirs[dir] = true
+			parents = append(parents, dir)
+		}
+		slices.Reverse(parents)
+		for _, dir := range parents {
+			if err := tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir, Name: dir + "/", Mode: 0o777,
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg, Name: name, Mode: 0o666, Size: int64(len(files[file])),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, files[file]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// extractAndApply extracts a tar stream into dir and signs and hashes
+// the files. The contents are kept in memory up to limit bytes.
+func extractAndApply(
+	t testing.TB,
+	dir string,
+	stream []byte,
+	patterns PatternActions,
+	limit int,
+) *contentCache {
+	t.Helper()
+	cache := newContentCache(limit, patterns)
+	untar := templateFromTar(
+		dir, 1<<20, false,
+		&templateData{BaseURL: "https://example.com"},
+		func([]string, io.Reader) error { return nil },
+		func([]string) bool { return false },
+		func(int, int) {},
+		cache)
+	if err := untar(bytes.NewReader(stream), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := patterns.Apply(dir, 1<<20, cache); err != nil {
+		t.Fatal(err)
+	}
+	return cache
+}
+
+func TestApplyFromMemory(t *testing.T) {
+	files := testAdvisories(5)
+	files["white/templated.json"] = `{"url":"$((.BaseURL))$"}`
+	stream := testTar(t, files)
+	patterns := jsonActions(testActions(t, testKey(t)))
+
+	disk := t.TempDir()
+	if cache := extractAndApply(t, disk, stream, patterns, 0); cache != nil {
+		t.Fatal("cache without limit")
+	}
+	want := readTree(t, disk)
+	if got := want["white/templated.json"]; got != `{"url":"https://example.com"}` {
+		t.Fatalf("template not filled in: %s", got)
+	}
+
+	for _, limit := range []int{1 << 20, 100} {
+		t.Run(fmt.Sprintf("limit %d", limit), func(t *testing.T) {
+			memory := t.TempDir()
+			cache := extractAndApply(t, memory, stream, patterns, limit)
+			if cache.size > limit {
+				t.Errorf("cache holds %d bytes above limit %d", cache.size, limit)
+			}
+			if len(cache.contents) == 0 {
+				t.Error("no contents kept")
+			}
+			// Only the files to sign and hash are kept.
+			for name := range cache.contents {
+				if !patterns.hasActions(filepath.Base(name)) {
+					t.Errorf("unneeded %s kept", name)
+				}
+			}
+			if got := readTree(t, memory); !maps.Equal(got, want) {
+				t.Errorf("from memory %q,\nfrom disk %q",
+					slices.Sorted(maps.Keys(got)), slices.Sorted(maps.Keys(want)))
+			}
+		})
+	}
+}
+
+func TestApplyStreamed(t *testing.T) {
+	files := testAdvisories(5)
+	files["white/large.json"] = `{"document":"` + strings.Repeat("large", 1000) + `"}`
+	key := testKey(t)
+	hashing, _ := testActions(t, key)
+	// The streamed signatures are verified after signing.
+	signing, err := encloseSignFile(key, config.SigningModeDetached, true,
+		key.GetEntity().PrimaryKey.CreationTime, armorHeaders{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var streamed []string
+	record := func(path string, r io.Reader) error {
+		if _, ok := r.(*os.File); ok {
+			streamed = append(streamed, filepath.Base(path))
+		}
+		return nil
+	}
+	patterns := jsonActions(hashing, signing, record)
+
+	memory := t.TempDir()
+	writeFiles(t, memory, files)
+	if err := patterns.Apply(memory, 1<<20, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(streamed) != 0 {
+		t.Fatalf("streamed %q below the limit", streamed)
+	}
+	want := readTree(t, memory)
+
+	// Only the file above the limit is streamed from the disk.
+	disk := t.TempDir()
+	writeFiles(t, disk, files)
+	if err := patterns.Apply(disk, 1000, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(streamed, []string{"large.json"}) {
+		t.Errorf("got streamed %q, want large.json", streamed)
+	}
+	if got := readTree(t, disk); !maps.Equal(got, want) {
+		for file := range want {
+			if got[file] != want[file] {
+				t.Errorf("%s differs when streamed", file)
+			}
+		}
+	}
+}
+
+func TestPutReadBufferCap(t *testing.T) {
+	large := bytes.NewBuffer(make([]byte, 0, 2*maxPooledBuffer))
+	putReadBuffer(large)
+	for range 10 {
+		if buf := readBuffers.Get().(*bytes.Buffer); buf == large {
+			t.Fatal("large buffer is pooled")
+		}
+	}
+}
+
+func BenchmarkApplyFromMemory(b *testing.B) {
+	stream := testTar(b, testAdvisories(500))
+	patterns := jsonActions(encloseHashFile(config.HashFormatCoreutils,
+		[]string{config.HashSHA256, config.HashSHA512}))
+	for _, bench := range []struct {
+		name  string
+		limit int
+	}{
+		{"disk", 0},
+		{"memory", 1 << 20},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			for b.Loop() {
+				extractAndApply(b, b.TempDir(), stream, patterns, bench.limit)
+			}
+		})
+	}
+}
+
+func TestVerbatimDirective(t *testing.T) {
+	const (
+		broken  = `{"sample":"$((broken"}`
+		literal = `{"sample":"$((.BaseURL))$"}`
+	)
+	untar := func(files map[string]string) (string, error) {
+		dir := t.TempDir()
+		var tb DirectoryBuilder
+		err := templateFromTar(
+			dir, 1<<20, false,
+			&templateData{BaseURL: "https://example.com"},
+			tb.addDirectives,
+			tb.verbatim,
+			func(int, int) {},
+			nil)(bytes.NewReader(testTar(t, files)), 0)
+		return dir, err
+	}
+	dir, err := untar(map[string]string{
+		"white/.directives.toml":    `verbatim = ["sample-*.json"]`,
+		"white/sample-broken.json":  broken,
+		"white/sample-literal.json": literal,
+		"white/templated.json":      literal,
+		"white/sub/sample-sub.json": literal,
+	})
+	if err != nil {
+		t.Fatalf("excluded file fails the build: %v", err)
+	}
+	got := readTree(t, dir)
+	for file, want := range map[string]string{
+		"white/sample-broken.json":  broken,
+		"white/sample-literal.json": literal,
+		"white/templated.json":      `{"sample":"https://example.com"}`,
+		// The patterns apply to the folder of the directives only.
+		"white/sub/sample-sub.json": `{"sample":"https://example.com"}`,
+	} {
+		if got[file] != want {
+			t.Errorf("%s: got %q, want %q", file, got[file], want)
+		}
+	}
+	if _, ok := got["white/.directives.toml"]; ok {
+		t.Error("directives are exported")
+	}
+
+	// Without the directive the broken file fails the build.
+	if _, err := untar(map[string]string{"white/sample-broken.json": broken}); err == nil {
+		t.Error("broken template does not fail the build")
+	}
+	if _, err := untar(map[string]string{
+		"white/.directives.toml": `verbatim = ["[invalid"]`,
+	}); err == nil || !strings.Contains(err.Error(), "invalid verbatim pattern") {
+		t.Errorf("invalid pattern: got error %v", err)
+	}
+}
+
+// zeros is an endless stream of zero bytes.
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestStreamLargeFile(t *testing.T) {
+	const size = 100 << 20
+	// The tar stream is generated on the fly to not hold it in memory.
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeDir, Name: "data/white/", Mode: 0o777,
+		})
+		if err == nil {
+			err = tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeReg, Name: "data/white/large.bin", Mode: 0o666, Size: size,
+			})
+		}
+		if err == nil {
+			_, err = io.CopyN(tw, zeros{}, size)
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	dir := t.TempDir()
+	untar := templateFromTar(
+		dir, 1<<20, false,
+		&templateData{},
+		func([]string, io.Reader) error { return nil },
+		func([]string) bool { return false },
+		func(int, int) {},
+		nil)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if err := untar(pr, 0); err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > size/4 {
+		t.Errorf("extracting allocated %d MiB", allocated>>20)
+	}
+	info, err := os.Stat(filepath.Join(dir, "white", "large.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != size {
+		t.Errorf("got %d bytes, want %d", info.Size(), size)
+	}
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	files := map[string]string{
+		"white/a.json": `{"document":{"title":"<a>","tracking":{"id":"x","version":1.0}},"vulnerabilities":[]}`,
+		"white/b.json": "{\n  \"vulnerabilities\": [ ],\n  \"document\": {\n    \"tracking\": " +
+			"{\"version\": 1.0, \"id\": \"x\"},\n    \"title\": \"<a>\"\n  }\n}\n",
+		// Broken documents of negative tests are kept as they are.
+		"white/broken.json":   `{"document": {`,
+		"white/trailing.json": `{"b":1, "a":2} {}`,
+	}
+	dir := t.TempDir()
+	untar := templateFromTar(
+		dir, 1<<20, true,
+		&templateData{},
+		func([]string, io.Reader) error { return nil },
+		func([]string) bool { return false },
+		func(int, int) {},
+		nil)
+	if err := untar(bytes.NewReader(testTar(t, files)), 0); err != nil {
+		t.Fatal(err)
+	}
+	hashing := encloseHashFile(config.HashFormatBare, []string{config.HashSHA256})
+	if err := jsonActions(hashing).Apply(dir, 1<<20, nil); err != nil {
+		t.Fatal(err)
+	}
+	got := readTree(t, dir)
+	want := `{"document":{"title":"<a>","tracking":{"id":"x","version":1.0}},"vulnerabilities":[]}`
+	for _, file := range []string{"white/a.json", "white/b.json"} {
+		if got[file] != want {
+			t.Errorf("%s: got %s, want %s", file, got[file], want)
+		}
+	}
+	if got["white/a.json.sha256"] != got["white/b.json.sha256"] {
+		t.Error("reordered documents have different hashes")
+	}
+	for _, file := range []string{"white/broken.json", "white/trailing.json"} {
+		if got[file] != files[file] {
+			t.Errorf("%s: got %q, want it unchanged", file, got[file])
+		}
+	}
+}
@@ -11,6 +11,7 @@
 package providers
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"crypto/sha512"
 	"errors"
@@ -19,12 +20,20 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/ProtonMail/gopenpgp/v3/armor"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
 	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/csaf-testsuite/contravider/pkg/config"
 )
 
-// prepareKeyRing unlocks and returns a reusable KeyRing for signing.
-func prepareKeyRing(armoredPrivateKeyPath string, passphrase string) (*crypto.Key, error) {
+// readKey reads an armored private key from a file without unlocking it.
+func readKey(armoredPrivateKeyPath string) (*crypto.Key, error) {
 	// read armored private key as bytes from file
 	armoredPrivateKeyByte, err := os.ReadFile(armoredPrivateKeyPath)
 	if err != nil {
@@ -34,6 +43,15 @@ func prepareKeyRing(armoredPrivateKeyPath string, passphrase string) (*crypto.Ke
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse private key: %w", err)
 	}
+	return privateKey, nil
+}
+
+// prepareKeyRing unlocks and returns a reusable KeyRing for signing.
+func prepareKeyRing(armoredPrivateKeyPath string, passphrase string) (*crypto.Key, error) {
+	privateKey, err := readKey(armoredPrivateKeyPath)
+	if err != nil {
+		return nil, err
+	}
 	if passphrase != "" {
 		privateKey, err = privateKey.Unlock([]byte(passphrase))
 		if err != nil {
@@ -43,16 +61,76 @@ func prepareKeyRing(armoredPrivateKeyPath string, passphrase string) (*crypto.Ke
 	return privateKey, nil
 }
 
-// signFileWithKey signs a file using an unlocked key.
-// func signFileWithKey(filePath string, signingKey *crypto.Key) error {
-func signFileWithKey(filePath string, signer crypto.PGPSign) error {
-	// Read content of file to sign
-	fileData, err := os.ReadFile(filePath)
+// parseKeyID parses a hexadecimal key id with an optional 0x prefix.
+func parseKeyID(id string) (uint64, error) {
+	return strconv.ParseUint(strings.TrimPrefix(strings.ToLower(id), "0x"), 16, 64)
+}
+
+// selectSubkey returns a key signing with the subkey of the given id.
+// The other subkeys are left out of it. Without any subkeys the
+// primary key signs so it is selected by its own id. Otherwise a
+// signing subkey is preferred over the primary key.
+func selectSubkey(key *crypto.Key, id string) (*crypto.Key, error) {
+	keyID, err := parseKeyID(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key id %q: %w", id, err)
+	}
+	entity := *key.GetEntity()
+	entity.Subkeys = nil
+	for _, sub := range key.GetEntity().Subkeys {
+		if sub.PublicKey.KeyId == keyID {
+			entity.Subkeys = append(entity.Subkeys, sub)
+		}
+	}
+	if len(entity.Subkeys) == 0 && entity.PrimaryKey.KeyId != keyID {
+		return nil, fmt.Errorf("key %s has no subkey %q", key.GetHexKeyID(), id)
+	}
+	// An expired key cannot sign at all which is reported elsewhere.
+	if signing, ok := entity.SigningKey(time.Now(), nil); ok && signing.PublicKey.KeyId != keyID {
+		return nil, fmt.Errorf("subkey %q of key %s cannot sign", id, key.GetHexKeyID())
+	}
+	return crypto.NewKeyFromEntity(&entity)
+}
+
+// armorHeaders are the optional Version and Comment
+// headers of the armored detached signatures.
+type armorHeaders struct {
+	version string
+	comment string
+}
+
+// armor armors a detached signature with the headers.
+func (ah armorHeaders) armor(signature []byte) ([]byte, error) {
+	return armor.ArmorWithTypeAndCustomHeadersBytes(
+		signature, constants.PGPSignatureHeader, ah.version, ah.comment)
+}
+
+// signReader streams a message into a detached signature.
+func signReader(r io.Reader, signer crypto.PGPSign, encoding int8) ([]byte, error) {
+	var signature bytes.Buffer
+	w, err := signer.SigningWriter(&signature, encoding)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return nil, err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return nil, err
 	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return signature.Bytes(), nil
+}
 
-	armored, err := signer.Sign(fileData, crypto.Armor)
+// signFileWithKey signs the content of a file read from r using an unlocked key.
+func signFileWithKey(filePath string, r io.Reader, signer crypto.PGPSign, headers armorHeaders) error {
+	var armored []byte
+	var err error
+	if headers == (armorHeaders{}) {
+		armored, err = signReader(r, signer, crypto.Armor)
+	} else if armored, err = signReader(r, signer, crypto.Bytes); err == nil {
+		armored, err = headers.armor(armored)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to sign message: %w", err)
 	}
@@ -64,97 +142,236 @@ func signFileWithKey(filePath string, signer crypto.PGPSign) error {
 	return nil
 }
 
-// writeHashtoFile writes a hash to a given file.
-func writeHashtoFile(fname, name string, hash []byte) error {
+// clearsignFileWithKey writes the content of a file read from r
+// clearsigned with an unlocked key next to it. As the clearsigned
+// message contains the content, it is read into memory.
+func clearsignFileWithKey(filePath string, r io.Reader, signer crypto.PGPSign) error {
+	fileData, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read message: %w", err)
+	}
+	signed, err := signer.SignCleartext(fileData)
+	if err != nil {
+		return fmt.Errorf("failed to clearsign message: %w", err)
+	}
+
+	signPath := filePath + ".asc"
+	if err := os.WriteFile(signPath, signed, 0644); err != nil {
+		return fmt.Errorf("failed to write clearsigned message to file: %w", err)
+	}
+	return nil
+}
+
+// writeHashtoFile writes a hash to a given file in the given format.
+func writeHashtoFile(fname, name, format string, hash []byte) error {
 	f, err := os.Create(fname)
 	if err != nil {
 		return fmt.Errorf("failed to write hash to file: %w", err)
 	}
-	fmt.Fprintf(f, "%x %s\n", hash, name)
-	return f.Close()
+	switch format {
+	case config.HashFormatCoreutils:
+		_, err = fmt.Fprintf(f, "%x  %s\n", hash, name)
+	case config.HashFormatBare:
+		_, err = fmt.Fprintf(f, "%x\n", hash)
+	default:
+		_, err = fmt.Fprintf(f, "%x %s\n", hash, name)
+	}
+	return errors.Join(err, f.Close())
 }
 
-// writeFileHashes computes hashes for an existing file and writes them.
-func writeFileHashes(filePath string, writeSha256 bool, writeSha512 bool) error {
-
+// writeFileHashes computes hashes over the content of a file
+// read from r and writes them.
+func writeFileHashes(filePath string, r io.Reader, format string, writeSha256 bool, writeSha512 bool) error {
 	if !writeSha256 && !writeSha512 {
-		// both hashes exist already -> write nothing
 		return nil
 	}
+	name := filepath.Base(filePath)
 
-	f, err := os.Open(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to open %s: %w", filePath, err)
-	}
-	defer f.Close()
-
-	// Prepare hashers
+	// Compute all hashes in one pass over the content.
+	hash256, hash512 := sha256.New(), sha512.New()
 	var hashers []io.Writer
-	s256 := sha256.New()
-	s512 := sha512.New()
-
 	if writeSha256 {
-		hashers = append(hashers, s256)
+		hashers = append(hashers, hash256)
 	}
 	if writeSha512 {
-		hashers = append(hashers, s512)
+		hashers = append(hashers, hash512)
 	}
-
-	// Copy file into the selected hashers.
-	if _, err := io.Copy(io.MultiWriter(hashers...), f); err != nil {
-		return fmt.Errorf("failed to copy file to hashers: %w", err)
+	if _, err := io.Copy(io.MultiWriter(hashers...), r); err != nil {
+		return fmt.Errorf("failed to hash %s: %w", filePath, err)
 	}
 
-	name := filepath.Base(filePath)
-
 	// Write hashes
 	if writeSha256 {
-		if err := writeHashtoFile(filePath+".sha256", name, s256.Sum(nil)); err != nil {
+		if err := writeHashtoFile(filePath+".sha256", name, format, hash256.Sum(nil)); err != nil {
 			return fmt.Errorf("failed to write sha256: %w", err)
 		}
 	}
 	if writeSha512 {
-		if err := writeHashtoFile(filePath+".sha512", name, s512.Sum(nil)); err != nil {
+		if err := writeHashtoFile(filePath+".sha512", name, format, hash512.Sum(nil)); err != nil {
 			return fmt.Errorf("failed to write sha512: %w", err)
 		}
 	}
 	return nil
 }
 
+// verifyDetached verifies the detached signature stored next to
+// a file against the content read from r.
+func verifyDetached(filePath string, r io.Reader, verifier crypto.PGPVerify) error {
+	signature, err := os.ReadFile(filePath + ".asc")
+	if err != nil {
+		return fmt.Errorf("failed to read signature: %w", err)
+	}
+	reader, err := verifier.VerifyingReader(r, bytes.NewReader(signature), crypto.Armor)
+	if err != nil {
+		return fmt.Errorf("failed to verify signature: %w", err)
+	}
+	result, err := reader.DiscardAllAndVerifySignature()
+	if err != nil {
+		return fmt.Errorf("failed to verify signature: %w", err)
+	}
+	if err := result.SignatureError(); err != nil {
+		return fmt.Errorf("signature of %q does not verify: %w", filePath, err)
+	}
+	return nil
+}
+
+// verifyCleartext verifies the clearsigned message stored next to a file.
+func verifyCleartext(filePath string, verifier crypto.PGPVerify) error {
+	signed, err := os.ReadFile(filePath + ".asc")
+	if err != nil {
+		return fmt.Errorf("failed to read clearsigned message: %w", err)
+	}
+	result, err := verifier.VerifyCleartext(signed)
+	if err != nil {
+		return fmt.Errorf("failed to verify clearsigned message: %w", err)
+	}
+	if err := result.SignatureError(); err != nil {
+		return fmt.Errorf("clearsigned message of %q does not verify: %w", filePath, err)
+	}
+	return nil
+}
+
+// newSigner returns a signer creating detached signatures or clearsigned
+// messages. If signTime is not zero the signatures are created at it.
+func newSigner(key *crypto.Key, detached bool, signTime time.Time) (crypto.PGPSign, error) {
+	if !signTime.IsZero() {
+		return newFixedSigner(key, signTime), nil
+	}
+	builder := crypto.PGP().Sign().SigningKey(key)
+	if detached {
+		builder = builder.Detached()
+	}
+	signer, err := builder.New()
+	if err != nil {
+		return nil, fmt.Errorf("building signer failed: %w", err)
+	}
+	return signer, nil
+}
+
 // encloseSignFile creates an action that signs a file with a keyring parameter.
-func encloseSignFile(signingKey *crypto.Key) (Action, error) {
+// If verify is set the freshly written signatures are verified against the
+// public key exported into the profile. Signatures already present are not checked as they
+// may be broken on purpose. If slots is not nil a slot has to be
+// taken from it for signing to limit the concurrent signings.
+// In the clearsign mode the .asc files contain the clearsigned
+// files instead of detached signatures. If signTime is not zero
+// all signatures are created at this time.
+func encloseSignFile(
+	signingKey *crypto.Key,
+	mode string,
+	verify bool,
+	signTime time.Time,
+	headers armorHeaders,
+	slots chan struct{},
+) (Action, error) {
 	pgp := crypto.PGP()
-	signer, err := pgp.Sign().SigningKey(signingKey).Detached().New()
+	clearsign := mode == config.SigningModeClearsign
+	signer, err := newSigner(signingKey, !clearsign, signTime)
 	if err != nil {
-		return nil, fmt.Errorf("building signer failed: %w", err)
+		return nil, err
+	}
+	var verifier crypto.PGPVerify
+	if verify {
+		publicKey, err := exportedPublicKey(signingKey)
+		if err != nil {
+			return nil, err
+		}
+		if verifier, err = pgp.Verify().VerificationKey(publicKey).New(); err != nil {
+			return nil, fmt.Errorf("building verifier failed: %w", err)
+		}
 	}
-	return func(file string, _ os.FileInfo) error {
+	return signFileAction(signer, verifier, clearsign, headers, slots), nil
+}
+
+// signFileAction creates an action that signs a file with a signer.
+// If verifier is not nil the written signatures are verified with it
+// against the file written.
+func signFileAction(
+	signer crypto.PGPSign,
+	verifier crypto.PGPVerify,
+	clearsign bool,
+	headers armorHeaders,
+	slots chan struct{},
+) Action {
+	return func(file string, r io.Reader) error {
 		// the files to be checked and created
 		fileSignature := file + ".asc"
 		// write Signature if it doesn't exist
 		if checkFileNotExists(fileSignature) {
-			if err := signFileWithKey(file, signer); err != nil {
+			if slots != nil {
+				slots <- struct{}{}
+				defer func() { <-slots }()
+			}
+			if clearsign {
+				if err := clearsignFileWithKey(file, r, signer); err != nil {
+					return fmt.Errorf("failed to sign file: %w", err)
+				}
+				if verifier != nil {
+					return verifyCleartext(file, verifier)
+				}
+				return nil
+			}
+			if err := signFileWithKey(file, r, signer, headers); err != nil {
 				return fmt.Errorf("failed to sign file: %w", err)
 			}
+			if verifier != nil {
+				// The content is read again as it may be streamed.
+				f, err := os.Open(file)
+				if err != nil {
+					return fmt.Errorf("failed to read file: %w", err)
+				}
+				defer f.Close()
+				if err := verifyDetached(file, f, verifier); err != nil {
+					return err
+				}
+			}
 		}
 		return nil
-	}, nil
+	}
 }
 
-// hashFile checks whether a file needs to be hashed and then hashes it.
-func hashFile(file string, _ os.FileInfo) error {
-	// the files to be checked and created
-	fileHash256 := file + ".sha256"
-	fileHash512 := file + ".sha512"
+// encloseHashFile creates an action that checks whether a file needs
+// to be hashed and then hashes it writing the hash files in the given format.
+// Only the hash files of the given algorithms are written.
+func encloseHashFile(format string, hashes []string) Action {
+	var (
+		with256 = slices.Contains(hashes, config.HashSHA256)
+		with512 = slices.Contains(hashes, config.HashSHA512)
+	)
+	return func(file string, r io.Reader) error {
+		// the files to be checked and created
+		fileHash256 := file + ".sha256"
+		fileHash512 := file + ".sha512"
 
-	shouldCreate256 := checkFileNotExists(fileHash256)
-	shouldCreate512 := checkFileNotExists(fileHash512)
+		shouldCreate256 := with256 && checkFileNotExists(fileHash256)
+		shouldCreate512 := with512 && checkFileNotExists(fileHash512)
 
-	// write Hashes
-	if err := writeFileHashes(file, shouldCreate256, shouldCreate512); err != nil {
-		return fmt.Errorf("failed to write Hashes: %w", err)
+		// write Hashes
+		if err := writeFileHashes(file, r, format, shouldCreate256, shouldCreate512); err != nil {
+			return fmt.Errorf("failed to write Hashes: %w", err)
+		}
+		return nil
 	}
-	return nil
 }
 
 // checkFileExists returns whether a file does not exist.
@@ -163,11 +380,41 @@ func checkFileNotExists(filePath string) bool {
 	return errors.Is(err, os.ErrNotExist)
 }
 
+// publicKeyMu serializes the exports of the public keys. Serializing
+// a key initializes its signature packets lazily which is not safe
+// for the concurrent builds sharing a key.
+var publicKeyMu sync.Mutex
+
+// armoredPublicKey returns the armored public key of a key.
+func armoredPublicKey(key *crypto.Key) (string, error) {
+	publicKeyMu.Lock()
+	defer publicKeyMu.Unlock()
+	asc, err := key.GetArmoredPublicKey()
+	if err != nil {
+		return "", fmt.Errorf("cannot get public key: %w", err)
+	}
+	return asc, nil
+}
+
+// exportedPublicKey returns the public key as written by writePublicKey
+// so that the signatures are verified against what the clients get.
+func exportedPublicKey(key *crypto.Key) (*crypto.Key, error) {
+	asc, err := armoredPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := crypto.NewKeyFromArmored(asc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse exported public key: %w", err)
+	}
+	return publicKey, nil
+}
+
 // writePublicKey writes the public key into the target directory.
 func writePublicKey(key *crypto.Key, targetDir string) error {
-	asc, err := key.GetArmoredPublicKey()
+	asc, err := armoredPublicKey(key)
 	if err != nil {
-		return fmt.Errorf("cannot get public key: %w", err)
+		return err
 	}
 	hexid := key.GetHexKeyID()
 	path := path.Join(targetDir, hexid+".asc")
@@ -176,3 +423,113 @@ func writePublicKey(key *crypto.Key, targetDir string) error {
 	}
 	return nil
 }
+
+// manifestFile is the name of the manifest listing the hashes of all files.
+const manifestFile = "manifest.txt"
+
+// manifestDirs returns the slash separated paths of the folders having
+// their own manifest. These are the root and the outermost protected
+// folders, so that the files in protected folders are only listed in
+// manifests which are protected themselves.
+func manifestDirs(dir *Directory) []string {
+	dirs := []string{""}
+	var recurse func(*Directory, string)
+	recurse = func(d *Directory, prefix string) {
+		for _, folder := range d.Folders {
+			if folder.Protection != nil {
+				dirs = append(dirs, prefix+folder.Name)
+				continue
+			}
+			recurse(folder, prefix+folder.Name+"/")
+		}
+	}
+	recurse(dir, "")
+	return dirs
+}
+
+// manifestDir returns the folder from manifestDirs listing a file.
+func manifestDir(dirs []string, rel string) string {
+	var longest string
+	for _, dir := range dirs {
+		if dir != "" && strings.HasPrefix(rel, dir+"/") && len(dir) > len(longest) {
+			longest = dir
+		}
+	}
+	return longest
+}
+
+// writeManifest writes manifests with the SHA256 hashes of all files
+// in the target directory and signs them with detached signatures.
+// The root and every outermost protected folder get their own
+// manifest, listing the files relative to them.
+func writeManifest(
+	targetDir string,
+	signingKey *crypto.Key,
+	signTime time.Time,
+	headers armorHeaders,
+) error {
+	dir, err := LoadDirectory(filepath.Join(targetDir, ".directories.json"))
+	if err != nil {
+		return err
+	}
+	dirs := manifestDirs(dir)
+	manifests := map[string]*bytes.Buffer{}
+	for _, d := range dirs {
+		if info, err := os.Stat(filepath.Join(targetDir, filepath.FromSlash(d))); err == nil && info.IsDir() {
+			manifests[d] = new(bytes.Buffer)
+		}
+	}
+	// The hasher is reused for all files.
+	hash := sha256.New()
+	if err := filepath.Walk(targetDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(targetDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == ".directories.json" {
+			return nil
+		}
+		d := manifestDir(dirs, rel)
+		if d != "" {
+			rel = strings.TrimPrefix(rel, d+"/")
+		}
+		switch rel {
+		case manifestFile, manifestFile + ".asc":
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		defer f.Close()
+		hash.Reset()
+		if _, err := io.Copy(hash, f); err != nil {
+			return fmt.Errorf("failed to hash %s: %w", path, err)
+		}
+		fmt.Fprintf(manifests[d], "%x  %s\n", hash.Sum(nil), rel)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("collecting manifest failed: %w", err)
+	}
+	signer, err := newSigner(signingKey, true, signTime)
+	if err != nil {
+		return err
+	}
+	for d, manifest := range manifests {
+		manifestPath := filepath.Join(targetDir, filepath.FromSlash(d), manifestFile)
+		if err := os.WriteFile(manifestPath, manifest.Bytes(), 0644); err != nil {
+			return fmt.Errorf("failed to write manifest: %w", err)
+		}
+		if err := signFileWithKey(manifestPath, bytes.NewReader(manifest.Bytes()), signer, headers); err != nil {
+			return fmt.Errorf("failed to sign manifest: %w", err)
+		}
+	}
+	return nil
+}
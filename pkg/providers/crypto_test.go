@@ -0,0 +1,423 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/ProtonMail/gopenpgp/v3/profile"
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+func TestWriteFileHashes(t *testing.T) {
+	const name = "advisory.json"
+	data := []byte(`{"document":{}}`)
+	sum256 := sha256.Sum256(data)
+	sum512 := sha512.Sum512(data)
+	hex256, hex512 := hex.EncodeToString(sum256[:]), hex.EncodeToString(sum512[:])
+	for _, tc := range []struct {
+		format string
+		sha256 string
+		sha512 string
+		// check is the coreutils tool the files are checked with.
+		check bool
+	}{
+		{config.HashFormatCoreutils, hex256 + "  " + name + "\n", hex512 + "  " + name + "\n", true},
+		{config.HashFormatSingleSpace, hex256 + " " + name + "\n", hex512 + " " + name + "\n", false},
+		{config.HashFormatBare, hex256 + "\n", hex512 + "\n", false},
+	} {
+		t.Run(tc.format, func(t *testing.T) {
+			dir := t.TempDir()
+			fname := filepath.Join(dir, name)
+			if err := os.WriteFile(fname, data, 0o666); err != nil {
+				t.Fatal(err)
+			}
+			if err := writeFileHashes(fname, bytes.NewReader(data), tc.format, true, true); err != nil {
+				t.Fatal(err)
+			}
+			for ext, want := range map[string]string{"sha256": tc.sha256, "sha512": tc.sha512} {
+				got, err := os.ReadFile(fname + "." + ext)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != want {
+					t.Errorf("%s: got %q, want %q", ext, got, want)
+				}
+				if !tc.check {
+					continue
+				}
+				tool := ext + "sum"
+				if _, err := exec.LookPath(tool); err != nil {
+					t.Logf("%s is not installed", tool)
+					continue
+				}
+				cmd := exec.Command(tool, "-c", name+"."+ext)
+				cmd.Dir = dir
+				if output, err := cmd.CombinedOutput(); err != nil {
+					t.Errorf("%s -c failed: %v\n%s", tool, err, output)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyAfterSign(t *testing.T) {
+	key := testKey(t)
+	publicKey, err := key.ToPublic()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := crypto.PGP().Verify().VerificationKey(publicKey).New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fname, data := filepath.Join(t.TempDir(), "advisory.json"), []byte(`{"document":{}}`)
+	if err := os.WriteFile(fname, data, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	sign, err := encloseSignFile(key, config.SigningModeDetached, true, time.Time{}, armorHeaders{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sign(fname, bytes.NewReader(data)); err != nil {
+		t.Fatalf("correctly signed file does not verify: %v", err)
+	}
+	if err := verifyDetached(fname, bytes.NewReader(data), verifier); err != nil {
+		t.Fatalf("signature does not verify: %v", err)
+	}
+	// Replace the signature by the one of other data.
+	other, otherData := filepath.Join(t.TempDir(), "other.json"), []byte(`{"document":{"tampered":true}}`)
+	if err := os.WriteFile(other, otherData, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := sign(other, bytes.NewReader(otherData)); err != nil {
+		t.Fatal(err)
+	}
+	tampered, err := os.ReadFile(other + ".asc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fname+".asc", tampered, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if verifyDetached(fname, bytes.NewReader(data), verifier) == nil {
+		t.Error("tampered signature verifies")
+	}
+	// A broken signature is not checked again when building.
+	if err := sign(fname, bytes.NewReader(data)); err != nil {
+		t.Errorf("existing signature is checked: %v", err)
+	}
+}
+
+// countingSigner counts the concurrent signings of a signer.
+type countingSigner struct {
+	crypto.PGPSign
+	mu      sync.Mutex
+	running int
+	max     int
+}
+
+func (cs *countingSigner) SigningWriter(output crypto.Writer, encoding int8) (crypto.WriteCloser, error) {
+	cs.mu.Lock()
+	cs.running++
+	cs.max = max(cs.max, cs.running)
+	cs.mu.Unlock()
+	defer func() {
+		cs.mu.Lock()
+		cs.running--
+		cs.mu.Unlock()
+	}()
+	// Give the other signings the chance to run at the same time.
+	time.Sleep(5 * time.Millisecond)
+	return cs.PGPSign.SigningWriter(output, encoding)
+}
+
+func TestSigningConcurrencyCap(t *testing.T) {
+	key := testKey(t)
+	for _, limit := range []int{1, 2, 4} {
+		t.Run(strconv.Itoa(limit), func(t *testing.T) {
+			signer, err := newSigner(key, true, time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			cs := &countingSigner{PGPSign: signer}
+			sign := signFileAction(cs, nil, false, armorHeaders{}, make(chan struct{}, limit))
+			dir := t.TempDir()
+			var wg sync.WaitGroup
+			for i := range 4 * limit {
+				wg.Go(func() {
+					fname := filepath.Join(dir, fmt.Sprintf("advisory-%d.json", i))
+					if err := sign(fname, strings.NewReader(`{"document":{}}`)); err != nil {
+						t.Error(err)
+					}
+				})
+			}
+			wg.Wait()
+			if cs.max > limit {
+				t.Errorf("got %d concurrent signings, want at most %d", cs.max, limit)
+			}
+			if cs.running != 0 {
+				t.Errorf("%d signings still running", cs.running)
+			}
+		})
+	}
+}
+
+func TestSelectSubkey(t *testing.T) {
+	key := testKey(t)
+	entity := key.GetEntity()
+	for range 2 {
+		if err := entity.AddSigningSubkey(&packet.Config{Algorithm: packet.PubKeyAlgoEd25519}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key, err := crypto.NewKeyFromEntity(entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		primary    = entity.PrimaryKey.KeyId
+		encryption = entity.Subkeys[0].PublicKey.KeyId
+		first      = entity.Subkeys[1].PublicKey.KeyId
+		second     = entity.Subkeys[2].PublicKey.KeyId
+	)
+	verifier, err := crypto.PGP().Verify().VerificationKey(key).New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// signedBy returns the id of the key which made the signature.
+	signedBy := func(signingKey *crypto.Key) uint64 {
+		t.Helper()
+		signer, err := newSigner(signingKey, true, time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := []byte(`{"document":{}}`)
+		signature, err := signer.Sign(data, crypto.Armor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := verifier.VerifyDetached(data, signature, crypto.Armor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := result.SignatureError(); err != nil {
+			t.Fatalf("signature does not verify with the full key: %v", err)
+		}
+		return result.SignedByKeyId()
+	}
+	// Without a selection a signing subkey is preferred.
+	if id := signedBy(key); id == primary || id == encryption {
+		t.Errorf("key signs with %x instead of a signing subkey", id)
+	}
+	for _, id := range []uint64{first, second, primary} {
+		for _, format := range []string{"%X", "0x%x"} {
+			selected, err := selectSubkey(key, fmt.Sprintf(format, id))
+			if err != nil {
+				t.Fatalf("selecting %x: %v", id, err)
+			}
+			if got := signedBy(selected); got != id {
+				t.Errorf("selected %x signs with %x", id, got)
+			}
+		}
+	}
+	for _, check := range []struct {
+		id   string
+		want string
+	}{
+		{fmt.Sprintf("%x", encryption), "cannot sign"},
+		{"0123456789abcdef", "has no subkey"},
+		{"no-id", "invalid key id"},
+	} {
+		if _, err := selectSubkey(key, check.id); err == nil || !strings.Contains(err.Error(), check.want) {
+			t.Errorf("%s: got error %v, want %q", check.id, err, check.want)
+		}
+	}
+}
+
+func TestArmorHeaders(t *testing.T) {
+	key := testKey(t)
+	signer, err := newSigner(key, true, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := crypto.PGP().Verify().VerificationKey(key).New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(`{"document":{}}`)
+	for _, check := range []struct {
+		headers armorHeaders
+		want    []string
+	}{
+		{armorHeaders{}, nil},
+		{armorHeaders{comment: "contravider test"}, []string{"Comment: contravider test"}},
+		{armorHeaders{version: "Strict 1.0"}, []string{"Version: Strict 1.0"}},
+		{armorHeaders{version: "Strict 1.0", comment: "both"}, []string{"Comment: both", "Version: Strict 1.0"}},
+	} {
+		fname := filepath.Join(t.TempDir(), "advisory.json")
+		if err := signFileWithKey(fname, bytes.NewReader(data), signer, check.headers); err != nil {
+			t.Fatal(err)
+		}
+		armored, err := os.ReadFile(fname + ".asc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The headers follow the armor line up to the first empty line.
+		head, _, _ := strings.Cut(string(armored), "\n\n")
+		lines := strings.Split(head, "\n")
+		if lines[0] != "-----BEGIN PGP SIGNATURE-----" {
+			t.Fatalf("%+v: no armored signature:\n%s", check.headers, armored)
+		}
+		if got := lines[1:]; !slices.Equal(got, check.want) {
+			t.Errorf("%+v: got headers %q, want %q", check.headers, got, check.want)
+		}
+		if err := verifyDetached(fname, bytes.NewReader(data), verifier); err != nil {
+			t.Errorf("%+v: %v", check.headers, err)
+		}
+	}
+}
+
+func TestKeyAlgorithms(t *testing.T) {
+	ecdsa := profile.Default()
+	ecdsa.SetKeyAlgorithm = func(cfg *packet.Config, _ int8) {
+		cfg.Algorithm = packet.PubKeyAlgoECDSA
+		cfg.Curve = packet.CurveNistP256
+	}
+	origin := testOrigin(t, map[string]map[string]string{
+		"main": {
+			"white/advisory.json": `{"document":{}}`,
+			"white/key.json": `{"fingerprint":"$((.PublicOpenPGPKeyFingerprint))$",` +
+				`"url":"$((.PublicOpenPGPKeyURL))$"}`,
+		},
+	})
+	for _, check := range []struct {
+		name      string
+		profile   *profile.Custom
+		algorithm string
+	}{
+		{"rsa", profile.RFC4880(), "RSA 3072"},
+		{"eddsa", profile.Default(), "EdDSA Curve25519"},
+		{"ed25519", profile.RFC9580(), "Ed25519"},
+		{"ecdsa", ecdsa, "ECDSA P256"},
+	} {
+		t.Run(check.name, func(t *testing.T) {
+			key, err := crypto.PGPWithProfile(check.profile).KeyGeneration().
+				AddUserId("contravider", "test@example.com").
+				New().
+				GenerateKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := keyAlgorithm(key, time.Now()); got != check.algorithm {
+				t.Errorf("got algorithm %q, want %q", got, check.algorithm)
+			}
+			s := startSystem(t, testConfig(t, origin, key, config.Profiles{
+				"main": {Branches: []string{"main"}},
+			}))
+			export := serve(t, s, "main")
+			if !verifies(t, filepath.Join(export, "white", "advisory.json"), key) {
+				t.Error("signature does not verify")
+			}
+			// The exported public key is the one signing.
+			armored, err := os.ReadFile(filepath.Join(export, key.GetHexKeyID()+".asc"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			public, err := crypto.NewKeyFromArmored(string(armored))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if public.IsPrivate() || public.GetFingerprint() != key.GetFingerprint() {
+				t.Errorf("exported key %s is not the public signing key", public.GetFingerprint())
+			}
+			data, err := os.ReadFile(filepath.Join(export, "white", "key.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var advertised struct {
+				Fingerprint string `json:"fingerprint"`
+				URL         string `json:"url"`
+			}
+			if err := json.Unmarshal(data, &advertised); err != nil {
+				t.Fatal(err)
+			}
+			if advertised.Fingerprint != key.GetFingerprint() {
+				t.Errorf("got fingerprint %q, want %q", advertised.Fingerprint, key.GetFingerprint())
+			}
+			if !strings.HasSuffix(advertised.URL, "/"+key.GetHexKeyID()+".asc") {
+				t.Errorf("got key URL %q", advertised.URL)
+			}
+		})
+	}
+}
+
+func TestWriteManifest(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"white/a.json":     "a",
+		"amber/b.json":     "b",
+		"amber/sub/c.json": "c",
+	})
+	writeProtected(t, dir, "amber")
+	key := testKey(t)
+	if err := writeManifest(dir, key, time.Time{}, armorHeaders{}); err != nil {
+		t.Fatal(err)
+	}
+	read := func(file string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(file)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	root := read(manifestFile)
+	if !strings.Contains(root, "  white/a.json\n") {
+		t.Errorf("root manifest misses public file:\n%s", root)
+	}
+	if strings.Contains(root, "amber/") {
+		t.Errorf("root manifest lists protected files:\n%s", root)
+	}
+	amber := read("amber/" + manifestFile)
+	for _, file := range []string{"b.json", "sub/c.json"} {
+		if !strings.Contains(amber, "  "+file+"\n") {
+			t.Errorf("protected manifest misses %q:\n%s", file, amber)
+		}
+	}
+	if strings.Contains(amber, "white/") {
+		t.Errorf("protected manifest lists public files:\n%s", amber)
+	}
+	if err := verifyManifests(dir, key); err != nil {
+		t.Fatalf("verifying manifests failed: %v", err)
+	}
+	// A change in a protected folder is detected by its manifest.
+	writeFiles(t, dir, map[string]string{"amber/b.json": "changed"})
+	if err := verifyManifests(dir, key); err == nil {
+		t.Fatal("changed protected file not detected")
+	}
+}
@@ -0,0 +1,128 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// storeDir is the directory in the web root holding the
+// files shared by the exports by their contents.
+const storeDir = ".store"
+
+// storeName returns the name of a file in the store. The mode
+// is part of it as all hardlinks of a file share it.
+func storeName(fname string, mode fs.FileMode) (string, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%o", hex.EncodeToString(h.Sum(nil)), mode.Perm()), nil
+}
+
+// dedup replaces the files of an export by hardlinks to files
+// with the same contents in the store. Files not in the store
+// yet are added to it. If a file cannot be linked, e.g. because
+// the store is on another file system, the copy is kept.
+func (s *System) dedup(targetDir string) error {
+	store := filepath.Join(s.cfg.Web.Root, storeDir)
+	if err := os.MkdirAll(store, 0777); err != nil {
+		return fmt.Errorf("creating store failed: %w", err)
+	}
+	var linked, kept int
+	if err := filepath.WalkDir(targetDir, func(fname string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		name, err := storeName(fname, info.Mode())
+		if err != nil {
+			return fmt.Errorf("hashing %q failed: %w", fname, err)
+		}
+		shared := filepath.Join(store, name)
+		// The first file with these contents goes into the store.
+		switch err := os.Link(fname, shared); {
+		case err == nil:
+			return nil
+		case !errors.Is(err, fs.ErrExist):
+			slog.Debug("cannot add file to store", "file", fname, "error", err)
+			kept++
+			return nil
+		}
+		tmp := fname + ".dedup"
+		if err := os.Link(shared, tmp); err != nil {
+			slog.Debug("cannot link file from store", "file", fname, "error", err)
+			kept++
+			return nil
+		}
+		if err := os.Rename(tmp, fname); err != nil {
+			os.Remove(tmp)
+			return fmt.Errorf("replacing %q by link failed: %w", fname, err)
+		}
+		linked++
+		return nil
+	}); err != nil {
+		return err
+	}
+	slog.Debug("deduplicated export", "dir", targetDir, "linked", linked, "kept", kept)
+	return nil
+}
+
+// gcStore removes the files of the store
+// which are not linked by any export anymore.
+func (s *System) gcStore() {
+	store := filepath.Join(s.cfg.Web.Root, storeDir)
+	entries, err := os.ReadDir(store)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Error("reading store failed", "error", err)
+		}
+		return
+	}
+	var removed int
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if n, ok := linkCount(info); !ok || n > 1 {
+			continue
+		}
+		if s.cfg.Providers.GCDryRun {
+			slog.Info("unused stored file", "name", entry.Name())
+			continue
+		}
+		if err := os.Remove(filepath.Join(store, entry.Name())); err != nil {
+			slog.Error("removing stored file failed", "name", entry.Name(), "error", err)
+			continue
+		}
+		removed++
+	}
+	if removed > 0 {
+		slog.Debug("collected unused stored files", "count", removed)
+	}
+}
@@ -0,0 +1,81 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+func TestDedupSharesInodes(t *testing.T) {
+	origin := testOrigin(t, map[string]map[string]string{
+		"main":  {"white/advisory.json": `{"document":{}}`},
+		"extra": {"white/extra.json": `{"document":{"extra":true}}`},
+	})
+	key := testKey(t)
+	cfg := testConfig(t, origin, key, config.Profiles{
+		"a": {Branches: []string{"main"}},
+		"b": {Branches: []string{"main", "extra"}},
+	})
+	cfg.Providers.Dedup = true
+	// Signatures over the same contents are only identical at fixed times.
+	cfg.Signing.FixedTimestamp = key.GetEntity().PrimaryKey.CreationTime.UTC().Format(time.RFC3339)
+	s := startSystem(t, cfg)
+	a, b := serve(t, s, "a"), serve(t, s, "b")
+	if a == b {
+		t.Fatal("profiles share their export")
+	}
+	stat := func(fname string) os.FileInfo {
+		t.Helper()
+		info, err := os.Stat(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+	for _, file := range []string{
+		"white/advisory.json",
+		"white/advisory.json.asc",
+		"white/advisory.json.sha256",
+		"white/advisory.json.sha512",
+	} {
+		file = filepath.FromSlash(file)
+		if !os.SameFile(stat(filepath.Join(a, file)), stat(filepath.Join(b, file))) {
+			t.Errorf("%s is not shared", file)
+		}
+	}
+	if os.SameFile(stat(filepath.Join(a, "white", "advisory.json")), stat(filepath.Join(b, "white", "extra.json"))) {
+		t.Error("files with different contents are shared")
+	}
+
+	// Files which cannot be linked are kept as they are.
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"kept.json": "kept"})
+	kept := filepath.Join(dir, "kept.json")
+	name, err := storeName(kept, stat(kept).Mode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A directory in the way of the stored file cannot be linked to.
+	if err := os.Mkdir(filepath.Join(cfg.Web.Root, storeDir, name), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.dedup(dir); err != nil {
+		t.Fatalf("unlinkable file fails: %v", err)
+	}
+	if data, err := os.ReadFile(kept); err != nil || string(data) != "kept" {
+		t.Errorf("unlinkable file not kept: %q %v", data, err)
+	}
+}
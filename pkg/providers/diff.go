@@ -0,0 +1,187 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ErrExportNotFound is returned if an export to compare does not exist.
+var ErrExportNotFound = errors.New("export not found")
+
+// hashRe matches the names of the export directories.
+var hashRe = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// Diff are the differences between two exports.
+type Diff struct {
+	A       string   `json:"a"`
+	B       string   `json:"b"`
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+}
+
+// Diff compares two exports. An export is given either as the name
+// of a profile for its current export or as "profile@hash" for an
+// export kept from before.
+func (s *System) Diff(ctx context.Context, a, b string) (*Diff, error) {
+	// Current exports may have to be built first.
+	for _, ref := range []string{a, b} {
+		if profile, hash, _ := strings.Cut(ref, "@"); hash == "" {
+			if err := s.Serve(ctx, profile); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// Only resolve the exports in the fns loop. Hashing them
+	// there would block all other requests meanwhile.
+	type answer struct {
+		dirA, refA string
+		dirB, refB string
+		err        error
+	}
+	result := make(chan answer)
+	s.fns <- func(s *System) {
+		var ans answer
+		if ans.dirA, ans.refA, ans.err = s.resolveExport(a); ans.err == nil {
+			ans.dirB, ans.refB, ans.err = s.resolveExport(b)
+		}
+		result <- ans
+	}
+	ans := <-result
+	if ans.err != nil {
+		return nil, ans.err
+	}
+	return diffExports(ans.dirA, ans.refA, ans.dirB, ans.refB)
+}
+
+// diffExports compares the files of two export directories.
+func diffExports(dirA, refA, dirB, refB string) (*Diff, error) {
+	hashesA, err := hashTree(dirA)
+	if err != nil {
+		return nil, err
+	}
+	hashesB, err := hashTree(dirB)
+	if err != nil {
+		return nil, err
+	}
+	diff := &Diff{
+		A:       refA,
+		B:       refB,
+		Added:   []string{},
+		Removed: []string{},
+		Changed: []string{},
+	}
+	for file, hashA := range hashesA {
+		switch hashB, ok := hashesB[file]; {
+		case !ok:
+			diff.Removed = append(diff.Removed, file)
+		case hashA != hashB && !resigned(file, hashesA, hashesB):
+			diff.Changed = append(diff.Changed, file)
+		}
+	}
+	for file := range hashesB {
+		if _, ok := hashesA[file]; !ok {
+			diff.Added = append(diff.Added, file)
+		}
+	}
+	slices.Sort(diff.Added)
+	slices.Sort(diff.Removed)
+	slices.Sort(diff.Changed)
+	return diff, nil
+}
+
+// resigned checks if a file is only a new signature of an unchanged
+// file. Signatures carry their creation time so they differ in every build.
+func resigned(file string, hashesA, hashesB map[string]string) bool {
+	signed, ok := strings.CutSuffix(file, ".asc")
+	if !ok {
+		return false
+	}
+	hashA, okA := hashesA[signed]
+	hashB, okB := hashesB[signed]
+	return okA && okB && hashA == hashB
+}
+
+// resolveExport returns the directory of an export and its
+// reference in the "profile@hash" form.
+func (s *System) resolveExport(ref string) (string, string, error) {
+	profile, hash, _ := strings.Cut(ref, "@")
+	if _, ok := s.Profiles()[profile]; !ok {
+		return "", "", fmt.Errorf("%w: %q", ErrProfileNotFound, profile)
+	}
+	if hash == "" {
+		dir, err := filepath.EvalSymlinks(filepath.Join(s.cfg.Web.Root, profile))
+		if err != nil {
+			return "", "", fmt.Errorf("%w: %q", ErrExportNotFound, ref)
+		}
+		return dir, profile + "@" + filepath.Base(dir), nil
+	}
+	if !hashRe.MatchString(hash) {
+		return "", "", fmt.Errorf("%w: %q", ErrExportNotFound, ref)
+	}
+	dir := filepath.Join(s.cfg.Web.Root, hash)
+	if bi, err := loadBuildInfo(filepath.Join(dir, buildInfoFile)); err != nil || bi.Profile != profile {
+		return "", "", fmt.Errorf("%w: %q", ErrExportNotFound, ref)
+	}
+	return dir, ref, nil
+}
+
+// hashTree returns the SHA256 hashes of the files of an export.
+// The files in protected folders are left out.
+func hashTree(root string) (map[string]string, error) {
+	dir, err := LoadDirectory(filepath.Join(root, ".directories.json"))
+	if err != nil {
+		return nil, err
+	}
+	hashes := map[string]string{}
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		parts := strings.Split(rel, "/")
+		if rel == buildInfoFile || rel == ".directories.json" ||
+			dir.FindProtection(parts[:len(parts)-1]) != nil {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		hashes[rel] = hex.EncodeToString(h.Sum(nil))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("hashing export %q failed: %w", root, err)
+	}
+	return hashes, nil
+}
@@ -0,0 +1,76 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeProtected protects the given folder of an export.
+func writeProtected(t *testing.T, dir, folder string) {
+	t.Helper()
+	d := Directory{Folders: []*Directory{{
+		Name: folder,
+		Protection: &Protection{
+			Users: []Credentials{{User: "user", Password: "secret"}},
+		},
+	}}}
+	data, err := json.Marshal(&d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".directories.json"), data, 0o666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiffExports(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	writeFiles(t, dirA, map[string]string{
+		"same.json":       "same",
+		"same.json.asc":   "signature A",
+		"changed.json":    "old",
+		"removed.json":    "removed",
+		"amber/tlp.json":  "old secret",
+		"amber/gone.json": "gone",
+	})
+	writeFiles(t, dirB, map[string]string{
+		"same.json":      "same",
+		"same.json.asc":  "signature B",
+		"changed.json":   "new",
+		"added.json":     "added",
+		"amber/tlp.json": "new secret",
+		"amber/new.json": "new",
+	})
+	writeProtected(t, dirA, "amber")
+	writeProtected(t, dirB, "amber")
+
+	diff, err := diffExports(dirA, "a", dirB, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, check := range []struct {
+		name      string
+		got, want []string
+	}{
+		{"added", diff.Added, []string{"added.json"}},
+		{"removed", diff.Removed, []string{"removed.json"}},
+		{"changed", diff.Changed, []string{"changed.json"}},
+	} {
+		if !slices.Equal(check.got, check.want) {
+			t.Errorf("%s: got %q, want %q", check.name, check.got, check.want)
+		}
+	}
+}
@@ -11,11 +11,13 @@
 package providers
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"slices"
 	"strings"
 
@@ -23,14 +25,26 @@ import (
 )
 
 type (
-	// Protection are the user credentials og a folder.
-	Protection struct {
+	// Credentials are a user and its password.
+	Credentials struct {
 		User     string `toml:"user" json:"user"`
 		Password string `toml:"password" json:"password"`
 	}
+	// Protection are the user credentials og a folder.
+	// User and Password are the older form of a single
+	// pair and are folded into Users.
+	Protection struct {
+		User     string        `toml:"user" json:"user,omitempty"`
+		Password string        `toml:"password" json:"password,omitempty"`
+		Users    []Credentials `toml:"users" json:"users,omitempty"`
+	}
 	// Directives are the directives applied to a folder.
 	Directives struct {
 		Protection *Protection `toml:"protection"`
+		Aliases    []string    `toml:"aliases"`
+		ShortBody  []string    `toml:"short_body"`
+		Chunked    []string    `toml:"chunked"`
+		Verbatim   []string    `toml:"verbatim"`
 	}
 )
 
@@ -40,6 +54,10 @@ type (
 		Name       string       `json:"name"`
 		Folders    []*Directory `json:"folders,omitempty"`
 		Protection *Protection  `json:"protection,omitempty"`
+		Aliases    []string     `json:"aliases,omitempty"`
+		ShortBody  []string     `json:"short_body,omitempty"`
+		Chunked    []string     `json:"chunked,omitempty"`
+		Verbatim   []string     `json:"verbatim,omitempty"`
 	}
 )
 
@@ -49,19 +67,26 @@ type DirectoryBuilder struct {
 }
 
 // addDirectives adds directives to the virtual tree.
-func (tb *DirectoryBuilder) addDirectives(path []string, r io.Reader) error {
+func (tb *DirectoryBuilder) addDirectives(parts []string, r io.Reader) error {
 	var d Directives
 	if _, err := toml.NewDecoder(r).Decode(&d); err != nil {
 		return fmt.Errorf(
 			"parsing directives %q failed: %w",
-			strings.Join(path, "/"), err)
+			strings.Join(parts, "/"), err)
+	}
+	for _, pattern := range d.Verbatim {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf(
+				"invalid verbatim pattern %q in %q: %w",
+				pattern, strings.Join(parts, "/"), err)
+		}
 	}
 	curr := tb.root
 	if curr == nil {
 		curr = &Directory{}
 		tb.root = curr
 	}
-	for _, part := range path[:len(path)-1] {
+	for _, part := range parts[:len(parts)-1] {
 		if idx := slices.IndexFunc(curr.Folders, func(f *Directory) bool {
 			return f.Name == part
 		}); idx == -1 {
@@ -72,10 +97,24 @@ func (tb *DirectoryBuilder) addDirectives(path []string, r io.Reader) error {
 			curr = curr.Folders[idx]
 		}
 	}
+	d.Protection.fold()
 	curr.Protection = d.Protection
+	curr.Aliases = d.Aliases
+	curr.ShortBody = d.ShortBody
+	curr.Chunked = d.Chunked
+	curr.Verbatim = d.Verbatim
 	return nil
 }
 
+// verbatim checks if a file is to be copied without templating.
+func (tb *DirectoryBuilder) verbatim(parts []string) bool {
+	if tb.root == nil {
+		return false
+	}
+	dir := tb.root.Find(parts[:len(parts)-1])
+	return dir != nil && dir.IsVerbatim(parts[len(parts)-1])
+}
+
 // Directories returns the root node od the directory tree.
 func (tb *DirectoryBuilder) Directories() *Directory {
 	return tb.root
@@ -104,9 +143,20 @@ func LoadDirectory(path string) (*Directory, error) {
 	if err := json.NewDecoder(f).Decode(&dir); err != nil {
 		return nil, fmt.Errorf("loading directory failed: %w", err)
 	}
+	// Directories written before are in the older form.
+	dir.fold()
 	return &dir, nil
 }
 
+// fold folds the older form of the protections of
+// the tree into their lists of credentials.
+func (d *Directory) fold() {
+	d.Protection.fold()
+	for _, folder := range d.Folders {
+		folder.fold()
+	}
+}
+
 // FindProtection traverses the given path and returns the first
 // directory with a valid protection.
 func (d *Directory) FindProtection(path []string) *Protection {
@@ -129,7 +179,65 @@ func (d *Directory) FindProtection(path []string) *Protection {
 	return nil
 }
 
-// Validate checks if user and password match the configured ones.
+// Find traverses the given path and returns the directory at its end.
+// Returns nil if there is no such directory in the tree.
+func (d *Directory) Find(path []string) *Directory {
+	for _, part := range path {
+		if part == "" {
+			continue
+		}
+		idx := slices.IndexFunc(d.Folders, func(f *Directory) bool {
+			return f.Name == part
+		})
+		if idx == -1 {
+			return nil
+		}
+		d = d.Folders[idx]
+	}
+	return d
+}
+
+// Alias returns the file the given extensionless name is an alias for.
+func (d *Directory) Alias(name string) (string, bool) {
+	for _, file := range d.Aliases {
+		if file != name && strings.TrimSuffix(file, path.Ext(file)) == name {
+			return file, true
+		}
+	}
+	return "", false
+}
+
+// IsVerbatim checks if a file in this folder matches
+// one of the patterns of the files not to be templated.
+func (d *Directory) IsVerbatim(name string) bool {
+	return slices.ContainsFunc(d.Verbatim, func(pattern string) bool {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	})
+}
+
+// fold moves the single user and password into the list of credentials.
+func (p *Protection) fold() {
+	if p == nil || (p.User == "" && p.Password == "") {
+		return
+	}
+	p.Users = append(p.Users, Credentials{User: p.User, Password: p.Password})
+	p.User, p.Password = "", ""
+}
+
+// Validate checks if user and password match any of the configured ones.
+// The comparisons are done in constant time. Credentials with an empty
+// password never match so that a protection without any denies all.
 func (p *Protection) Validate(user, password string) bool {
-	return p.User == user && p.Password == password
+	var valid bool
+	for i := range p.Users {
+		c := &p.Users[i]
+		if c.Password == "" {
+			continue
+		}
+		match := subtle.ConstantTimeCompare([]byte(user), []byte(c.User)) &
+			subtle.ConstantTimeCompare([]byte(password), []byte(c.Password))
+		valid = valid || match == 1
+	}
+	return valid
 }
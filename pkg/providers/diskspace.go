@@ -0,0 +1,42 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// ErrInsufficientStorage is returned if there is not enough
+// free space in the web root to build a profile.
+var ErrInsufficientStorage = errors.New("insufficient storage")
+
+// checkFreeSpace checks if the web root has the configured
+// minimum of free space left to build a profile.
+func (s *System) checkFreeSpace() error {
+	minFree := uint64(s.cfg.Providers.MinFreeMB) << 20
+	if minFree == 0 {
+		return nil
+	}
+	free, err := freeSpace(s.cfg.Web.Root)
+	switch {
+	case errors.Is(err, errors.ErrUnsupported):
+		slog.Warn("checking free space is not supported on this platform")
+		return nil
+	case err != nil:
+		return fmt.Errorf("checking free space failed: %w", err)
+	case free < minFree:
+		return fmt.Errorf("%w: %d MiB free in %q, %d MiB required",
+			ErrInsufficientStorage, free>>20, s.cfg.Web.Root, s.cfg.Providers.MinFreeMB)
+	}
+	return nil
+}
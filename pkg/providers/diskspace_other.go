@@ -0,0 +1,20 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+//go:build !(linux || darwin || freebsd)
+
+package providers
+
+import "errors"
+
+// freeSpace is not supported on this platform.
+func freeSpace(string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
@@ -0,0 +1,45 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+func TestInsufficientStorage(t *testing.T) {
+	origin := testOrigin(t, map[string]map[string]string{
+		"main": {"white/advisory.json": `{}`},
+	})
+	cfg := testConfig(t, origin, testKey(t), config.Profiles{
+		"main": {Branches: []string{"main"}},
+	})
+	// No file system has an exbibyte left.
+	cfg.Providers.MinFreeMB = 1 << 40
+	s := startSystem(t, cfg)
+
+	if err := s.Serve(t.Context(), "main"); !errors.Is(err, ErrInsufficientStorage) {
+		t.Fatalf("got error %v, want %v", err, ErrInsufficientStorage)
+	}
+	if s.instantiated("main") {
+		t.Error("profile is exported without free space")
+	}
+	if entries, _ := os.ReadDir(cfg.Web.Root); len(entries) != 0 {
+		t.Errorf("%d entries left in the web root", len(entries))
+	}
+
+	// With enough space the profile is built.
+	cfg.Providers.MinFreeMB = 1
+	serve(t, s, "main")
+}
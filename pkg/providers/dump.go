@@ -0,0 +1,156 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// buildResult is the outcome of the last build of a profile.
+type buildResult struct {
+	finished time.Time
+	err      error
+}
+
+// runningBuild is the state of a running build.
+type runningBuild struct {
+	since time.Time
+	// done and total are the number of extracted entries
+	// and of all entries of the merged branches.
+	done, total int
+}
+
+// buildState tracks the builds for debugging. In contrast to the
+// rest of the system it is safe to be read while a build is running.
+type buildState struct {
+	mu sync.Mutex
+	// running are the start times of the running builds
+	// by profile path.
+	running map[string]runningBuild
+	last    map[string]buildResult
+}
+
+// start records the start of a build.
+func (bs *buildState) start(profilePath string) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if bs.running == nil {
+		bs.running = map[string]runningBuild{}
+	}
+	bs.running[profilePath] = runningBuild{since: time.Now()}
+}
+
+// progress records the progress of the extraction of a build.
+func (bs *buildState) progress(profilePath string, done, total int) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if rb, ok := bs.running[profilePath]; ok {
+		rb.done, rb.total = done, total
+		bs.running[profilePath] = rb
+	}
+}
+
+// Progress returns the fraction of the extracted entries of
+// the running build of a profile. It returns false if there
+// is no running build of the profile.
+func (s *System) Progress(profile string) (float64, bool) {
+	s.builds.mu.Lock()
+	defer s.builds.mu.Unlock()
+	rb, ok := s.builds.running[profile]
+	if !ok {
+		return 0, false
+	}
+	if rb.total == 0 {
+		return 0, true
+	}
+	return float64(rb.done) / float64(rb.total), true
+}
+
+// done records the end of a build.
+func (bs *buildState) done(profilePath string, err error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if bs.last == nil {
+		bs.last = map[string]buildResult{}
+	}
+	bs.last[profilePath] = buildResult{finished: time.Now(), err: err}
+	delete(bs.running, profilePath)
+}
+
+// Dump writes a snapshot of the state of the system for debugging.
+// It does not wait for running builds so it can be used to
+// inspect a stuck system.
+func (s *System) Dump(w io.Writer) error {
+	const stamp = time.RFC3339
+	now := time.Now()
+
+	s.builds.mu.Lock()
+	running := maps.Clone(s.builds.running)
+	last := maps.Clone(s.builds.last)
+	s.builds.mu.Unlock()
+
+	var errs []error
+	printf := func(format string, args ...any) {
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	printf("state at %s\n", now.Format(stamp))
+	switch status := s.upstream.Load(); {
+	case status == nil:
+		printf("upstream: not checked yet\n")
+	case status.err != nil:
+		printf("upstream: %v (checked %s)\n", status.err, status.checked.Format(stamp))
+	default:
+		printf("upstream: reachable (checked %s)\n", status.checked.Format(stamp))
+	}
+	if len(running) == 0 {
+		printf("building: -\n")
+	}
+	for _, current := range slices.Sorted(maps.Keys(running)) {
+		rb := running[current]
+		printf("building: %s since %s (%s, %d/%d entries)\n",
+			current, rb.since.Format(stamp), now.Sub(rb.since).Round(time.Millisecond),
+			rb.done, rb.total)
+	}
+
+	printf("profiles:\n")
+	profiles := s.Profiles()
+	for _, name := range slices.Sorted(maps.Keys(profiles)) {
+		export, ok := s.CurrentExport(name)
+		if !ok {
+			export = "-"
+		}
+		printf("  %s: branches=%v export=%s", name, profiles.Branches(name), export)
+		if res, ok := last[name]; ok {
+			printf(" last_build=%s", res.finished.Format(stamp))
+			if res.err != nil {
+				printf(" last_error=%q", res.err.Error())
+			}
+		}
+		printf("\n")
+	}
+
+	previews, _ := os.ReadDir(filepath.Join(s.cfg.Web.Root, previewsDir))
+	printf("previews: %d\n", len(previews))
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+func TestDump(t *testing.T) {
+	// The branches a and b conflict with each other.
+	origin := testOrigin(t, map[string]map[string]string{
+		"main": {"white/advisory.json": `{}`},
+		"a":    {"white/conflict.json": `{"a":true}`},
+		"b":    {"white/conflict.json": `{"b":true}`},
+	})
+	cfg := testConfig(t, origin, testKey(t), config.Profiles{
+		"broken": {Branches: []string{"a", "b"}},
+		"built":  {Branches: []string{"main"}},
+		"cold":   {Branches: []string{"main"}},
+	})
+	s := startSystem(t, cfg)
+	serve(t, s, "built")
+	if err := s.Serve(t.Context(), "broken"); err == nil {
+		t.Fatal("conflicting branches are merged")
+	}
+	// Pretend a build hangs.
+	s.builds.start("cold")
+	s.builds.progress("cold", 3, 10)
+
+	var b strings.Builder
+	if err := s.Dump(&b); err != nil {
+		t.Fatal(err)
+	}
+	dump := b.String()
+	export, err := filepath.EvalSymlinks(filepath.Join(cfg.Web.Root, "built"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := filepath.Base(export)
+	for _, want := range []string{
+		`(?m)^upstream: `,
+		`(?m)^building: cold since \S+ \(\S+, 3/10 entries\)$`,
+		`(?m)^  broken: branches=\[a b\] export=- last_build=\S+ last_error=".*conflicts in data/white/conflict.json.*"$`,
+		`(?m)^  built: branches=\[main\] export=` + hash + ` last_build=\S+$`,
+		`(?m)^  cold: branches=\[main\] export=-$`,
+		`(?m)^previews: 0$`,
+	} {
+		if !regexp.MustCompile(want).MatchString(dump) {
+			t.Errorf("dump does not match %s:\n%s", want, dump)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+// KeyInfo describes a signing key and its expiry.
+type KeyInfo struct {
+	Fingerprint string    `json:"fingerprint"`
+	KeyID       string    `json:"key_id"`
+	Created     time.Time `json:"created"`
+	// Algorithm is the public key algorithm of the
+	// key signing the files, e.g. "EdDSA Curve25519".
+	Algorithm string `json:"algorithm"`
+	// Expires is the time the key can no longer sign.
+	// It is nil if the key does not expire.
+	Expires *time.Time `json:"expires,omitempty"`
+	// Expired is true if the key cannot sign anymore.
+	Expired bool `json:"expired"`
+	// Expiring is true if the key expires within the
+	// configured expiry warning.
+	Expiring bool `json:"expiring"`
+}
+
+// keyExpires returns the time a key can no longer sign. This
+// is the earliest expiry of the primary key and the signing key.
+func keyExpires(key *crypto.Key, now time.Time) *time.Time {
+	var expires *time.Time
+	earliest := func(created time.Time, lifetime *uint32) {
+		if lifetime == nil || *lifetime == 0 {
+			return
+		}
+		t := created.Add(time.Duration(*lifetime) * time.Second)
+		if expires == nil || t.Before(*expires) {
+			expires = &t
+		}
+	}
+	entity := key.GetEntity()
+	if sig, err := entity.PrimarySelfSignature(time.Time{}, nil); err == nil {
+		earliest(entity.PrimaryKey.CreationTime, sig.KeyLifetimeSecs)
+	}
+	if signing, ok := entity.SigningKey(now, nil); ok && signing.SelfSignature != nil &&
+		signing.PublicKey != entity.PrimaryKey {
+		earliest(signing.PublicKey.CreationTime, signing.SelfSignature.KeyLifetimeSecs)
+	}
+	return expires
+}
+
+// keyAlgorithm describes the public key algorithm of the key
+// signing with a key at the given time, e.g. "RSA 3072" or
+// "ECDSA P256". If the key cannot sign its primary key
+// is described.
+func keyAlgorithm(key *crypto.Key, now time.Time) string {
+	entity := key.GetEntity()
+	pk := entity.PrimaryKey
+	if signing, ok := entity.SigningKey(now, nil); ok {
+		pk = signing.PublicKey
+	}
+	var name string
+	switch pk.PubKeyAlgo {
+	case packet.PubKeyAlgoRSA, packet.PubKeyAlgoRSASignOnly:
+		name = "RSA"
+	case packet.PubKeyAlgoDSA:
+		name = "DSA"
+	case packet.PubKeyAlgoECDSA:
+		name = "ECDSA"
+	case packet.PubKeyAlgoEdDSA:
+		name = "EdDSA"
+	case packet.PubKeyAlgoEd25519:
+		return "Ed25519"
+	case packet.PubKeyAlgoEd448:
+		return "Ed448"
+	default:
+		return fmt.Sprintf("algorithm %d", pk.PubKeyAlgo)
+	}
+	if curve, err := pk.Curve(); err == nil {
+		return name + " " + string(curve)
+	}
+	if bits, err := pk.BitLength(); err == nil {
+		return fmt.Sprintf("%s %d", name, bits)
+	}
+	return name
+}
+
+// newKeyInfo describes a key at the given time. Keys expiring
+// within warn from now are reported as expiring.
+func newKeyInfo(key *crypto.Key, now time.Time, warn time.Duration) KeyInfo {
+	_, canSign := key.GetEntity().SigningKey(now, nil)
+	info := KeyInfo{
+		Fingerprint: key.GetFingerprint(),
+		KeyID:       key.GetHexKeyID(),
+		Created:     key.GetEntity().PrimaryKey.CreationTime,
+		Algorithm:   keyAlgorithm(key, now),
+		Expires:     keyExpires(key, now),
+		Expired:     key.IsExpired(now.Unix()) || !canSign,
+	}
+	info.Expiring = !info.Expired && info.Expires != nil && info.Expires.Before(now.Add(warn))
+	return info
+}
+
+// checkKeyExpiry warns about expired keys and keys expiring soon.
+// If refuse is true an expired key is an error.
+func checkKeyExpiry(name string, key *crypto.Key, warn time.Duration, refuse bool) error {
+	switch info := newKeyInfo(key, time.Now(), warn); {
+	case info.Expired && refuse:
+		return fmt.Errorf("signing key %q (%s) is expired", name, info.KeyID)
+	case info.Expired:
+		slog.Warn("signing key is expired, clients will reject its signatures",
+			"key", name, "key_id", info.KeyID, "expires", info.Expires)
+	case info.Expiring:
+		slog.Warn("signing key expires soon",
+			"key", name, "key_id", info.KeyID, "expires", *info.Expires)
+	}
+	return nil
+}
+
+// checkKeysExpiry checks the expiry of the default key, the named
+// keys and the keys of the profiles not selecting a named one.
+func checkKeysExpiry(
+	cfg *config.Config,
+	key *crypto.Key,
+	named, profiles map[string]*crypto.Key,
+) error {
+	warn, refuse := cfg.Signing.ExpiryWarning, cfg.Signing.RefuseExpired
+	errs := []error{checkKeyExpiry("default", key, warn, refuse)}
+	for _, name := range slices.Sorted(maps.Keys(named)) {
+		errs = append(errs, checkKeyExpiry(name, named[name], warn, refuse))
+	}
+	for _, name := range slices.Sorted(maps.Keys(profiles)) {
+		if p := cfg.Providers.Profiles[name]; p != nil && p.SigningKey == "" {
+			errs = append(errs, checkKeyExpiry("profile "+name, profiles[name], warn, refuse))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SigningKeyInfo describes the default signing key.
+func (s *System) SigningKeyInfo() KeyInfo {
+	return newKeyInfo(s.signer(s.key), time.Now(), s.cfg.Signing.ExpiryWarning)
+}
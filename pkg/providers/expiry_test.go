@@ -0,0 +1,91 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+// testKeyLifetime generates a key created at the given time
+// which expires after lifetime.
+func testKeyLifetime(t *testing.T, created time.Time, lifetime time.Duration) *crypto.Key {
+	t.Helper()
+	key, err := crypto.PGP().KeyGeneration().
+		AddUserId("contravider", "test@example.com").
+		GenerationTime(created.Unix()).
+		Lifetime(int32(lifetime / time.Second)).
+		New().
+		GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestCheckKeyExpiry(t *testing.T) {
+	now := time.Now()
+	expired := testKeyLifetime(t, now.Add(-48*time.Hour), 24*time.Hour)
+	expiring := testKeyLifetime(t, now.Add(-time.Hour), 48*time.Hour)
+	valid := testKey(t)
+
+	info := newKeyInfo(expired, now, 7*24*time.Hour)
+	if !info.Expired || info.Expiring {
+		t.Errorf("expired key: got expired %t, expiring %t", info.Expired, info.Expiring)
+	}
+	if want := now.Add(-24 * time.Hour); info.Expires == nil ||
+		info.Expires.Sub(want).Abs() > time.Second {
+		t.Errorf("expired key: got expiry %v, want %v", info.Expires, want)
+	}
+	if info := newKeyInfo(valid, now, 7*24*time.Hour); info.Expired || info.Expiring || info.Expires != nil {
+		t.Errorf("valid key reported as expired %t, expiring %t, expires %v",
+			info.Expired, info.Expiring, info.Expires)
+	}
+
+	for _, check := range []struct {
+		name string
+		key  *crypto.Key
+		want string
+	}{
+		{"expired", expired, "signing key is expired"},
+		{"expiring", expiring, "signing key expires soon"},
+		{"valid", valid, ""},
+	} {
+		t.Run(check.name, func(t *testing.T) {
+			log := captureLog(t)
+			if err := checkKeyExpiry(check.name, check.key, 7*24*time.Hour, false); err != nil {
+				t.Fatalf("checking without refusing failed: %v", err)
+			}
+			if check.want == "" {
+				if log.Len() != 0 {
+					t.Errorf("unexpected warning: %s", log)
+				}
+				return
+			}
+			if !strings.Contains(log.String(), "level=WARN") ||
+				!strings.Contains(log.String(), check.want) ||
+				!strings.Contains(log.String(), "key="+check.name) {
+				t.Errorf("missing warning %q in: %s", check.want, log)
+			}
+		})
+	}
+
+	err := checkKeyExpiry("expired", expired, 0, true)
+	if err == nil || !strings.Contains(err.Error(), "is expired") {
+		t.Errorf("got error %v for a refused expired key", err)
+	}
+	if err := checkKeyExpiry("expiring", expiring, 7*24*time.Hour, true); err != nil {
+		t.Errorf("expiring key is refused: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/ProtonMail/gopenpgp/v3/profile"
+)
+
+// fixedSigner signs with a fixed creation time. Unlike the signers
+// of gopenpgp it leaves out the random salt notation of v4 signatures
+// so that the same contents get the same signatures with deterministic
+// algorithms like EdDSA and RSA. It implements the methods of
+// [crypto.PGPSign] used to sign the files.
+type fixedSigner struct {
+	entity *openpgp.Entity
+	config *packet.Config
+}
+
+func newFixedSigner(key *crypto.Key, signTime time.Time) *fixedSigner {
+	config := profile.Default().SignConfig()
+	config.Time = func() time.Time { return signTime }
+	randomize := false
+	config.NonDeterministicSignaturesViaNotation = &randomize
+	return &fixedSigner{entity: key.GetEntity(), config: config}
+}
+
+// SigningWriter implements [crypto.PGPSign]. The message written to
+// the returned writer is signed and the detached signature is written
+// to output when the writer is closed.
+func (fs *fixedSigner) SigningWriter(output crypto.Writer, encoding int8) (crypto.WriteCloser, error) {
+	var armored io.WriteCloser
+	if encoding == crypto.Armor {
+		var err error
+		if armored, err = armor.EncodeWithChecksumOption(output, openpgp.SignatureType, nil, false); err != nil {
+			return nil, fmt.Errorf("signing failed: %w", err)
+		}
+		output = armored
+	}
+	w, err := openpgp.DetachSignWriter(output, []*openpgp.Entity{fs.entity},
+		&openpgp.SignParams{Config: fs.config})
+	if err != nil {
+		return nil, fmt.Errorf("signing failed: %w", err)
+	}
+	if armored == nil {
+		return w, nil
+	}
+	return &armoredSigningWriter{WriteCloser: w, armored: armored}, nil
+}
+
+// armoredSigningWriter closes the armor after the signature.
+type armoredSigningWriter struct {
+	io.WriteCloser
+	armored io.WriteCloser
+}
+
+// Close writes the signature and ends the armor.
+func (w *armoredSigningWriter) Close() error {
+	if err := w.WriteCloser.Close(); err != nil {
+		return fmt.Errorf("signing failed: %w", err)
+	}
+	return w.armored.Close()
+}
+
+// Sign implements [crypto.PGPSign]. It returns a detached signature.
+func (fs *fixedSigner) Sign(message []byte, encoding int8) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := fs.SigningWriter(&buf, encoding)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(message); err != nil {
+		return nil, fmt.Errorf("signing failed: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SignCleartext implements [crypto.PGPSign].
+func (fs *fixedSigner) SignCleartext(message []byte) ([]byte, error) {
+	key, ok := fs.entity.SigningKey(fs.config.Now(), fs.config)
+	if !ok {
+		return nil, errors.New("no valid signing key")
+	}
+	var buf bytes.Buffer
+	w, err := clearsign.Encode(&buf, key.PrivateKey, fs.config)
+	if err != nil {
+		return nil, fmt.Errorf("clearsigning failed: %w", err)
+	}
+	if _, err := w.Write(message); err != nil {
+		return nil, fmt.Errorf("clearsigning failed: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("clearsigning failed: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ClearPrivateParams implements [crypto.PGPSign]. The
+// key is owned by the system so nothing is cleared.
+func (fs *fixedSigner) ClearPrivateParams() {}
@@ -0,0 +1,72 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// gc removes the export directories in the web root which are
+// neither linked by a profile nor kept as previous exports nor
+// currently built nor waiting for their removal. In dry-run mode they are only logged.
+func (s *System) gc() {
+	root := s.cfg.Web.Root
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		slog.Error("reading web root failed", "error", err)
+		return
+	}
+	used := map[string]bool{}
+	for hash := range s.pending {
+		used[hash] = true
+	}
+	// The retired exports are removed after their grace periods.
+	for hash := range s.retired {
+		used[hash] = true
+	}
+	for profile := range s.Profiles() {
+		if exported, err := filepath.EvalSymlinks(filepath.Join(root, profile)); err == nil {
+			used[filepath.Base(exported)] = true
+		}
+		if keep := s.cfg.Providers.KeepExports; keep > 0 {
+			infos := s.exports(profile)
+			for _, bi := range infos[:min(keep, len(infos))] {
+				used[bi.Hash] = true
+			}
+		}
+	}
+	var reclaimed int
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || !hashRe.MatchString(name) || used[name] {
+			continue
+		}
+		if s.cfg.Providers.GCDryRun {
+			slog.Info("orphaned export", "hash", name)
+			reclaimed++
+			continue
+		}
+		slog.Debug("removing orphaned export", "hash", name)
+		if err := os.RemoveAll(filepath.Join(root, name)); err != nil {
+			slog.Error("removing orphaned export failed", "hash", name, "error", err)
+			continue
+		}
+		reclaimed++
+	}
+	if reclaimed > 0 {
+		slog.Info("collected orphaned exports",
+			"count", reclaimed,
+			"dry_run", s.cfg.Providers.GCDryRun)
+	}
+	s.gcStore()
+}
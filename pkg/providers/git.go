@@ -12,6 +12,8 @@ package providers
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
 	"crypto/sha1"
 	"encoding/hex"
 	"errors"
@@ -22,9 +24,105 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/csaf-testsuite/contravider/pkg/metrics"
 )
 
-func initialCheckout(url, workdir string, branches []string) error {
+// checkoutOptions control how much of the git repository is fetched.
+type checkoutOptions struct {
+	// depth limits the fetched history to the given number
+	// of commits if positive.
+	depth int
+	// singleBranch restricts the fetches to the branches of the profiles.
+	singleBranch bool
+}
+
+// fetchArgs returns the extra arguments of the fetches.
+func (co checkoutOptions) fetchArgs() []string {
+	if co.depth <= 0 {
+		return nil
+	}
+	return []string{"--depth", strconv.Itoa(co.depth), "--no-tags"}
+}
+
+// cloneArgs returns the arguments to clone url into dir.
+func (co checkoutOptions) cloneArgs(url, dir string) []string {
+	args := []string{"clone"}
+	if co.depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(co.depth))
+	}
+	if co.singleBranch {
+		args = append(args, "--single-branch")
+	} else if co.depth > 0 {
+		// A shallow clone fetches only the default branch otherwise.
+		args = append(args, "--no-single-branch")
+	}
+	return append(args, url, dir)
+}
+
+// trackBranches restricts the fetches of the clone to the branches
+// of the profiles and fetches them.
+func (g *gitClient) trackBranches(ctx context.Context, cloneDir string, branches []string, co checkoutOptions) error {
+	tracked := []string{"main"}
+	for _, branch := range branches {
+		if !slices.Contains(tracked, branch) {
+			tracked = append(tracked, branch)
+		}
+	}
+	cmd := g.command(ctx, append([]string{"remote", "set-branches", "origin"}, tracked...)...)
+	cmd.Dir = cloneDir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		slog.Error("git remote set-branches failed", "msg", g.scrub(output), "err", err)
+		return fmt.Errorf("tracking branches failed: %w", err)
+	}
+	return g.fetchOrigin(ctx, cloneDir, co)
+}
+
+// fetchOrigin fetches the tracked branches of the remote.
+func (g *gitClient) fetchOrigin(ctx context.Context, cloneDir string, co checkoutOptions) error {
+	cmd := g.command(ctx, append(append([]string{"fetch"}, co.fetchArgs()...), "origin")...)
+	cmd.Dir = cloneDir
+	done := observe("fetch")
+	output, err := cmd.CombinedOutput()
+	if done(err); err != nil {
+		slog.Error("git fetch failed", "msg", g.scrub(output), "err", err)
+		return fmt.Errorf("git fetch failed: %w", err)
+	}
+	return nil
+}
+
+// pull updates the checkout of a branch in dir. Shallow checkouts are
+// fetched with the same depth and reset to the fetched revision, so
+// that their history does not grow. A pull would refuse to merge them
+// as the fetched revision has no parents.
+func (g *gitClient) pull(ctx context.Context, dir, branch string, co checkoutOptions) ([]byte, error) {
+	done := observe("pull")
+	if co.depth <= 0 {
+		cmd := g.command(ctx, "pull")
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		done(err)
+		return output, err
+	}
+	cmd := g.command(ctx, append(append([]string{"fetch"}, co.fetchArgs()...), "origin", branch)...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		cmd = g.command(ctx, "reset", "--hard", "--quiet", "origin/"+branch)
+		cmd.Dir = dir
+		var out []byte
+		out, err = cmd.CombinedOutput()
+		output = append(output, out...)
+	}
+	done(err)
+	return output, err
+}
+
+func (g *gitClient) initialCheckout(ctx context.Context, url, workdir string, branches []string, co checkoutOptions) error {
 
 	absWorkDir, err := filepath.Abs(workdir)
 	if err != nil {
@@ -51,22 +149,27 @@ func initialCheckout(url, workdir string, branches []string) error {
 	}
 
 	if clone { // Fresh checkout
-		cmd := exec.Command("git", "clone", url, cloneDir)
+		cmd := g.command(ctx, co.cloneArgs(url, cloneDir)...)
+		done := observe("clone")
 		output, err := cmd.CombinedOutput()
+		done(err)
 		if err != nil {
-			slog.Error("clone failed", "msg", output)
+			slog.Error("clone failed", "msg", g.scrub(output))
 			return fmt.Errorf("clone failed: %w", err)
 		}
 	} else { // Only update
-		cmd := exec.Command("git", "pull")
-		cmd.Dir = cloneDir
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			slog.Error("git pull failed", "msg", output, "err", err)
+		if output, err := g.pull(ctx, cloneDir, "main", co); err != nil {
+			slog.Error("git pull failed", "msg", g.scrub(output), "err", err)
 			return fmt.Errorf("git pull failed: %w", err)
 		}
 	}
 
+	if co.singleBranch {
+		if err := g.trackBranches(ctx, cloneDir, branches, co); err != nil {
+			return err
+		}
+	}
+
 	for _, branch := range branches {
 		if branch == "main" {
 			// Ignore main as it already there.
@@ -78,19 +181,16 @@ func initialCheckout(url, workdir string, branches []string) error {
 				return err
 			}
 			// Create
-			cmd := exec.Command("git", "worktree", "add", branchDir, branch)
-			cmd.Dir = cloneDir
-			output, err := cmd.CombinedOutput()
+			done := observe("worktree_add")
+			output, err := g.worktree(ctx, cloneDir, "add", branchDir, branch)
+			done(err)
 			if err != nil {
-				slog.Error("worktree add failed", "msg", output, "err", err)
+				slog.Error("worktree add failed", "msg", g.scrub(output), "err", err)
 				return fmt.Errorf("worktree add failed: %w", err)
 			}
 		} else { // Only update
-			cmd := exec.Command("git", "pull")
-			cmd.Dir = branchDir
-			output, err := cmd.CombinedOutput()
-			if err != nil {
-				slog.Error("git pull failed", "msg", output, "err", err)
+			if output, err := g.pull(ctx, branchDir, branch, co); err != nil {
+				slog.Error("git pull failed", "msg", g.scrub(output), "err", err)
 				return fmt.Errorf("git pull failed: %w", err)
 			}
 		}
@@ -99,26 +199,115 @@ func initialCheckout(url, workdir string, branches []string) error {
 	return nil
 }
 
-// allRevisionsHash returns a hash over all revisions of the given branches.
-func allRevisionsHash(workdir string, branches []string) ([]byte, error) {
-	hash := sha1.New()
+// observe starts timing a git operation. The returned function
+// records the duration and counts a failure if err is not nil.
+func observe(operation string) func(err error) {
+	start := time.Now()
+	return func(err error) {
+		metrics.ObserveGit(operation, time.Since(start), err)
+	}
+}
+
+// worktree runs a git worktree command in the clone dir. The worktree
+// commands are serialized as git reads the metadata of all worktrees
+// while adding one and fails on the half written one of another.
+func (g *gitClient) worktree(ctx context.Context, cloneDir string, args ...string) ([]byte, error) {
+	g.worktrees.Lock()
+	defer g.worktrees.Unlock()
+	cmd := g.command(ctx, append([]string{"worktree"}, args...)...)
+	cmd.Dir = cloneDir
+	return cmd.CombinedOutput()
+}
+
+// addWorktrees adds worktrees for the given branches which are
+// not checked out yet. The existing checkouts are left untouched.
+func (g *gitClient) addWorktrees(ctx context.Context, workdir string, branches []string, co checkoutOptions) error {
+	cloneDir := filepath.Join(workdir, "main")
+	fetched := false
+	for _, branch := range branches {
+		if branch == "main" {
+			continue
+		}
+		branchDir := filepath.Join(workdir, branch)
+		if _, err := os.Stat(branchDir); err == nil {
+			continue
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		// New branches may not be known to the clone yet.
+		if !fetched {
+			var err error
+			if co.singleBranch {
+				err = g.trackBranches(ctx, cloneDir, branches, co)
+			} else {
+				err = g.fetchOrigin(ctx, cloneDir, co)
+			}
+			if err != nil {
+				return err
+			}
+			fetched = true
+		}
+		done := observe("worktree_add")
+		output, err := g.worktree(ctx, cloneDir, "add", branchDir, branch)
+		if done(err); err != nil {
+			slog.Error("worktree add failed", "msg", g.scrub(output), "err", err)
+			return fmt.Errorf("worktree add of %q failed: %w", branch, err)
+		}
+	}
+	return nil
+}
+
+// removeWorktrees removes the worktrees of the given branches.
+func (g *gitClient) removeWorktrees(ctx context.Context, workdir string, branches []string) error {
+	cloneDir := filepath.Join(workdir, "main")
+	var errs []error
 	for _, branch := range branches {
-		rev, err := currentRevision(workdir, branch)
+		if branch == "main" {
+			continue
+		}
+		if output, err := g.worktree(ctx, cloneDir, "remove", "--force", filepath.Join(workdir, branch)); err != nil {
+			slog.Error("worktree remove failed", "msg", g.scrub(output), "err", err)
+			errs = append(errs, fmt.Errorf("worktree remove of %q failed: %w", branch, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// branchRevisions returns the current revisions of the checked out branches.
+func (g *gitClient) branchRevisions(ctx context.Context, workdir string, branches []string) ([]string, error) {
+	revisions := make([]string, 0, len(branches))
+	for _, branch := range branches {
+		rev, err := g.currentRevision(ctx, workdir, branch)
 		if err != nil {
-			return nil, fmt.Errorf("allRevisions failed for %q: %w", branch, err)
+			return nil, fmt.Errorf("revision of %q failed: %w", branch, err)
 		}
-		hash.Write(rev)
+		revisions = append(revisions, hex.EncodeToString(rev))
+	}
+	return revisions, nil
+}
+
+// exportHash returns a hash over the name of a profile and the
+// revisions of its branches. The name is included so that profiles
+// with the same branches do not share an export.
+func exportHash(profile string, revisions []string) string {
+	hash := sha1.New()
+	hash.Write([]byte(profile))
+	for _, rev := range revisions {
+		hash.Write([]byte{0})
+		hash.Write([]byte(rev))
 	}
-	return hash.Sum(nil), nil
+	return hex.EncodeToString(hash.Sum(nil))
 }
 
 // currentRevision returns the current revision of a checked out branch.
-func currentRevision(workdir, branch string) ([]byte, error) {
-	cmd := exec.Command("git", "rev-parse", "HEAD")
+func (g *gitClient) currentRevision(ctx context.Context, workdir, branch string) ([]byte, error) {
+	cmd := g.command(ctx, "rev-parse", "HEAD")
 	cmd.Dir = path.Join(workdir, branch)
+	done := observe("rev_parse")
 	output, err := cmd.CombinedOutput()
+	done(err)
 	if err != nil {
-		slog.Error("git rev-parse failed", "msg", output, "err", err)
+		slog.Error("git rev-parse failed", "msg", g.scrub(output), "err", err)
 		return nil, fmt.Errorf("git rev-parse failed: %w", err)
 	}
 	out := bytes.TrimSpace(output)
@@ -130,80 +319,47 @@ func currentRevision(workdir, branch string) ([]byte, error) {
 	return rev[:n], nil
 }
 
-// mergeBranches merges all branches into first branch and serializes
-// as a tar stream. After that the original revision of the first branch
-// is restored.
-func mergeBranches(
-	workdir string, branches []string,
-	untar func(io.Reader) error,
-) (err error) {
-	base := branches[0]
-	headRev, err := currentRevision(workdir, base)
-	if err != nil {
-		return fmt.Errorf("merging branches failed: %w", err)
-	}
-	head := hex.EncodeToString(headRev)
-
-	baseDir := path.Join(workdir, base)
-
-	// Guarantee that the original revision is restored.
-	defer func() {
-		cmd := exec.Command("git", "reset", "--hard", head)
-		cmd.Dir = baseDir
-		_, err2 := cmd.CombinedOutput()
-		err = errors.Join(err, err2)
-	}()
-
-	// Merge other branches into first.
-	for _, branch := range branches[1:] {
-		cmd := exec.Command("git", "merge", "--no-edit", branch)
-		cmd.Dir = baseDir
-		_, err := cmd.CombinedOutput()
-		if err != nil {
-			return fmt.Errorf(
-				"merging branch %q into %q failed: %w",
-				branch, base, err)
-		}
-	}
+// mergeOptions are the options of the merges of the branches.
+type mergeOptions struct {
+	// name and email are the git identity used for the merge commits.
+	name  string
+	email string
+	// noFF creates merge commits even if a fast-forward is possible.
+	noFF bool
+}
 
-	// Pipe the git archive tar stream to given function.
-	cmd := exec.Command("git", "archive", "--format=tar", "HEAD")
-	cmd.Dir = baseDir
-	stdout, err2 := cmd.StdoutPipe()
-	if err2 != nil {
-		err = fmt.Errorf("failed to get stdout from git archive: %w", err)
-		return
+// args returns the arguments to merge a revision.
+// An explicit identity avoids failing merges if none is configured.
+func (mo mergeOptions) args(rev string) []string {
+	args := []string{
+		"-c", "user.name=" + mo.name,
+		"-c", "user.email=" + mo.email,
+		"merge", "--no-edit",
 	}
-	if err = cmd.Start(); err != nil {
-		err = fmt.Errorf("starting git archive failed: %w", err)
-		return
+	if mo.noFF {
+		args = append(args, "--no-ff")
 	}
-	err3 := untar(stdout)
-	err4 := cmd.Wait()
-	err = errors.Join(err3, err4)
-	return
+	return append(args, rev)
 }
 
 // updateBranches updates all given branches and returns a slice
 // of branches which actually got changed.
-func updateBranches(workdir string, branches []string) ([]string, error) {
+func (g *gitClient) updateBranches(ctx context.Context, workdir string, branches []string, co checkoutOptions) ([]string, error) {
 	var (
 		refreshed []string
 		errs      []error
 	)
 	for _, branch := range branches {
-		before, err := currentRevision(workdir, branch)
+		before, err := g.currentRevision(ctx, workdir, branch)
 		if err != nil {
 			errs = append(errs, err)
 			continue
 		}
-		cmd := exec.Command("git", "pull")
-		cmd.Dir = path.Join(workdir, branch)
-		if _, err := cmd.CombinedOutput(); err != nil {
+		if _, err := g.pull(ctx, path.Join(workdir, branch), branch, co); err != nil {
 			errs = append(errs, err)
 			continue
 		}
-		after, err := currentRevision(workdir, branch)
+		after, err := g.currentRevision(ctx, workdir, branch)
 		if err != nil {
 			errs = append(errs, err)
 			continue
@@ -214,3 +370,225 @@ func updateBranches(workdir string, branches []string) ([]string, error) {
 	}
 	return refreshed, errors.Join(errs...)
 }
+
+// remoteBranches returns the names of the branches available in the
+// git repository. If there is already a checkout in the workdir it is
+// used, otherwise the remote repository is asked. A checkout only
+// tracking the branches of the profiles does not know the others.
+func (g *gitClient) remoteBranches(ctx context.Context, url, workdir string, co checkoutOptions) ([]string, error) {
+	var cmd *exec.Cmd
+	cloneDir := filepath.Join(workdir, "main")
+	if _, err := os.Stat(cloneDir); err == nil && !co.singleBranch {
+		cmd = g.command(ctx,
+			"for-each-ref", "--format=%(refname:lstrip=3)", "refs/remotes/origin")
+		cmd.Dir = cloneDir
+	} else {
+		cmd = g.command(ctx, "ls-remote", "--heads", url)
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing branches failed: %w", err)
+	}
+	var branches []string
+	for line := range strings.Lines(string(output)) {
+		line = strings.TrimSpace(line)
+		// ls-remote prints "<revision>\trefs/heads/<branch>".
+		if _, ref, ok := strings.Cut(line, "\t"); ok {
+			line = strings.TrimPrefix(ref, "refs/heads/")
+		}
+		if line != "" && line != "HEAD" {
+			branches = append(branches, line)
+		}
+	}
+	return branches, nil
+}
+
+// pingRemote checks if the remote git repository is reachable.
+func (g *gitClient) pingRemote(ctx context.Context, url string) error {
+	cmd := g.command(ctx, "ls-remote", "--heads", url)
+	done := observe("ls_remote")
+	output, err := cmd.CombinedOutput()
+	if done(err); err != nil {
+		return fmt.Errorf("git ls-remote failed: %w: %s",
+			err, g.scrub(bytes.TrimSpace(output)))
+	}
+	return nil
+}
+
+// fetchRevision fetches a ref from the remote repository into the
+// main checkout and returns the fetched revision.
+func (g *gitClient) fetchRevision(ctx context.Context, workdir, ref string) (string, error) {
+	if ref == "" || strings.HasPrefix(ref, "-") {
+		return "", fmt.Errorf("invalid ref %q", ref)
+	}
+	cloneDir := filepath.Join(workdir, "main")
+	// Fetch into a ref of its own as FETCH_HEAD is shared
+	// with the concurrent fetches.
+	local := "refs/previews/" + rand.Text()
+	cmd := g.command(ctx, "fetch", "--no-tags", "--no-write-fetch-head",
+		"origin", "+"+ref+":"+local)
+	cmd.Dir = cloneDir
+	done := observe("fetch")
+	output, err := cmd.CombinedOutput()
+	if done(err); err != nil {
+		slog.Error("git fetch failed", "ref", ref, "msg", g.scrub(output), "err", err)
+		return "", fmt.Errorf("fetching %q failed: %w", ref, err)
+	}
+	defer func() {
+		cmd := g.command(context.Background(), "update-ref", "-d", local)
+		cmd.Dir = cloneDir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			slog.Warn("removing fetched ref failed", "ref", local, "msg", g.scrub(output), "err", err)
+		}
+	}()
+	cmd = g.command(ctx, "rev-parse", "--verify", local+"^{commit}")
+	cmd.Dir = cloneDir
+	output, err = cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("resolving %q failed: %w", ref, err)
+	}
+	return string(bytes.TrimSpace(output)), nil
+}
+
+// MergeConflictError is returned if a branch of a profile
+// does not merge into the previous ones.
+type MergeConflictError struct {
+	Branch string
+	Files  []string
+	Err    error
+}
+
+// Error implements [error].
+func (mce *MergeConflictError) Error() string {
+	if len(mce.Files) == 0 {
+		return fmt.Sprintf("merging branch %q failed: %v", mce.Branch, mce.Err)
+	}
+	return fmt.Sprintf("merging branch %q failed: conflicts in %s: %v",
+		mce.Branch, strings.Join(mce.Files, ", "), mce.Err)
+}
+
+// Unwrap returns the error of the failed merge.
+func (mce *MergeConflictError) Unwrap() error {
+	return mce.Err
+}
+
+// mergeRevisions merges the given revisions of the branches into the
+// first one in a temporary worktree and serializes the result as a
+// tar stream. The checkouts of the branches are left untouched so
+// merges of different profiles can run concurrently.
+func (g *gitClient) mergeRevisions(
+	ctx context.Context, workdir string, branches, revisions []string, mo mergeOptions,
+	untar func(io.Reader, int) error,
+) (err error) {
+	cloneDir := filepath.Join(workdir, "main")
+	tmpDir, err := os.MkdirTemp("", "contravider-merge-")
+	if err != nil {
+		return fmt.Errorf("creating temporary worktree failed: %w", err)
+	}
+	if output, err := g.worktree(ctx, cloneDir, "add", "--detach", tmpDir, revisions[0]); err != nil {
+		slog.Error("worktree add failed", "msg", g.scrub(output), "err", err)
+		return errors.Join(
+			fmt.Errorf("worktree add failed: %w", err),
+			os.RemoveAll(tmpDir))
+	}
+
+	// Guarantee that the temporary worktree is removed. This has to
+	// happen even if the context is done, e.g. because the client
+	// went away, or the worktree metadata would be left behind.
+	defer func() {
+		cleanup := context.WithoutCancel(ctx)
+		_, err2 := g.worktree(cleanup, cloneDir, "remove", "--force", tmpDir)
+		err = errors.Join(err, os.RemoveAll(tmpDir))
+		if err2 != nil {
+			// Drop the metadata of the already removed worktree.
+			if output, err3 := g.worktree(cleanup, cloneDir, "prune"); err3 != nil {
+				slog.Error("worktree prune failed", "msg", g.scrub(output), "err", err3)
+				err = errors.Join(err, err2, err3)
+			}
+		}
+	}()
+
+	for i, rev := range revisions[1:] {
+		cmd := g.command(ctx, mo.args(rev)...)
+		cmd.Dir = tmpDir
+		done := observe("merge")
+		output, err := cmd.CombinedOutput()
+		if done(err); err != nil {
+			slog.Debug("git merge failed", "revision", rev, "msg", g.scrub(output))
+			files := g.conflictingPaths(ctx, tmpDir)
+			// Without conflicts tell why git refused to merge,
+			// e.g. because of a too shallow history.
+			if len(files) == 0 {
+				if msg := bytes.TrimSpace(output); len(msg) > 0 {
+					err = fmt.Errorf("%w: %s", err, g.scrub(msg))
+				}
+			}
+			return &MergeConflictError{
+				Branch: branches[i+1],
+				Files:  files,
+				Err:    err,
+			}
+		}
+	}
+
+	return g.archive(ctx, tmpDir, "HEAD", untar)
+}
+
+// conflictingPaths returns the unmerged paths of a failed merge.
+func (g *gitClient) conflictingPaths(ctx context.Context, dir string) []string {
+	cmd := g.command(ctx, "diff", "--name-only", "-z", "--diff-filter=U")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil || len(output) == 0 {
+		return nil
+	}
+	// The paths are terminated by NUL bytes and may contain spaces.
+	return strings.Split(strings.TrimSuffix(string(output), "\x00"), "\x00")
+}
+
+// archiveRevision serializes a single revision as a tar stream without
+// a worktree. It is the fast path for profiles without merges.
+func (g *gitClient) archiveRevision(
+	ctx context.Context, workdir, revision string,
+	untar func(io.Reader, int) error,
+) error {
+	return g.archive(ctx, filepath.Join(workdir, "main"), revision, untar)
+}
+
+// countEntries returns the number of files and directories
+// in the tree of a revision which is the number of entries
+// in its tar stream.
+func (g *gitClient) countEntries(ctx context.Context, dir, revision string) (int, error) {
+	cmd := g.command(ctx, "ls-tree", "-r", "-t", "-z", "--name-only", revision)
+	cmd.Dir = dir
+	done := observe("ls_tree")
+	output, err := cmd.Output()
+	if done(err); err != nil {
+		return 0, fmt.Errorf("git ls-tree failed: %w", err)
+	}
+	return bytes.Count(output, []byte{0}), nil
+}
+
+// archive pipes the git archive tar stream of a revision in the
+// given checkout and its number of entries to the untar function.
+func (g *gitClient) archive(ctx context.Context, dir, revision string, untar func(io.Reader, int) error) error {
+	entries, err := g.countEntries(ctx, dir, revision)
+	if err != nil {
+		return err
+	}
+	cmd := g.command(ctx, "archive", "--format=tar", revision)
+	cmd.Dir = dir
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to get stdout from git archive: %w", err)
+	}
+	done := observe("archive")
+	if err := cmd.Start(); err != nil {
+		done(err)
+		return fmt.Errorf("starting git archive failed: %w", err)
+	}
+	errUntar := untar(stdout, entries)
+	errWait := cmd.Wait()
+	done(errWait)
+	return errors.Join(errUntar, errWait)
+}
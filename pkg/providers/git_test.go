@@ -0,0 +1,368 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testRepo creates a work directory with a checkout "main" having the
+// branches "main", "a" and "b" with distinct files. It returns the
+// work directory and the revisions of the branches.
+func testRepo(t testing.TB) (string, []string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	workdir := t.TempDir()
+	clone := filepath.Join(workdir, "main")
+	if err := os.Mkdir(clone, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	testGit(t, clone, "init", "-q")
+	commit := func(file string) {
+		if err := os.WriteFile(filepath.Join(clone, file), []byte(file), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		testGit(t, clone, "add", file)
+		testGit(t, clone, "commit", "-q", "-m", file)
+	}
+	commit("base.json")
+	for _, branch := range []string{"a", "b"} {
+		testGit(t, clone, "checkout", "-q", "-b", branch, "main")
+		commit(branch + ".json")
+	}
+	testGit(t, clone, "checkout", "-q", "main")
+	var revisions []string
+	for _, branch := range []string{"main", "a", "b"} {
+		revisions = append(revisions, testGit(t, clone, "rev-parse", branch))
+	}
+	return workdir, revisions
+}
+
+// testGitClient runs the git commands without credentials.
+var testGitClient = new(gitClient)
+
+// testMergeOptions are the merge options used in the tests.
+var testMergeOptions = mergeOptions{
+	name:  "test",
+	email: "test@example.com",
+	noFF:  true,
+}
+
+// checkNoWorktrees fails if temporary worktrees are left in the checkout.
+func checkNoWorktrees(t testing.TB, workdir string) {
+	t.Helper()
+	clone := filepath.Join(workdir, "main")
+	if list := testGit(t, clone, "worktree", "list", "--porcelain"); strings.Count(list, "worktree ") != 1 {
+		t.Errorf("temporary worktrees left:\n%s", list)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(clone, ".git", "worktrees")); len(entries) != 0 {
+		t.Errorf("%d worktree metadata entries left", len(entries))
+	}
+}
+
+// mergedCommit merges the revisions of the branches and returns
+// the id of the archived commit.
+func mergedCommit(
+	t *testing.T, workdir string, branches, revisions []string, mo mergeOptions,
+) string {
+	t.Helper()
+	var commit string
+	if err := testGitClient.mergeRevisions(t.Context(), workdir, branches, revisions, mo, func(r io.Reader, _ int) error {
+		// git archive stores the commit id in the global header.
+		hdr, err := tar.NewReader(r).Next()
+		if err != nil {
+			return err
+		}
+		commit = hdr.PAXRecords["comment"]
+		_, err = io.Copy(io.Discard, r)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if commit == "" {
+		t.Fatal("archive has no commit id")
+	}
+	return commit
+}
+
+func TestMergeWithoutIdentity(t *testing.T) {
+	workdir, revisions := testRepo(t)
+	// Neither a global identity nor a guessed one is available.
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "user.useConfigOnly")
+	t.Setenv("GIT_CONFIG_VALUE_0", "true")
+	for _, env := range []string{"GIT_AUTHOR_NAME", "GIT_AUTHOR_EMAIL", "GIT_COMMITTER_NAME", "GIT_COMMITTER_EMAIL", "EMAIL"} {
+		t.Setenv(env, "")
+		os.Unsetenv(env)
+	}
+	clone := filepath.Join(workdir, "main")
+	cmd := exec.Command("git", "commit", "-q", "--allow-empty", "-m", "no identity")
+	cmd.Dir = clone
+	if err := cmd.Run(); err == nil {
+		t.Fatal("committing without identity succeeds")
+	}
+
+	// Merging b needs a merge commit.
+	mo := mergeOptions{name: "merger", email: "merger@example.com"}
+	commit := mergedCommit(t, workdir, []string{"main", "a", "b"}, revisions, mo)
+	if author := testGit(t, clone, "show", "-s", "--format=%an <%ae>", commit); author != "merger <merger@example.com>" {
+		t.Errorf("got merge author %q", author)
+	}
+}
+
+func TestMergeNoFF(t *testing.T) {
+	workdir, revisions := testRepo(t)
+	// The branch a is forked from main and can be fast-forwarded.
+	branches, revisions := []string{"main", "a"}, revisions[:2]
+	clone := filepath.Join(workdir, "main")
+	for _, noFF := range []bool{false, true} {
+		mo := mergeOptions{name: "test", email: "test@example.com", noFF: noFF}
+		commit := mergedCommit(t, workdir, branches, revisions, mo)
+		parents := strings.Fields(testGit(t, clone, "show", "-s", "--format=%P", commit))
+		if merged := len(parents) == 2; merged != noFF {
+			t.Errorf("no-ff %t: got commit %s with parents %q", noFF, commit, parents)
+		}
+		if !noFF && commit != revisions[1] {
+			t.Errorf("fast-forward: got commit %s, want %s", commit, revisions[1])
+		}
+	}
+}
+
+func TestMergeConflictThenSuccess(t *testing.T) {
+	workdir, revisions := testRepo(t)
+	// The branch c adds the file of a with another content.
+	clone := filepath.Join(workdir, "main")
+	testGit(t, clone, "checkout", "-q", "-b", "c", "main")
+	if err := os.WriteFile(filepath.Join(clone, "a.json"), []byte("c"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	testGit(t, clone, "add", "a.json")
+	testGit(t, clone, "commit", "-q", "-m", "c")
+	conflicting := testGit(t, clone, "rev-parse", "c")
+	testGit(t, clone, "checkout", "-q", "main")
+
+	mo := mergeOptions{name: "test", email: "test@example.com"}
+	ignore := func(io.Reader, int) error { return nil }
+	err := testGitClient.mergeRevisions(
+		t.Context(), workdir, []string{"main", "a", "c"},
+		[]string{revisions[0], revisions[1], conflicting}, mo, ignore,
+	)
+	var mce *MergeConflictError
+	if !errors.As(err, &mce) {
+		t.Fatalf("got error %v, want a merge conflict", err)
+	}
+	if mce.Branch != "c" || !slices.Equal(mce.Files, []string{"a.json"}) {
+		t.Errorf("got conflict of %s in %q", mce.Branch, mce.Files)
+	}
+	if worktrees := testGit(t, clone, "worktree", "list", "--porcelain"); strings.Count(worktrees, "worktree ") != 1 {
+		t.Errorf("temporary worktree left:\n%s", worktrees)
+	}
+	if status := testGit(t, clone, "status", "--porcelain"); status != "" {
+		t.Errorf("checkout left dirty:\n%s", status)
+	}
+	if head := testGit(t, clone, "rev-parse", "HEAD"); head != revisions[0] {
+		t.Errorf("got head %s, want %s", head, revisions[0])
+	}
+
+	// The next merge of another profile is not affected.
+	if err := testGitClient.mergeRevisions(t.Context(), workdir, []string{"main", "a", "b"}, revisions, mo, ignore); err != nil {
+		t.Errorf("merge after conflict failed: %v", err)
+	}
+}
+
+func TestMergeConflictPathWithSpace(t *testing.T) {
+	workdir, revisions := testRepo(t)
+	// The branches x and y add the same file with other contents.
+	clone := filepath.Join(workdir, "main")
+	const file = "white paper.json"
+	var conflicting []string
+	for _, branch := range []string{"x", "y"} {
+		testGit(t, clone, "checkout", "-q", "-b", branch, "main")
+		if err := os.WriteFile(filepath.Join(clone, file), []byte(branch), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		testGit(t, clone, "add", file)
+		testGit(t, clone, "commit", "-q", "-m", branch)
+		conflicting = append(conflicting, testGit(t, clone, "rev-parse", branch))
+	}
+	testGit(t, clone, "checkout", "-q", "main")
+
+	err := testGitClient.mergeRevisions(
+		t.Context(), workdir, []string{"main", "x", "y"},
+		append([]string{revisions[0]}, conflicting...), testMergeOptions,
+		func(io.Reader, int) error { return nil },
+	)
+	var mce *MergeConflictError
+	if !errors.As(err, &mce) {
+		t.Fatalf("got error %v, want a merge conflict", err)
+	}
+	if mce.Branch != "y" || !slices.Equal(mce.Files, []string{file}) {
+		t.Errorf("got conflict of %s in %q", mce.Branch, mce.Files)
+	}
+	checkNoWorktrees(t, workdir)
+}
+
+func TestArchiveRevision(t *testing.T) {
+	workdir, revisions := testRepo(t)
+	stream := func(merge func(func(io.Reader, int) error) error) ([]byte, int) {
+		t.Helper()
+		var (
+			data    []byte
+			entries int
+		)
+		if err := merge(func(r io.Reader, n int) error {
+			var err error
+			data, err = io.ReadAll(r)
+			entries = n
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return data, entries
+	}
+	mo := mergeOptions{name: "test", email: "test@example.com"}
+	fast, fastEntries := stream(func(untar func(io.Reader, int) error) error {
+		return testGitClient.archiveRevision(t.Context(), workdir, revisions[1], untar)
+	})
+	merged, mergedEntries := stream(func(untar func(io.Reader, int) error) error {
+		return testGitClient.mergeRevisions(t.Context(), workdir, []string{"a"}, revisions[1:2], mo, untar)
+	})
+	if !bytes.Equal(fast, merged) || fastEntries != mergedEntries {
+		t.Errorf("fast path differs: %d bytes, %d entries, merged: %d bytes, %d entries",
+			len(fast), fastEntries, len(merged), mergedEntries)
+	}
+
+	// The fast path needs no temporary worktree.
+	t.Setenv("TMPDIR", filepath.Join(t.TempDir(), "missing"))
+	if again, _ := stream(func(untar func(io.Reader, int) error) error {
+		return testGitClient.archiveRevision(t.Context(), workdir, revisions[1], untar)
+	}); !bytes.Equal(again, fast) {
+		t.Error("fast path depends on a temporary worktree")
+	}
+	if err := testGitClient.mergeRevisions(
+		t.Context(), workdir, []string{"a"}, revisions[1:2], mo,
+		func(io.Reader, int) error { return nil },
+	); err == nil {
+		t.Error("merge without temporary directory succeeds")
+	}
+}
+
+func TestMergeRevisions(t *testing.T) {
+	workdir, revisions := testRepo(t)
+	var entries int
+	if err := testGitClient.mergeRevisions(
+		t.Context(), workdir, []string{"main", "a", "b"}, revisions, testMergeOptions,
+		func(r io.Reader, n int) error {
+			entries = n
+			_, err := io.Copy(io.Discard, r)
+			return err
+		},
+	); err != nil {
+		t.Fatal(err)
+	}
+	if entries != 3 {
+		t.Errorf("got %d entries, want 3", entries)
+	}
+	checkNoWorktrees(t, workdir)
+}
+
+func TestMergeRevisionsCancelled(t *testing.T) {
+	workdir, revisions := testRepo(t)
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	errGone := errors.New("client went away")
+	err := testGitClient.mergeRevisions(
+		ctx, workdir, []string{"main", "a", "b"}, revisions, testMergeOptions,
+		func(io.Reader, int) error {
+			// The client goes away while the merged tree is read.
+			cancel()
+			return errGone
+		},
+	)
+	if !errors.Is(err, errGone) {
+		t.Fatalf("got error %v, want %v", err, errGone)
+	}
+	checkNoWorktrees(t, workdir)
+}
+
+func BenchmarkMergeRevisions(b *testing.B) {
+	workdir, revisions := testRepo(b)
+	branches := []string{"main", "a", "b"}
+	discard := func(r io.Reader, _ int) error {
+		_, err := io.Copy(io.Discard, r)
+		return err
+	}
+	for b.Loop() {
+		if err := testGitClient.mergeRevisions(
+			b.Context(), workdir, branches, revisions, testMergeOptions, discard,
+		); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestUpdateBranchesShallow(t *testing.T) {
+	for _, depth := range []int{0, 1} {
+		t.Run(fmt.Sprintf("depth %d", depth), func(t *testing.T) {
+			origin, _ := testRepo(t)
+			upstream := filepath.Join(origin, "main")
+			workdir := t.TempDir()
+			co := checkoutOptions{depth: depth}
+			branches := []string{"main", "a"}
+			if err := testGitClient.initialCheckout(
+				t.Context(), "file://"+upstream, workdir, branches, co,
+			); err != nil {
+				t.Fatal(err)
+			}
+			// New commits on both branches.
+			want := map[string]string{}
+			for _, branch := range branches {
+				testGit(t, upstream, "checkout", "-q", branch)
+				testGit(t, upstream, "commit", "-q", "--allow-empty", "-m", "update "+branch)
+				want[branch] = testGit(t, upstream, "rev-parse", "HEAD")
+			}
+			refreshed, err := testGitClient.updateBranches(t.Context(), workdir, branches, co)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(refreshed, branches) {
+				t.Errorf("got refreshed %q, want %q", refreshed, branches)
+			}
+			for _, branch := range branches {
+				dir := filepath.Join(workdir, branch)
+				if got := testGit(t, dir, "rev-parse", "HEAD"); got != want[branch] {
+					t.Errorf("%s: got revision %s, want %s", branch, got, want[branch])
+				}
+				count := testGit(t, dir, "rev-list", "--count", "HEAD")
+				if depth > 0 && count != strconv.Itoa(depth) {
+					t.Errorf("%s: history has %s commits, want %d", branch, count, depth)
+				}
+			}
+		})
+	}
+}
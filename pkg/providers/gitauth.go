@@ -0,0 +1,107 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+// defaultGitUsername is used with a token if no username is configured.
+const defaultGitUsername = "git"
+
+// gitAuth are the credentials to access the git remote.
+type gitAuth struct {
+	// env passes the authorization header to git. Passing it
+	// in the environment keeps it out of the process list.
+	env []string
+	// secret is scrubbed from the logged outputs of git.
+	secret string
+}
+
+// gitClient runs the git commands with the credentials
+// to access the git remote.
+type gitClient struct {
+	auth atomic.Pointer[gitAuth]
+	// worktrees serializes the worktree commands.
+	worktrees sync.Mutex
+}
+
+// newGitClient returns a git client with the configured credentials.
+func newGitClient(cfg *config.Providers) *gitClient {
+	g := new(gitClient)
+	g.configure(cfg)
+	return g
+}
+
+// configure sets the credentials to access the git remote.
+func (g *gitClient) configure(cfg *config.Providers) {
+	if cfg.GitToken == "" {
+		g.auth.Store(nil)
+		return
+	}
+	username := cfg.GitUsername
+	if username == "" {
+		username = defaultGitUsername
+	}
+	basic := base64.StdEncoding.EncodeToString([]byte(username + ":" + cfg.GitToken))
+	g.auth.Store(&gitAuth{
+		env: []string{
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic " + basic,
+			// Fail instead of prompting for credentials.
+			"GIT_TERMINAL_PROMPT=0",
+		},
+		secret: cfg.GitToken,
+	})
+}
+
+// UpdateGitCredentials replaces the credentials used by
+// the git commands started from now on.
+func (s *System) UpdateGitCredentials(cfg *config.Providers) {
+	s.git.configure(cfg)
+}
+
+// command returns a git command with the configured credentials.
+// The command is killed if the context is done.
+func (g *gitClient) command(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	if a := g.auth.Load(); a != nil {
+		cmd.Env = append(os.Environ(), a.env...)
+	}
+	return cmd
+}
+
+// userInfoRe matches the credentials in URLs.
+var userInfoRe = regexp.MustCompile(`://[^/@\s]+@`)
+
+// scrub removes credentials from the output of git to be logged.
+func (g *gitClient) scrub(output []byte) string {
+	s := userInfoRe.ReplaceAllString(string(output), "://***@")
+	if a := g.auth.Load(); a != nil {
+		s = strings.ReplaceAll(s, a.secret, "***")
+	}
+	return s
+}
+
+// scrubURL removes the credentials from a URL to be logged.
+func (g *gitClient) scrubURL(url string) string {
+	return g.scrub([]byte(url))
+}
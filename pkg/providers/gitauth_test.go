@@ -0,0 +1,73 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"encoding/base64"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+func TestGitClientCredentials(t *testing.T) {
+	a := newGitClient(&config.Providers{GitUsername: "alice", GitToken: "token-a"})
+	b := newGitClient(&config.Providers{GitToken: "token-b"})
+	none := newGitClient(&config.Providers{})
+
+	header := func(g *gitClient) string {
+		for _, env := range g.command(t.Context(), "version").Env {
+			if value, ok := strings.CutPrefix(env, "GIT_CONFIG_VALUE_0="); ok {
+				return value
+			}
+		}
+		return ""
+	}
+	basic := func(userPassword string) string {
+		return "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(userPassword))
+	}
+	if got, want := header(a), basic("alice:token-a"); got != want {
+		t.Errorf("client a: got header %q, want %q", got, want)
+	}
+	if got, want := header(b), basic(defaultGitUsername+":token-b"); got != want {
+		t.Errorf("client b: got header %q, want %q", got, want)
+	}
+	if env := none.command(t.Context(), "version").Env; env != nil {
+		t.Errorf("client without credentials has environment %q", env)
+	}
+
+	// Updating one client leaves the others alone.
+	a.configure(&config.Providers{GitToken: "token-c"})
+	if got, want := header(a), basic(defaultGitUsername+":token-c"); got != want {
+		t.Errorf("updated client a: got header %q, want %q", got, want)
+	}
+	if got, want := header(b), basic(defaultGitUsername+":token-b"); got != want {
+		t.Errorf("client b after update of a: got header %q, want %q", got, want)
+	}
+
+	output := []byte("fatal: https://user:pw@example.com token-b token-c")
+	for _, check := range []struct {
+		g    *gitClient
+		want string
+	}{
+		{a, "fatal: https://***@example.com token-b ***"},
+		{b, "fatal: https://***@example.com *** token-c"},
+		{none, "fatal: https://***@example.com token-b token-c"},
+	} {
+		if got := check.g.scrub(output); got != check.want {
+			t.Errorf("got scrubbed %q, want %q", got, check.want)
+		}
+	}
+	if !slices.Contains(a.command(t.Context(), "version").Env, "GIT_TERMINAL_PROMPT=0") {
+		t.Error("git may prompt for credentials")
+	}
+}
@@ -0,0 +1,119 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// errNotChecked is reported as long as the git remote was not checked.
+var errNotChecked = errors.New("git remote not checked yet")
+
+// updateStatus is the result of the last update of the branches.
+type updateStatus struct {
+	updated time.Time
+	err     error
+}
+
+// upstreamStatus is the result of a reachability check of the git remote.
+type upstreamStatus struct {
+	checked time.Time
+	err     error
+}
+
+// watchUpstream periodically checks if the git remote is reachable.
+// Meant to be run in a Go routine.
+func (s *System) watchUpstream(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Providers.GitCheck)
+	defer ticker.Stop()
+	for {
+		s.checkUpstream(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkUpstream checks once if the git remote is reachable
+// and stores the result.
+func (s *System) checkUpstream(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Providers.GitCheckTimeout)
+	defer cancel()
+	err := s.git.pingRemote(ctx, s.cfg.Providers.GitURL)
+	if prev := s.upstream.Load(); err != nil && (prev == nil || prev.err == nil) {
+		slog.Warn("git remote is not reachable", "url", s.git.scrubURL(s.cfg.Providers.GitURL), "error", err)
+	} else if err == nil && prev != nil && prev.err != nil {
+		slog.Info("git remote is reachable again", "url", s.git.scrubURL(s.cfg.Providers.GitURL))
+	}
+	s.upstream.Store(&upstreamStatus{checked: time.Now(), err: err})
+}
+
+// Status is the state of the system reported by the readiness endpoint.
+type Status struct {
+	// LastUpdate is the time of the last update of the branches.
+	// The initial checkout counts as the first one.
+	LastUpdate time.Time `json:"last_update"`
+	// LastError is the reason why the system is not ready.
+	LastError string `json:"last_error,omitempty"`
+	// ProfilesReady is the number of instantiated profiles.
+	ProfilesReady int `json:"profiles_ready"`
+	// SigningKey is the default signing key.
+	SigningKey KeyInfo `json:"signing_key"`
+}
+
+// Status returns the state of the system. The error
+// is the reason why the system is not ready if any.
+func (s *System) Status() (Status, error) {
+	var status Status
+	if update := s.updated.Load(); update != nil {
+		status.LastUpdate = update.updated
+	}
+	for profile := range s.Profiles() {
+		if _, ok := s.CurrentExport(profile); ok {
+			status.ProfilesReady++
+		}
+	}
+	status.SigningKey = s.SigningKeyInfo()
+	err := s.Ready()
+	if err != nil {
+		status.LastError = err.Error()
+	}
+	return status, err
+}
+
+// Ready checks if the system is able to build profiles.
+// This is the case if the default signing key is not expired,
+// the last update of the branches succeeded and the git remote
+// was reachable at the last check.
+func (s *System) Ready() error {
+	if info := s.SigningKeyInfo(); info.Expired {
+		return fmt.Errorf("signing key %s is expired", info.KeyID)
+	}
+	if update := s.updated.Load(); update != nil && update.err != nil {
+		return fmt.Errorf("updating branches failed (at %s): %w",
+			update.updated.Format(time.RFC3339), update.err)
+	}
+	status := s.upstream.Load()
+	if status == nil {
+		return errNotChecked
+	}
+	if status.err != nil {
+		return fmt.Errorf("git remote not reachable (checked %s): %w",
+			status.checked.Format(time.RFC3339), status.err)
+	}
+	return nil
+}
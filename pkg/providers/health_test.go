@@ -0,0 +1,73 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+// captureLog collects the log output of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(old) })
+	return &buf
+}
+
+func TestReadyUpstream(t *testing.T) {
+	origin := testOrigin(t, map[string]map[string]string{
+		"main": {"white/advisory.json": `{}`},
+	})
+	cfg := testConfig(t, origin, testKey(t), config.Profiles{
+		"main": {Branches: []string{"main"}},
+	})
+	// The upstream is checked by hand instead of running the system.
+	s, err := NewSystem(t.Context(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Ready(); !errors.Is(err, errNotChecked) {
+		t.Errorf("unchecked: got %v, want %v", err, errNotChecked)
+	}
+	s.checkUpstream(t.Context())
+	if err := s.Ready(); err != nil {
+		t.Errorf("reachable remote: got %v", err)
+	}
+
+	log := captureLog(t)
+	cfg.Providers.GitURL = "file://" + filepath.Join(t.TempDir(), "missing")
+	s.checkUpstream(t.Context())
+	if err := s.Ready(); err == nil || !strings.Contains(err.Error(), "git remote not reachable") {
+		t.Errorf("unreachable remote: got %v", err)
+	}
+	if !strings.Contains(log.String(), "git remote is not reachable") {
+		t.Errorf("missing warning in log: %s", log)
+	}
+
+	// The recovery is reported, too.
+	cfg.Providers.GitURL = "file://" + origin
+	s.checkUpstream(t.Context())
+	if err := s.Ready(); err != nil {
+		t.Errorf("recovered remote: got %v", err)
+	}
+	if !strings.Contains(log.String(), "git remote is reachable again") {
+		t.Errorf("missing recovery in log: %s", log)
+	}
+}
@@ -0,0 +1,62 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// exports returns the build information of all exports
+// of a profile in the web root, newest first.
+func (s *System) exports(profile string) []*BuildInfo {
+	entries, err := os.ReadDir(s.cfg.Web.Root)
+	if err != nil {
+		slog.Error("reading web root failed", "error", err)
+		return nil
+	}
+	var infos []*BuildInfo
+	for _, entry := range entries {
+		if !entry.IsDir() || !hashRe.MatchString(entry.Name()) {
+			continue
+		}
+		fname := filepath.Join(s.cfg.Web.Root, entry.Name(), buildInfoFile)
+		if bi, err := loadBuildInfo(fname); err == nil && bi.Profile == profile {
+			infos = append(infos, bi)
+		}
+	}
+	slices.SortFunc(infos, func(a, b *BuildInfo) int {
+		return b.Created.Compare(a.Created)
+	})
+	return infos
+}
+
+// shelveProfile removes the symlink to the export of a profile
+// but keeps the export as history. Only the configured number
+// of old exports of the profile are kept, the others are removed
+// after the delete grace like the exports of removed profiles.
+func (s *System) shelveProfile(profile string) {
+	link := filepath.Join(s.cfg.Web.Root, profile)
+	if info, err := os.Lstat(link); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(link); err != nil {
+			slog.Error("removing link to profile failed", "error", err, "branch", profile)
+		}
+	}
+	infos := s.exports(profile)
+	if len(infos) <= s.cfg.Providers.KeepExports {
+		return
+	}
+	for _, bi := range infos[s.cfg.Providers.KeepExports:] {
+		s.removeExport(profile, bi.Hash, s.cfg.Providers.DeleteGrace)
+	}
+}
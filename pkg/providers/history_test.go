@@ -0,0 +1,102 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeExports writes n exports of a profile into the web root, the
+// last one being the newest, and links the profile to it.
+func writeExports(t *testing.T, s *System, profile string, n int) []string {
+	t.Helper()
+	created := time.Now().Add(-time.Hour)
+	var hashes []string
+	for i := range n {
+		hash := fmt.Sprintf("%040x", i+1)
+		dir := filepath.Join(s.cfg.Web.Root, hash)
+		if err := os.Mkdir(dir, 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeBuildInfo(filepath.Join(dir, buildInfoFile), &BuildInfo{
+			Profile: profile,
+			Hash:    hash,
+			Created: created.Add(time.Duration(i) * time.Minute),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, hash)
+	}
+	if err := os.Symlink(filepath.Join(s.cfg.Web.Root, hashes[n-1]),
+		filepath.Join(s.cfg.Web.Root, profile)); err != nil {
+		t.Fatal(err)
+	}
+	return hashes
+}
+
+func exists(t *testing.T, s *System, hash string) bool {
+	t.Helper()
+	_, err := os.Stat(filepath.Join(s.cfg.Web.Root, hash))
+	return err == nil
+}
+
+func TestShelveProfileGrace(t *testing.T) {
+	s := testSystem(t)
+	s.cfg.Providers.KeepExports = 1
+	s.cfg.Providers.DeleteGrace = time.Minute
+	hashes := writeExports(t, s, "profile", 3)
+
+	s.shelveProfile("profile")
+	if _, err := os.Lstat(filepath.Join(s.cfg.Web.Root, "profile")); err == nil {
+		t.Error("link to the shelved profile is left")
+	}
+	// The old exports are kept until their grace is over.
+	for _, hash := range hashes {
+		if !exists(t, s, hash) {
+			t.Errorf("export %s removed before its grace", hash)
+		}
+	}
+	for _, hash := range hashes[:2] {
+		if _, ok := s.retired[hash]; !ok {
+			t.Errorf("export %s not scheduled for removal", hash)
+		}
+	}
+	if _, ok := s.retired[hashes[2]]; ok {
+		t.Error("kept export scheduled for removal")
+	}
+	s.removeRetired(true)
+	for i, hash := range hashes {
+		if got, want := exists(t, s, hash), i == 2; got != want {
+			t.Errorf("export %s exists: %t, want %t", hash, got, want)
+		}
+	}
+}
+
+func TestShelveProfileNoGrace(t *testing.T) {
+	s := testSystem(t)
+	s.cfg.Providers.KeepExports = 2
+	s.cfg.Providers.DeleteGrace = 0
+	hashes := writeExports(t, s, "profile", 3)
+
+	s.shelveProfile("profile")
+	for i, hash := range hashes {
+		if got, want := exists(t, s, hash), i > 0; got != want {
+			t.Errorf("export %s exists: %t, want %t", hash, got, want)
+		}
+	}
+	if len(s.retired) != 0 {
+		t.Errorf("%d exports scheduled without grace", len(s.retired))
+	}
+}
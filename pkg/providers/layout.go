@@ -0,0 +1,224 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+var (
+	yearRe  = regexp.MustCompile(`^[0-9]{4}$`)
+	monthRe = regexp.MustCompile(`^[0-9]{2}$`)
+)
+
+// advisory is an advisory found in an export.
+type advisory struct {
+	path           string
+	initialRelease time.Time
+	currentRelease time.Time
+}
+
+// distribution are the advisories of a directory distribution.
+type distribution struct {
+	root       string
+	advisories []*advisory
+	// moved is true if advisories were filed under other folders.
+	moved bool
+}
+
+// parseAdvisory extracts the release dates of an advisory.
+// It returns false if the file is no advisory.
+func parseAdvisory(data []byte) (*advisory, bool) {
+	var doc struct {
+		Document struct {
+			Tracking struct {
+				InitialReleaseDate time.Time `json:"initial_release_date"`
+				CurrentReleaseDate time.Time `json:"current_release_date"`
+			} `json:"tracking"`
+		} `json:"document"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil ||
+		doc.Document.Tracking.InitialReleaseDate.IsZero() {
+		return nil, false
+	}
+	tracking := &doc.Document.Tracking
+	return &advisory{
+		initialRelease: tracking.InitialReleaseDate,
+		currentRelease: cmp.Or(tracking.CurrentReleaseDate, tracking.InitialReleaseDate),
+	}, true
+}
+
+// folder returns the relative folder an advisory is filed
+// under in the given layout.
+func (adv *advisory) folder(layout string) string {
+	t := adv.initialRelease.UTC()
+	if layout == config.LayoutMonth {
+		return fmt.Sprintf("%04d/%02d", t.Year(), t.Month())
+	}
+	return fmt.Sprintf("%04d", t.Year())
+}
+
+// distributionRoot returns the root of the directory distribution
+// of an advisory in the given folder and if the advisory is
+// already filed under a folder of the layout. Advisories filed
+// under the folders of the other layout are filed again.
+func distributionRoot(dir, layout string) (string, bool) {
+	parent := filepath.Dir(dir)
+	switch {
+	case monthRe.MatchString(filepath.Base(dir)) && yearRe.MatchString(filepath.Base(parent)):
+		return filepath.Dir(parent), layout == config.LayoutMonth
+	case yearRe.MatchString(filepath.Base(dir)):
+		return parent, layout == config.LayoutYear
+	default:
+		return dir, false
+	}
+}
+
+// fileAdvisories files the advisories of an export under year
+// or year/month folders of their initial release dates. The
+// signatures and hashes coming with them are moved along.
+// An index.txt and a changes.csv are written into the roots
+// of the directory distributions if they are not there or
+// advisories of the distribution were moved. If seed is
+// not nil the entries of these files are shuffled with it.
+func fileAdvisories(targetDir, layout string, seed *uint64, cache *contentCache) error {
+	dists := map[string]*distribution{}
+	type move struct {
+		adv *advisory
+		dst string
+	}
+	var moves []move
+	if err := filepath.Walk(targetDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		data, ok := cache.load(path)
+		if !ok {
+			buf, err := readFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read file: %w", err)
+			}
+			defer putReadBuffer(buf)
+			data = buf.Bytes()
+		}
+		adv, ok := parseAdvisory(data)
+		if !ok {
+			return nil
+		}
+		adv.path = path
+		root, filed := distributionRoot(filepath.Dir(path), layout)
+		dist := dists[root]
+		if dist == nil {
+			dist = &distribution{root: root}
+			dists[root] = dist
+		}
+		dist.advisories = append(dist.advisories, adv)
+		if !filed {
+			dst := filepath.Join(root, filepath.FromSlash(adv.folder(layout)), info.Name())
+			moves = append(moves, move{adv, dst})
+			dist.moved = true
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("collecting advisories failed: %w", err)
+	}
+	for _, m := range moves {
+		if err := os.MkdirAll(filepath.Dir(m.dst), 0777); err != nil {
+			return fmt.Errorf("creating folder failed: %w", err)
+		}
+		for _, ext := range []string{"", ".asc", ".sha256", ".sha512"} {
+			switch err := os.Rename(m.adv.path+ext, m.dst+ext); {
+			case ext != "" && errors.Is(err, os.ErrNotExist):
+			case err != nil:
+				return fmt.Errorf("filing advisory failed: %w", err)
+			}
+		}
+		slog.Debug("filed advisory", "from", m.adv.path, "to", m.dst)
+		cache.rename(m.adv.path, m.dst)
+		m.adv.path = m.dst
+	}
+	for _, dist := range dists {
+		var shuffle *rand.Rand
+		if seed != nil {
+			shuffle = rand.New(rand.NewPCG(*seed, 0))
+		}
+		if err := dist.writeIndexes(shuffle); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeIndexes writes the index.txt and the changes.csv
+// of a directory distribution. Existing ones are only
+// replaced if advisories were moved. If shuffle is not nil
+// the index.txt is written in random order and the entries
+// of the changes.csv with the same release date are shuffled
+// so that clients relying on a sorted listing are detected.
+func (dist *distribution) writeIndexes(shuffle *rand.Rand) error {
+	advs := make([]*advisory, 0, len(dist.advisories))
+	for _, adv := range dist.advisories {
+		rel, err := filepath.Rel(dist.root, adv.path)
+		if err != nil {
+			return err
+		}
+		advs = append(advs, &advisory{
+			path:           filepath.ToSlash(rel),
+			currentRelease: adv.currentRelease,
+		})
+	}
+	var index, changes bytes.Buffer
+	slices.SortFunc(advs, func(a, b *advisory) int { return strings.Compare(a.path, b.path) })
+	if shuffle != nil {
+		shuffle.Shuffle(len(advs), func(i, j int) { advs[i], advs[j] = advs[j], advs[i] })
+	}
+	for _, adv := range advs {
+		fmt.Fprintln(&index, adv.path)
+	}
+	// The changes are ordered from the newest to the oldest.
+	// The sort is stable to keep the shuffled order of equal dates.
+	slices.SortStableFunc(advs, func(a, b *advisory) int {
+		return b.currentRelease.Compare(a.currentRelease)
+	})
+	for _, adv := range advs {
+		fmt.Fprintf(&changes, "%q,%q\n", adv.path, adv.currentRelease.UTC().Format(time.RFC3339))
+	}
+	for name, content := range map[string][]byte{
+		"index.txt":   index.Bytes(),
+		"changes.csv": changes.Bytes(),
+	} {
+		fname := filepath.Join(dist.root, name)
+		if !dist.moved && !checkFileNotExists(fname) {
+			continue
+		}
+		if err := os.WriteFile(fname, content, 0644); err != nil {
+			return fmt.Errorf("writing %s failed: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+// testAdvisory returns an advisory with an id and release dates.
+func testAdvisory(id, initial, current string) string {
+	return `{"document":{"tracking":{"id":"` + id + `",` +
+		`"initial_release_date":"` + initial + `",` +
+		`"current_release_date":"` + current + `"}}}`
+}
+
+func TestDirectoryLayout(t *testing.T) {
+	origin := testOrigin(t, map[string]map[string]string{
+		"main": {
+			"white/a.json":      testAdvisory("a", "2023-05-01T00:00:00Z", "2024-06-01T00:00:00Z"),
+			"white/b.json":      testAdvisory("b", "2024-03-10T00:00:00Z", "2024-03-10T00:00:00Z"),
+			"white/2023/c.json": testAdvisory("c", "2023-11-20T00:00:00Z", "2023-12-01T00:00:00Z"),
+			"white/other.json":  `{"document":{}}`,
+		},
+	})
+	main := []string{"main"}
+	key := testKey(t)
+	s := startSystem(t, testConfig(t, origin, key, config.Profiles{
+		"year":  {Branches: main, DirectoryLayout: config.LayoutYear},
+		"month": {Branches: main, DirectoryLayout: config.LayoutMonth},
+	}))
+	for _, check := range []struct {
+		profile string
+		files   []string
+		index   string
+		changes string
+	}{{
+		profile: "year",
+		files:   []string{"2023/a.json", "2024/b.json", "2023/c.json", "other.json"},
+		index:   "2023/a.json\n2023/c.json\n2024/b.json\n",
+		changes: `"2023/a.json","2024-06-01T00:00:00Z"` + "\n" +
+			`"2024/b.json","2024-03-10T00:00:00Z"` + "\n" +
+			`"2023/c.json","2023-12-01T00:00:00Z"` + "\n",
+	}, {
+		profile: "month",
+		files:   []string{"2023/05/a.json", "2024/03/b.json", "2023/11/c.json", "other.json"},
+		index:   "2023/05/a.json\n2023/11/c.json\n2024/03/b.json\n",
+		changes: `"2023/05/a.json","2024-06-01T00:00:00Z"` + "\n" +
+			`"2024/03/b.json","2024-03-10T00:00:00Z"` + "\n" +
+			`"2023/11/c.json","2023-12-01T00:00:00Z"` + "\n",
+	}} {
+		white := filepath.Join(serve(t, s, check.profile), "white")
+		for _, file := range check.files {
+			fname := filepath.Join(white, filepath.FromSlash(file))
+			// The relocated files are signed and hashed.
+			for _, ext := range []string{"", ".asc", ".sha256", ".sha512"} {
+				if checkFileNotExists(fname + ext) {
+					t.Fatalf("%s: %s%s is missing", check.profile, file, ext)
+				}
+			}
+			if !verifies(t, fname, key) {
+				t.Errorf("%s: signature of %s does not verify", check.profile, file)
+			}
+		}
+		for _, gone := range []string{"a.json", "b.json", "a.json.asc"} {
+			if !checkFileNotExists(filepath.Join(white, gone)) {
+				t.Errorf("%s: %s is left in place", check.profile, gone)
+			}
+		}
+		for name, want := range map[string]string{
+			"index.txt":   check.index,
+			"changes.csv": check.changes,
+		} {
+			got, err := os.ReadFile(filepath.Join(white, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want {
+				t.Errorf("%s: got %s\n%s\nwant\n%s", check.profile, name, got, want)
+			}
+		}
+	}
+}
+
+func TestShuffledIndexes(t *testing.T) {
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	write := func(seed *uint64) (index, changes []string) {
+		t.Helper()
+		dist := &distribution{root: t.TempDir(), moved: true}
+		for i := range 20 {
+			dist.advisories = append(dist.advisories, &advisory{
+				path: filepath.Join(dist.root, "2024", fmt.Sprintf("advisory-%02d.json", i)),
+				// Every second advisory is released on the same day.
+				currentRelease: date.AddDate(0, 0, i%2*i),
+			})
+		}
+		var shuffle *rand.Rand
+		if seed != nil {
+			shuffle = rand.New(rand.NewPCG(*seed, 0))
+		}
+		if err := dist.writeIndexes(shuffle); err != nil {
+			t.Fatal(err)
+		}
+		read := func(name string) []string {
+			data, err := os.ReadFile(filepath.Join(dist.root, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		}
+		return read("index.txt"), read("changes.csv")
+	}
+	sortedIndex, sortedChanges := write(nil)
+	if !slices.IsSorted(sortedIndex) {
+		t.Fatalf("index without seed is not sorted: %q", sortedIndex)
+	}
+	seed := uint64(42)
+	index, changes := write(&seed)
+	if againIndex, againChanges := write(&seed); !slices.Equal(index, againIndex) ||
+		!slices.Equal(changes, againChanges) {
+		t.Error("indexes with the same seed differ")
+	}
+	if slices.Equal(index, sortedIndex) {
+		t.Error("shuffled index is sorted")
+	}
+	if slices.Equal(changes, sortedChanges) {
+		t.Error("shuffled changes are not shuffled")
+	}
+	// The shuffled files list the same entries and keep the
+	// changes ordered from the newest to the oldest.
+	if !slices.Equal(slices.Sorted(slices.Values(index)), sortedIndex) {
+		t.Errorf("shuffled index has other entries: %q", index)
+	}
+	dates := func(changes []string) []string {
+		var dates []string
+		for _, line := range changes {
+			_, date, _ := strings.Cut(line, ",")
+			dates = append(dates, date)
+		}
+		return dates
+	}
+	if got, want := dates(changes), dates(sortedChanges); !slices.Equal(got, want) {
+		t.Errorf("shuffled changes are out of order: %q", changes)
+	}
+}
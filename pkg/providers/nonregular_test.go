@@ -0,0 +1,74 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+//go:build linux || darwin || freebsd
+
+package providers
+
+import (
+	"archive/tar"
+	"bytes"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+func TestSkipNonRegular(t *testing.T) {
+	log := captureLog(t)
+	// A branch with entries which are neither files nor directories.
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	content := `{"document":{}}`
+	for _, hdr := range []*tar.Header{
+		{Typeflag: tar.TypeDir, Name: "data/white/", Mode: 0o777},
+		{Typeflag: tar.TypeFifo, Name: "data/white/fifo.json", Mode: 0o666},
+		{Typeflag: tar.TypeSymlink, Name: "data/white/link.json", Linkname: "/etc/passwd"},
+		{Typeflag: tar.TypeChar, Name: "data/white/null.json", Mode: 0o666, Devmajor: 1, Devminor: 3},
+		{Typeflag: tar.TypeReg, Name: "data/white/advisory.json", Mode: 0o666, Size: int64(len(content))},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := tw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	hashing := encloseHashFile(config.HashFormatCoreutils, []string{config.HashSHA256})
+	extractAndApply(t, dir, buf.Bytes(), jsonActions(hashing), 0)
+	want := []string{"white/advisory.json", "white/advisory.json.sha256"}
+	if got := slices.Sorted(maps.Keys(readTree(t, dir))); !slices.Equal(got, want) {
+		t.Errorf("got files %q, want %q", got, want)
+	}
+	if n := strings.Count(log.String(), "skipping non-regular tar entry"); n != 3 {
+		t.Errorf("got %d warnings, want 3:\n%s", n, log)
+	}
+
+	// A FIFO in the export is not opened by the actions.
+	fifo := filepath.Join(dir, "white", "pipe.json")
+	if err := syscall.Mkfifo(fifo, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := jsonActions(hashing).Apply(dir, 1<<20, nil); err != nil {
+		t.Fatal(err)
+	}
+	if checkFileNotExists(fifo+".sha256") == false {
+		t.Error("FIFO is hashed")
+	}
+}
@@ -0,0 +1,56 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+func TestPassthrough(t *testing.T) {
+	const committed = "committed signature"
+	origin := testOrigin(t, map[string]map[string]string{
+		"main": {
+			"white/signed.json":     `{}`,
+			"white/signed.json.asc": committed,
+			"white/unsigned.json":   `{}`,
+		},
+	})
+	main := []string{"main"}
+	cfg := testConfig(t, origin, testKey(t), config.Profiles{
+		"passthrough": {Branches: main, Passthrough: true},
+		"signed":      {Branches: main},
+	})
+	s := startSystem(t, cfg)
+
+	white := filepath.Join(serve(t, s, "passthrough"), "white")
+	if data, err := os.ReadFile(filepath.Join(white, "signed.json.asc")); err != nil {
+		t.Errorf("committed signature lost: %v", err)
+	} else if string(data) != committed {
+		t.Errorf("committed signature replaced by %q", data)
+	}
+	for _, file := range []string{"unsigned.json.asc", "unsigned.json.sha256", "signed.json.sha256"} {
+		if _, err := os.Stat(filepath.Join(white, file)); err == nil {
+			t.Errorf("%s generated in passthrough mode", file)
+		}
+	}
+
+	// Without passthrough the files are signed and hashed.
+	white = filepath.Join(serve(t, s, "signed"), "white")
+	for _, file := range []string{"unsigned.json.asc", "unsigned.json.sha256"} {
+		if _, err := os.Stat(filepath.Join(white, file)); err != nil {
+			t.Errorf("%s not generated: %v", file, err)
+		}
+	}
+}
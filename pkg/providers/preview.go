@@ -0,0 +1,224 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+// previewsDir is the folder in the web root storing the previews.
+const previewsDir = ".previews"
+
+var (
+	// ErrPreviewNotFound is returned if a preview does not exist or is expired.
+	ErrPreviewNotFound = errors.New("preview not found")
+	// ErrInvalidPreview is returned if a preview request is malformed.
+	ErrInvalidPreview = errors.New("invalid preview")
+)
+
+// tokenRe matches the tokens generated by [rand.Text].
+var tokenRe = regexp.MustCompile(`^[A-Z2-7]{26}$`)
+
+// previewDir returns the directory of the preview with the given token.
+func (s *System) previewDir(token string) string {
+	return filepath.Join(s.cfg.Web.Root, previewsDir, token)
+}
+
+// BuildPreview builds a preview of a profile with some of its
+// branches replaced by the given refs of the git remote. Like
+// [System.Serve] only the bookkeeping is done in the control
+// goroutine. The fetching, merging and signing run concurrently.
+func (s *System) BuildPreview(ctx context.Context, profile string, refs map[string]string) (*BuildInfo, error) {
+	profiles := s.Profiles()
+	if _, ok := profiles[profile]; !ok {
+		return nil, ErrProfileNotFound
+	}
+	branches := profiles.Branches(profile)
+	if len(branches) == 0 {
+		return nil, ErrProfileNotFound
+	}
+	for branch := range refs {
+		if !slices.Contains(branches, branch) {
+			return nil, fmt.Errorf("%w: branch %q is not part of profile %q",
+				ErrInvalidPreview, branch, profile)
+		}
+	}
+	var (
+		preview   *BuildInfo
+		targetDir string
+		revisions []string
+		key       *crypto.Key
+	)
+	if err := s.do(func(s *System) (err error) {
+		preview, targetDir, revisions, err = s.preparePreview(ctx, profile, branches, refs)
+		key = s.signingKey(profile)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	err := s.buildPreview(ctx, targetDir, preview, branches, revisions, key)
+	if err := s.do(func(s *System) error {
+		delete(s.pending, filepath.Join(previewsDir, preview.Token))
+		if err != nil {
+			// Ensure that the debris is always removed.
+			os.RemoveAll(targetDir)
+		}
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	slog.Info("built preview",
+		"profile", profile, "refs", refs, "expires", preview.Expires)
+	return preview, nil
+}
+
+// preparePreview runs in the control goroutine. It creates the
+// directory of the preview and returns it with the revisions of the
+// branches not replaced by refs. The revisions of the refs are left empty.
+func (s *System) preparePreview(
+	ctx context.Context,
+	profile string,
+	branches []string,
+	refs map[string]string,
+) (*BuildInfo, string, []string, error) {
+	if err := s.checkFreeSpace(); err != nil {
+		return nil, "", nil, err
+	}
+	revisions := make([]string, len(branches))
+	for i, branch := range branches {
+		if _, ok := refs[branch]; ok {
+			continue
+		}
+		rev, err := s.git.currentRevision(ctx, s.cfg.Providers.WorkDir, branch)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("revision of %q failed: %w", branch, err)
+		}
+		revisions[i] = hex.EncodeToString(rev)
+	}
+	now := time.Now()
+	preview := &BuildInfo{
+		Token:   rand.Text(),
+		Profile: profile,
+		Refs:    refs,
+		Created: now,
+		Expires: now.Add(s.cfg.Providers.PreviewTTL),
+	}
+	targetDir, err := filepath.Abs(s.previewDir(preview.Token))
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("unable to get abs path for preview: %w", err)
+	}
+	if err := os.MkdirAll(targetDir, 0777); err != nil {
+		return nil, "", nil, fmt.Errorf("creating preview directory failed: %w", err)
+	}
+	// Don't let the cleanup remove the preview while it is built.
+	s.pending[filepath.Join(previewsDir, preview.Token)] = true
+	return preview, targetDir, revisions, nil
+}
+
+// buildPreview fetches the refs of a preview and builds it into the
+// target directory. It runs concurrently to the control goroutine.
+func (s *System) buildPreview(
+	ctx context.Context,
+	targetDir string,
+	preview *BuildInfo,
+	branches, revisions []string,
+	key *crypto.Key,
+) error {
+	workdir := s.cfg.Providers.WorkDir
+	for i, branch := range branches {
+		if ref, ok := preview.Refs[branch]; ok {
+			rev, err := s.git.fetchRevision(ctx, workdir, ref)
+			if err != nil {
+				return fmt.Errorf("%w: %w", ErrInvalidPreview, err)
+			}
+			revisions[i] = rev
+		}
+	}
+	merge := func(untar func(io.Reader, int) error) error {
+		return s.git.mergeRevisions(ctx, workdir, branches, revisions, s.mergeOptions(), untar)
+	}
+	if err := s.export(targetDir, preview.Profile, "preview/"+preview.Token, key, merge); err != nil {
+		return err
+	}
+	return writeBuildInfo(filepath.Join(targetDir, buildInfoFile), preview)
+}
+
+// PreviewDir returns the directory of a preview which is not expired.
+func (s *System) PreviewDir(token string) (string, error) {
+	if !tokenRe.MatchString(token) {
+		return "", ErrPreviewNotFound
+	}
+	dir := s.previewDir(token)
+	preview, err := loadBuildInfo(filepath.Join(dir, buildInfoFile))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return "", ErrPreviewNotFound
+	case err != nil:
+		return "", err
+	case preview.Expired(time.Now()):
+		return "", ErrPreviewNotFound
+	}
+	return dir, nil
+}
+
+// cleanupPreviews removes the previews whose lifetime plus a grace period
+// for the requests still being served is over. Previews without build
+// information are debris of failed builds and are removed after the
+// same time based on their modification time.
+func (s *System) cleanupPreviews(now time.Time) {
+	dir := filepath.Join(s.cfg.Web.Root, previewsDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Error("reading previews failed", "error", err)
+		}
+		return
+	}
+	keep := s.cfg.Providers.PreviewTTL + s.cfg.Providers.PreviewGrace
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		// The previews being built are not finished yet.
+		if s.pending[filepath.Join(previewsDir, entry.Name())] {
+			continue
+		}
+		previewDir := filepath.Join(dir, entry.Name())
+		var created time.Time
+		if preview, err := loadBuildInfo(filepath.Join(previewDir, buildInfoFile)); err == nil {
+			created = preview.Created
+		} else if info, err := entry.Info(); err == nil {
+			created = info.ModTime()
+		} else {
+			continue
+		}
+		if now.Sub(created) <= keep {
+			continue
+		}
+		slog.Debug("removing expired preview", "token", entry.Name())
+		if err := os.RemoveAll(previewDir); err != nil {
+			slog.Error("removing expired preview failed", "error", err)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+// testSystem returns a system with the default config
+// and a temporary web root which is not run.
+func testSystem(t *testing.T) *System {
+	t.Helper()
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Web.Root = t.TempDir()
+	return &System{
+		cfg:     cfg,
+		retired: map[string]time.Time{},
+	}
+}
+
+// writePreview writes a preview created at the given time.
+func writePreview(t *testing.T, s *System, created time.Time) string {
+	t.Helper()
+	token := rand.Text()
+	dir := s.previewDir(token)
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeBuildInfo(filepath.Join(dir, buildInfoFile), &BuildInfo{
+		Profile: "main",
+		Token:   token,
+		Created: created,
+		Expires: created.Add(s.cfg.Providers.PreviewTTL),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestCleanupPreviews(t *testing.T) {
+	s := testSystem(t)
+	s.cfg.Providers.PreviewTTL = time.Hour
+	s.cfg.Providers.PreviewGrace = time.Minute
+	now := time.Now()
+
+	fresh := writePreview(t, s, now.Add(-30*time.Minute))
+	// Expired but still in the grace period for running downloads.
+	grace := writePreview(t, s, now.Add(-time.Hour-30*time.Second))
+	expired := writePreview(t, s, now.Add(-2*time.Hour))
+	// Debris of a failed build without build info.
+	debris := s.previewDir("debris")
+	if err := os.MkdirAll(debris, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	old := now.Add(-2 * time.Hour)
+	if err := os.Chtimes(debris, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.PreviewDir(fresh); err != nil {
+		t.Errorf("fresh preview not served: %v", err)
+	}
+	if _, err := s.PreviewDir(grace); !errors.Is(err, ErrPreviewNotFound) {
+		t.Errorf("expired preview served: %v", err)
+	}
+
+	s.cleanupPreviews(now)
+	for _, check := range []struct {
+		name string
+		dir  string
+		keep bool
+	}{
+		{"fresh", s.previewDir(fresh), true},
+		{"grace", s.previewDir(grace), true},
+		{"expired", s.previewDir(expired), false},
+		{"debris", debris, false},
+	} {
+		if _, err := os.Stat(check.dir); (err == nil) != check.keep {
+			t.Errorf("%s preview: kept %t, want %t", check.name, err == nil, check.keep)
+		}
+	}
+}
+
+func TestConcurrentPreviews(t *testing.T) {
+	branches := map[string]map[string]string{
+		"main": {"white/advisory.json": `{"document":{}}`},
+	}
+	for i := range 4 {
+		branches["feature"+strconv.Itoa(i)] = map[string]string{
+			"white/advisory.json": `{"document":{"feature":` + strconv.Itoa(i) + `}}`,
+		}
+	}
+	origin := testOrigin(t, branches)
+	s := startSystem(t, testConfig(t, origin, testKey(t), config.Profiles{
+		"main": {Branches: []string{"main"}},
+	}))
+	// The previews fetch and build concurrently.
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Go(func() {
+			ref := "feature" + strconv.Itoa(i)
+			preview, err := s.BuildPreview(t.Context(), "main", map[string]string{"main": ref})
+			if err != nil {
+				t.Errorf("%s: %v", ref, err)
+				return
+			}
+			dir, err := s.PreviewDir(preview.Token)
+			if err != nil {
+				t.Errorf("%s: %v", ref, err)
+				return
+			}
+			data, err := os.ReadFile(filepath.Join(dir, "white", "advisory.json"))
+			if err != nil {
+				t.Errorf("%s: %v", ref, err)
+				return
+			}
+			if want := `{"document":{"feature":` + strconv.Itoa(i) + `}}`; string(data) != want {
+				t.Errorf("%s: got %q, want %q", ref, data, want)
+			}
+		})
+	}
+	wg.Wait()
+	// The fetched refs are not kept.
+	if refs := testGit(t, filepath.Join(s.cfg.Providers.WorkDir, "main"),
+		"for-each-ref", "refs/previews/"); refs != "" {
+		t.Errorf("fetched refs are kept:\n%s", refs)
+	}
+}
@@ -0,0 +1,109 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+// UpdateProfiles replaces the served profiles. Worktrees of new
+// branches are added and the exports of removed and changed profiles
+// are deleted. Unchanged profiles stay instantiated.
+func (s *System) UpdateProfiles(ctx context.Context, profiles config.Profiles) error {
+	if len(profiles) == 0 {
+		return errors.New("no profiles configured")
+	}
+	result := make(chan error)
+	s.fns <- func(s *System) {
+		result <- s.updateProfiles(ctx, profiles)
+	}
+	return <-result
+}
+
+func (s *System) updateProfiles(ctx context.Context, profiles config.Profiles) error {
+	keys, err := loadProfileKeys(profiles, s.namedKeys)
+	if err != nil {
+		return err
+	}
+	if err := s.git.addWorktrees(ctx, s.cfg.Providers.WorkDir, profiles.AllBranches(), newCheckoutOptions(&s.cfg.Providers)); err != nil {
+		return fmt.Errorf("updating profiles failed: %w", err)
+	}
+	old := s.Profiles()
+	s.keys = keys
+	s.profiles.Store(&profiles)
+
+	for name := range old {
+		if exportChanged(old, profiles, name) {
+			slog.Info("profile changed", "profile", name)
+			s.purgeProfile(name)
+		}
+		if profiles[name] == nil {
+			s.forgetServed(name)
+		}
+	}
+	// Remove the worktrees no longer needed.
+	var (
+		all    = profiles.AllBranches()
+		unused []string
+	)
+	for _, branch := range old.AllBranches() {
+		if _, found := slices.BinarySearch(all, branch); !found {
+			unused = append(unused, branch)
+		}
+	}
+	if err := s.git.removeWorktrees(ctx, s.cfg.Providers.WorkDir, unused); err != nil {
+		slog.Error("removing unused worktrees failed", "error", err)
+	}
+	return nil
+}
+
+// exportChanged checks if the export of a profile is different
+// with the new profiles or if the profile is removed.
+func exportChanged(old, profiles config.Profiles, name string) bool {
+	o, n := old[name], profiles[name]
+	return n == nil ||
+		o.Key != n.Key ||
+		o.Passphrase != n.Passphrase ||
+		o.SigningKey != n.SigningKey ||
+		o.Passthrough != n.Passthrough ||
+		!slices.Equal(o.Hashes, n.Hashes) ||
+		o.DirectoryLayout != n.DirectoryLayout ||
+		!equalSeed(o.ShuffleSeed, n.ShuffleSeed) ||
+		o.BaseURL != n.BaseURL ||
+		!maps.Equal(o.TemplateVars, n.TemplateVars) ||
+		!slices.Equal(old.Branches(name), profiles.Branches(name))
+}
+
+// equalSeed checks if two optional seeds are the same.
+func equalSeed(a, b *uint64) bool {
+	return a == b || a != nil && b != nil && *a == *b
+}
+
+// purgeProfile removes the current and all kept exports of a profile.
+func (s *System) purgeProfile(profile string) {
+	// The kept exports are removed right away so that none of them
+	// is reused for the changed profile.
+	s.removeProfile(profile, 0)
+	for _, bi := range s.exports(profile) {
+		if err := os.RemoveAll(filepath.Join(s.cfg.Web.Root, bi.Hash)); err != nil {
+			slog.Error("removing old export failed", "error", err, "profile", profile)
+		}
+	}
+}
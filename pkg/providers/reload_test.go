@@ -0,0 +1,58 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+func TestUpdateProfiles(t *testing.T) {
+	origin := testOrigin(t, map[string]map[string]string{
+		"main":  {"white/main.json": `{}`},
+		"extra": {"white/extra.json": `{}`},
+	})
+	cfg := testConfig(t, origin, testKey(t), config.Profiles{
+		"main": {Branches: []string{"main"}},
+	})
+	s := startSystem(t, cfg)
+	serve(t, s, "main")
+	if err := s.Serve(t.Context(), "extra"); !errors.Is(err, ErrProfileNotFound) {
+		t.Fatalf("unconfigured profile: got error %v, want %v", err, ErrProfileNotFound)
+	}
+
+	// A new profile with a branch not checked out yet replaces the old one.
+	if err := s.UpdateProfiles(t.Context(), config.Profiles{
+		"extra": {Branches: []string{"main", "extra"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	export := serve(t, s, "extra")
+	for _, file := range []string{"main.json", "extra.json"} {
+		if _, err := os.Stat(filepath.Join(export, "white", file)); err != nil {
+			t.Errorf("new profile: %v", err)
+		}
+	}
+	if err := s.Serve(t.Context(), "main"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("removed profile: got error %v, want %v", err, ErrProfileNotFound)
+	}
+	if s.instantiated("main") {
+		t.Error("removed profile is still exported")
+	}
+
+	if err := s.UpdateProfiles(t.Context(), nil); err == nil {
+		t.Error("removing all profiles is accepted")
+	}
+}
@@ -13,20 +13,26 @@ package providers
 
 import (
 	"context"
-	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"maps"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/ProtonMail/gopenpgp/v3/crypto"
 	"github.com/csaf-testsuite/contravider/pkg/config"
+	"github.com/csaf-testsuite/contravider/pkg/metrics"
+	"golang.org/x/sync/singleflight"
 )
 
 // System manages the sync between the git repo, the local checkouts
@@ -34,34 +40,276 @@ import (
 type System struct {
 	cfg  *config.Config
 	key  *crypto.Key
-	done bool
-	fns  chan func(*System)
+	keys map[string]*crypto.Key
+	// namedKeys are the keys the profiles can select by name.
+	namedKeys map[string]*crypto.Key
+	// signers are the keys with a selected subkey to sign
+	// with instead of the loaded keys.
+	signers map[*crypto.Key]*crypto.Key
+	// signTime is the fixed creation time of the signatures if not zero.
+	signTime time.Time
+	done     bool
+	fns      chan func(*System)
+	// git runs the git commands with the credentials of the remote.
+	git *gitClient
+	// served is the time each profile was served last.
+	served map[string]time.Time
+	// profiles are the currently served profiles.
+	profiles atomic.Pointer[config.Profiles]
+	// builds tracks the builds for debugging.
+	builds buildState
+	// building coalesces concurrent builds of the same export.
+	building singleflight.Group
+	// pending are the hashes of the exports being built.
+	pending map[string]bool
+	// retired are the hashes of the exports to be removed
+	// with the time their grace period is over.
+	retired map[string]time.Time
+	// stale are the profiles whose branches have changed
+	// but which are not invalidated yet.
+	stale map[string]bool
+	// signSlots limits the concurrent signings if not nil.
+	signSlots chan struct{}
+
+	upstream atomic.Pointer[upstreamStatus]
+	updated  atomic.Pointer[updateStatus]
 }
 
 // NewSystem create a new System.
-func NewSystem(cfg *config.Config) (*System, error) {
+func NewSystem(ctx context.Context, cfg *config.Config) (*System, error) {
 	key, err := prepareKeyRing(cfg.Signing.Key, cfg.Signing.Passphrase)
 	if err != nil {
 		return nil, fmt.Errorf("cannot load signing key: %w", err)
 	}
-	if err := initialCheckout(
+	namedKeys, err := loadNamedKeys(cfg.Signing.Keys)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := loadProfileKeys(cfg.Providers.Profiles, namedKeys)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKeysExpiry(cfg, key, namedKeys, keys); err != nil {
+		return nil, err
+	}
+	signers, err := selectSigners(&cfg.Signing, key, namedKeys)
+	if err != nil {
+		return nil, err
+	}
+	signTime, err := cfg.Signing.SignTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := prepareWebRoot(cfg.Web.Root); err != nil {
+		return nil, err
+	}
+	git := newGitClient(&cfg.Providers)
+	if err := git.initialCheckout(
+		ctx,
 		cfg.Providers.GitURL,
 		cfg.Providers.WorkDir,
 		cfg.Providers.Profiles.AllBranches(),
+		newCheckoutOptions(&cfg.Providers),
 	); err != nil {
 		return nil, fmt.Errorf("initial checkout failed %w", err)
 	}
-	return &System{
-		cfg: cfg,
-		key: key,
-		fns: make(chan func(*System)),
-	}, nil
+	served, err := loadServed(cfg.Web.Root, cfg.Providers.Profiles)
+	if err != nil {
+		slog.Warn("ignoring served profiles", "error", err)
+		served = map[string]time.Time{}
+	}
+	s := &System{
+		cfg:       cfg,
+		key:       key,
+		keys:      keys,
+		namedKeys: namedKeys,
+		signers:   signers,
+		signTime:  signTime,
+		git:       git,
+		fns:       make(chan func(*System)),
+		pending:   map[string]bool{},
+		retired:   map[string]time.Time{},
+		stale:     map[string]bool{},
+		served:    served,
+	}
+	if n := cfg.Signing.MaxConcurrency; n > 0 {
+		s.signSlots = make(chan struct{}, n)
+	}
+	profiles := cfg.Providers.Profiles
+	s.profiles.Store(&profiles)
+	s.updated.Store(&updateStatus{updated: time.Now()})
+	return s, nil
+}
+
+// Profiles returns the currently served profiles.
+// The returned profiles must not be modified.
+func (s *System) Profiles() config.Profiles {
+	return *s.profiles.Load()
+}
+
+// prepareWebRoot creates the web root if it does not exist
+// and checks that it is a writable directory.
+func prepareWebRoot(root string) error {
+	switch info, err := os.Stat(root); {
+	case errors.Is(err, os.ErrNotExist):
+		slog.Info("creating web root", "dir", root)
+		if err := os.MkdirAll(root, 0777); err != nil {
+			return fmt.Errorf("creating web root %q failed: %w", root, err)
+		}
+	case err != nil:
+		return fmt.Errorf("stating web root %q failed: %w", root, err)
+	case !info.IsDir():
+		return fmt.Errorf("web root %q is not a directory", root)
+	}
+	// Profiles are exported into the web root so it has to be writable.
+	probe, err := os.CreateTemp(root, ".probe-")
+	if err != nil {
+		return fmt.Errorf("web root %q is not writable: %w", root, err)
+	}
+	return errors.Join(probe.Close(), os.Remove(probe.Name()))
+}
+
+// loadNamedKeys loads the additional signing keys selected by name.
+func loadNamedKeys(named []config.SigningKey) (map[string]*crypto.Key, error) {
+	keys := make(map[string]*crypto.Key, len(named))
+	for _, nk := range named {
+		key, err := prepareKeyRing(nk.Key, nk.Passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load signing key %q: %w", nk.Name, err)
+		}
+		keys[nk.Name] = key
+	}
+	return keys, nil
+}
+
+// selectSigners selects the configured subkeys of
+// the default and the named keys for signing.
+func selectSigners(
+	cfg *config.Signing,
+	key *crypto.Key,
+	named map[string]*crypto.Key,
+) (map[*crypto.Key]*crypto.Key, error) {
+	signers := map[*crypto.Key]*crypto.Key{}
+	if cfg.Subkey != "" {
+		signer, err := selectSubkey(key, cfg.Subkey)
+		if err != nil {
+			return nil, fmt.Errorf("cannot select subkey: %w", err)
+		}
+		signers[key] = signer
+	}
+	for _, nk := range cfg.Keys {
+		if nk.Subkey == "" {
+			continue
+		}
+		signer, err := selectSubkey(named[nk.Name], nk.Subkey)
+		if err != nil {
+			return nil, fmt.Errorf("cannot select subkey of signing key %q: %w", nk.Name, err)
+		}
+		signers[named[nk.Name]] = signer
+	}
+	return signers, nil
+}
+
+// signer returns the key to sign with instead of the given one.
+// This is the key itself if none of its subkeys is selected.
+func (s *System) signer(key *crypto.Key) *crypto.Key {
+	if signer := s.signers[key]; signer != nil {
+		return signer
+	}
+	return key
+}
+
+// loadProfileKeys loads the signing keys overridden by the profiles.
+// Keys used by several profiles are only loaded once. Profiles
+// selecting a key by name get it from the named keys. The config
+// rejects profiles setting both, the named key would be used.
+func loadProfileKeys(profiles config.Profiles, named map[string]*crypto.Key) (map[string]*crypto.Key, error) {
+	keys := map[string]*crypto.Key{}
+	cache := map[string]*crypto.Key{}
+	for name, profile := range profiles {
+		if profile.SigningKey != "" {
+			key := named[profile.SigningKey]
+			if key == nil {
+				return nil, fmt.Errorf(
+					"signing key %q of profile %q is not configured",
+					profile.SigningKey, name)
+			}
+			keys[name] = key
+			continue
+		}
+		if profile.Key == "" {
+			continue
+		}
+		key := cache[profile.Key]
+		if key == nil {
+			var err error
+			if key, err = prepareKeyRing(profile.Key, profile.Passphrase); err != nil {
+				return nil, fmt.Errorf(
+					"cannot load signing key of profile %q: %w", name, err)
+			}
+			cache[profile.Key] = key
+		}
+		keys[name] = key
+	}
+	return keys, nil
+}
+
+// hashes returns the hash algorithms of the hash files of the given profile.
+func (s *System) hashes(profile string) []string {
+	if p := s.Profiles()[profile]; p != nil && p.Hashes != nil {
+		return p.Hashes
+	}
+	return s.cfg.Signing.Hashes
+}
+
+// signingKey returns the key to sign the given profile with.
+func (s *System) signingKey(profile string) *crypto.Key {
+	if key := s.keys[profile]; key != nil {
+		return key
+	}
+	return s.key
+}
+
+// mergeOptions returns the options for merging the branches.
+func (s *System) mergeOptions() mergeOptions {
+	return mergeOptions{
+		name:  s.cfg.Providers.GitAuthor,
+		email: s.cfg.Providers.GitEmail,
+		noFF:  s.cfg.Providers.MergeNoFF,
+	}
+}
+
+// newCheckoutOptions returns the options how to fetch the git repository.
+func newCheckoutOptions(cfg *config.Providers) checkoutOptions {
+	return checkoutOptions{
+		depth:        cfg.ShallowDepth,
+		singleBranch: cfg.SingleBranch,
+	}
 }
 
 // Run drives the system. Meant to be run in a Go routine.
 func (s *System) Run(ctx context.Context) {
 	ticker := time.NewTicker(s.cfg.Providers.Update)
 	defer ticker.Stop()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go s.watchUpstream(ctx)
+	if verify, prewarm := s.cfg.Providers.VerifyOnStart, s.cfg.Providers.Prewarm; verify || prewarm {
+		served := maps.Clone(s.served)
+		go func() {
+			// Verify first so that prewarming does not keep a broken export.
+			if verify {
+				s.verifyExports(ctx)
+			}
+			if prewarm {
+				s.prewarm(ctx, served)
+			}
+		}()
+	}
+	// Store the last served times for the next start.
+	defer s.saveServed()
+	// Nobody downloads the retired exports any more.
+	defer s.removeRetired(true)
 	for !s.done {
 		select {
 		case <-ctx.Done():
@@ -69,7 +317,9 @@ func (s *System) Run(ctx context.Context) {
 		case fn := <-s.fns:
 			fn(s)
 		case <-ticker.C:
-			s.update()
+			s.update(ctx)
+			s.removeRetired(false)
+			s.gc()
 		}
 	}
 }
@@ -83,181 +333,471 @@ func (s *System) Kill() {
 var ErrProfileNotFound = errors.New("profile not found")
 
 // Serve prepares the serving of a given profile.
-func (s *System) Serve(profile string) error {
-	branches := s.cfg.Providers.Profiles[profile]
+// Different profiles are built concurrently. Concurrent requests
+// of the same profile wait for the same build. The git commands
+// are killed if the context is done.
+func (s *System) Serve(ctx context.Context, profile string) (err error) {
+	profiles := s.Profiles()
+	if _, ok := profiles[profile]; !ok {
+		return ErrProfileNotFound
+	}
+	branches := profiles.Branches(profile)
 	if len(branches) == 0 {
 		return ErrProfileNotFound
 	}
-	result := make(chan error)
-	s.fns <- func(s *System) {
-		profileDir := path.Join(s.cfg.Web.Root, profile)
-		profileDir, err := filepath.Abs(profileDir)
-		if err != nil {
-			result <- fmt.Errorf("unable to get abs path for %q: %w", profile, err)
-			return
+	var revisions []string
+	switch err := s.do(func(s *System) (err error) {
+		revisions, err = s.prepareServe(ctx, profile, branches)
+		return err
+	}); {
+	case errors.Is(err, errServed):
+		metrics.ProfileServed(profile)
+		return nil
+	case err != nil:
+		return err
+	}
+	defer func() {
+		if err == nil {
+			metrics.ProfileServed(profile)
 		}
-
-		slog.Debug("profile dir", "dir", profileDir)
-
-		// Check if we already have instantiated this profile.
-		switch _, err := os.Stat(profileDir); {
-		case errors.Is(err, os.ErrNotExist):
-			slog.Debug("profile does not exists", "profile", profile)
-		case err != nil:
-			result <- fmt.Errorf(
-				"stating profile %q failed: %w", profile, err)
-			return
-		default:
-			// We already have it.
-			result <- nil
-			return
+	}()
+	// The hash covers the revisions so all callers waiting
+	// for the same build need the same revisions.
+	hash := exportHash(profile, revisions)
+	for {
+		_, err, _ := s.building.Do(hash, func() (any, error) {
+			return nil, s.buildServe(ctx, profile, branches, revisions, hash)
+		})
+		// The build may have been aborted for another caller
+		// which went away. Then try it again.
+		if errors.Is(err, errAborted) && ctx.Err() == nil {
+			continue
 		}
+		return err
+	}
+}
 
-		// The hash over all branch revisions will be the destination folder.
-		h, err := allRevisionsHash(s.cfg.Providers.WorkDir, branches)
-		if err != nil {
-			result <- fmt.Errorf(
-				"calculating hash of the branches of %q failed: %w",
-				profile, err)
-			return
-		}
-		hash := hex.EncodeToString(h)
-		slog.Debug("current hash", "profile", profile, "hash", hash)
+// errAborted is returned if a build is aborted because its context is done.
+var errAborted = errors.New("build aborted")
+
+// errServed signals that a profile is already instantiated.
+var errServed = errors.New("profile already served")
+
+// do runs a function in the control goroutine and waits for its result.
+func (s *System) do(fn func(*System) error) error {
+	result := make(chan error)
+	s.fns <- func(s *System) { result <- fn(s) }
+	return <-result
+}
+
+// webPath returns the absolute path of an entry in the web root.
+func (s *System) webPath(name string) (string, error) {
+	dir, err := filepath.Abs(path.Join(s.cfg.Web.Root, name))
+	if err != nil {
+		return "", fmt.Errorf("unable to get abs path for %q: %w", name, err)
+	}
+	return dir, nil
+}
+
+// prepareServe runs in the control goroutine. It returns errServed
+// if the profile is already instantiated or a kept export could be
+// reused. Otherwise it returns the revisions of the branches to build.
+func (s *System) prepareServe(ctx context.Context, profile string, branches []string) ([]string, error) {
+	profileDir, err := s.webPath(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Debug("profile dir", "dir", profileDir)
+
+	// Check if we already have instantiated this profile.
+	switch _, err := os.Stat(profileDir); {
+	case errors.Is(err, os.ErrNotExist):
+		slog.Debug("profile does not exists", "profile", profile)
+	case err != nil:
+		return nil, fmt.Errorf("stating profile %q failed: %w", profile, err)
+	default:
+		// We already have it.
+		s.markServed(profile)
+		return nil, errServed
+	}
+
+	if err := s.checkFreeSpace(); err != nil {
+		return nil, fmt.Errorf("building profile %q failed: %w", profile, err)
+	}
+
+	revisions, err := s.git.branchRevisions(ctx, s.cfg.Providers.WorkDir, branches)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"calculating hash of the branches of %q failed: %w",
+			profile, err)
+	}
+	// The hash over the profile and all branch revisions will be the destination folder.
+	hash := exportHash(profile, revisions)
+	slog.Debug("current hash", "profile", profile, "hash", hash)
 
-		targetDir := path.Join(s.cfg.Web.Root, hash)
-		if targetDir, err = filepath.Abs(targetDir); err != nil {
-			result <- fmt.Errorf("unable to get abs path for %q: %w", profile, err)
-			return
+	targetDir, err := s.webPath(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reuse a kept export of this profile with the same revisions.
+	if bi, err := loadBuildInfo(path.Join(targetDir, buildInfoFile)); err == nil &&
+		bi.Profile == profile {
+		slog.Debug("reusing kept export", "profile", profile, "hash", hash)
+		// It may have been scheduled for removal.
+		delete(s.retired, hash)
+		if err := os.Symlink(targetDir, profileDir); err != nil {
+			return nil, fmt.Errorf("symlinking profile %q failed: %w", profile, err)
 		}
+		s.markServed(profile)
+		s.evictProfiles(profile)
+		return nil, errServed
+	}
+	return revisions, nil
+}
+
+// buildServe builds the export of a profile from the given revisions.
+// Only the bookkeeping and the linking of the export are done in the
+// control goroutine. The merging in a temporary worktree, the signing
+// and the hashing run concurrently to other builds.
+func (s *System) buildServe(
+	ctx context.Context,
+	profile string, branches, revisions []string, hash string,
+) (err error) {
+	s.builds.start(profile)
+	defer func() { s.builds.done(profile, err) }()
 
+	profileDir, err := s.webPath(profile)
+	if err != nil {
+		return err
+	}
+	targetDir, err := s.webPath(hash)
+	if err != nil {
+		return err
+	}
+
+	var key *crypto.Key
+	switch err := s.do(func(s *System) error {
+		// A build of the same profile may have finished meanwhile.
+		if s.instantiated(profile) {
+			s.markServed(profile)
+			return errServed
+		}
+		// Don't build into a broken export scheduled for removal.
+		if _, ok := s.retired[hash]; ok {
+			delete(s.retired, hash)
+			if err := os.RemoveAll(targetDir); err != nil {
+				return fmt.Errorf("removing retired export failed: %w", err)
+			}
+		}
 		// Create target directory to write the export into.
 		if err := os.MkdirAll(targetDir, 0777); err != nil {
-			result <- fmt.Errorf("creating profile directory failed: %w", err)
-			return
+			return fmt.Errorf("creating profile directory failed: %w", err)
+		}
+		s.pending[hash] = true
+		key = s.signingKey(profile)
+		return nil
+	}); {
+	case errors.Is(err, errServed):
+		return nil
+	case err != nil:
+		return err
+	}
+	start := time.Now()
+	defer func() { metrics.ObserveBuild(profile, time.Since(start), err) }()
+
+	merge := func(untar func(io.Reader, int) error) error {
+		// Profiles of a single branch need no merge.
+		if len(revisions) == 1 {
+			return s.git.archiveRevision(ctx, s.cfg.Providers.WorkDir, revisions[0], untar)
 		}
+		return s.git.mergeRevisions(ctx, s.cfg.Providers.WorkDir, branches, revisions, s.mergeOptions(), untar)
+	}
+	cache, err := s.newContentCache(profile, key)
+	var directories *Directory
+	if err == nil {
+		directories, err = s.extract(targetDir, profile, profile, key, merge, cache)
+	}
+	if err == nil {
+		// Don't sign for a caller which went away.
+		err = ctx.Err()
+	}
+	if err == nil {
+		err = s.finish(targetDir, profile, key, directories, cache)
+	}
+	if err == nil {
+		err = writeBuildInfo(path.Join(targetDir, buildInfoFile), &BuildInfo{
+			Profile: profile,
+			Hash:    hash,
+			Created: time.Now(),
+		})
+	}
+	if err == nil && s.cfg.Providers.Dedup {
+		err = s.dedup(targetDir)
+	}
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("%w: %w", errAborted, context.Cause(ctx))
+	}
 
-		errExit := func(err error) {
+	return s.do(func(s *System) error {
+		delete(s.pending, hash)
+		if err != nil {
 			// Ensure that the debris is always removed.
 			os.RemoveAll(targetDir)
-			result <- err
+			return err
+		}
+		// Create a symlink for the profile.
+		if err := os.Symlink(targetDir, profileDir); err != nil {
+			os.RemoveAll(targetDir)
+			return fmt.Errorf("symlinking profile %q failed: %w", profile, err)
 		}
+		s.markServed(profile)
+		s.evictProfiles(profile)
+		return nil
+	})
+}
 
-		directivesBuilder := &DirectoryBuilder{}
+// export builds the export of a profile in the target directory.
+// The merge function has to feed the merged branches as tar stream
+// into the given untar function. The profile path is interpolated
+// into the base URL of the export. The files are signed with key.
+func (s *System) export(
+	targetDir, profile, profilePath string,
+	key *crypto.Key,
+	merge func(untar func(io.Reader, int) error) error,
+) (err error) {
+	s.builds.start(profilePath)
+	defer func() { s.builds.done(profilePath, err) }()
 
-		untar := templateFromTar(
-			targetDir,
-			s.fillTemplateData(profile),
-			directivesBuilder.addDirectives)
+	cache, err := s.newContentCache(profile, key)
+	if err != nil {
+		return err
+	}
+	directories, err := s.extract(targetDir, profile, profilePath, key, merge, cache)
+	if err != nil {
+		return err
+	}
+	return s.finish(targetDir, profile, key, directories, cache)
+}
 
-		if err := mergeBranches(s.cfg.Providers.WorkDir, branches, untar); err != nil {
-			errExit(fmt.Errorf("merging profile %q failed: %w", profile, err))
-			return
-		}
+// newContentCache returns the cache of the file contents to sign
+// and hash of a build of a profile. It returns nil if no contents
+// are kept in memory.
+func (s *System) newContentCache(profile string, key *crypto.Key) (*contentCache, error) {
+	limit := s.cfg.Signing.InMemoryMB << 20
+	// Profiles in passthrough mode are not signed and hashed.
+	if p := s.Profiles()[profile]; limit <= 0 || p != nil && p.Passthrough {
+		return nil, nil
+	}
+	patterns, err := s.buildPatternActions(profile, key)
+	if err != nil {
+		return nil, fmt.Errorf("building patterns failed: %w", err)
+	}
+	return newContentCache(limit, patterns), nil
+}
 
-		// If we have directives store them in the root folder of the export.
-		if directories := directivesBuilder.Directories(); directories != nil {
-			directoriesFile := path.Join(targetDir, ".directories.json")
-			slog.Debug("writing directories file", "file", directoriesFile)
-			if err := directories.WriteToFile(directoriesFile); err != nil {
-				errExit(fmt.Errorf(
-					"storing directories file for profile %q failed: %w",
-					profile, err))
-				return
-			}
-		}
+// extract extracts the merged branches of a profile into the
+// target directory and interpolates the templates. It returns
+// the collected directives.
+func (s *System) extract(
+	targetDir, profile, profilePath string,
+	key *crypto.Key,
+	merge func(untar func(io.Reader, int) error) error,
+	cache *contentCache,
+) (*Directory, error) {
+	data := s.fillTemplateData(profile, profilePath, key)
+	if err := data.validate(); err != nil {
+		return nil, fmt.Errorf("building profile %q failed: %w", profile, err)
+	}
+	directivesBuilder := &DirectoryBuilder{}
 
-		// Store the public key in the exported directory.
-		if err := writePublicKey(s.key, targetDir); err != nil {
-			errExit(fmt.Errorf("signing failed: %w", err))
-			return
-		}
+	untar := templateFromTar(
+		targetDir,
+		int64(s.cfg.Providers.MaxTemplateMB)<<20,
+		s.cfg.Providers.CanonicalizeJSON,
+		data,
+		directivesBuilder.addDirectives,
+		func(parts []string) bool {
+			return slices.Contains(s.cfg.Providers.VerbatimExtensions, path.Ext(parts[len(parts)-1])) ||
+				directivesBuilder.verbatim(parts)
+		},
+		func(done, total int) { s.builds.progress(profilePath, done, total) },
+		cache)
 
-		// Sign and hash the relevant files.
-		patterns, err := s.buildPatternActions()
-		if err != nil {
-			errExit(fmt.Errorf("building patterns failed: %w", err))
-			return
+	if err := merge(untar); err != nil {
+		return nil, fmt.Errorf("merging profile %q failed: %w", profile, err)
+	}
+	return directivesBuilder.Directories(), nil
+}
+
+// finish stores the directives and the public key in an extracted
+// export and signs and hashes its files. It does not touch the
+// git worktrees so it may run outside the control goroutine.
+func (s *System) finish(
+	targetDir, profile string,
+	key *crypto.Key,
+	directories *Directory,
+	cache *contentCache,
+) error {
+	// If we have directives store them in the root folder of the export.
+	if directories != nil {
+		directoriesFile := path.Join(targetDir, ".directories.json")
+		slog.Debug("writing directories file", "file", directoriesFile)
+		if err := directories.WriteToFile(directoriesFile); err != nil {
+			return fmt.Errorf(
+				"storing directories file for profile %q failed: %w",
+				profile, err)
 		}
-		if err := patterns.Apply(targetDir); err != nil {
-			errExit(fmt.Errorf("applying actions failed: %w", err))
-			return
+	}
+
+	// Store the public key in the exported directory.
+	if err := writePublicKey(key, targetDir); err != nil {
+		return fmt.Errorf("signing failed: %w", err)
+	}
+
+	// File the advisories of directory distributions.
+	if p := s.Profiles()[profile]; p != nil && p.DirectoryLayout != "" {
+		if err := fileAdvisories(targetDir, p.DirectoryLayout, p.ShuffleSeed, cache); err != nil {
+			return fmt.Errorf("filing advisories failed: %w", err)
 		}
+	}
 
-		// Create a symlink for the profile.
-		if err := os.Symlink(targetDir, profileDir); err != nil {
-			errExit(fmt.Errorf("symlinking profile %q failed: %w", profile, err))
-			return
+	// Profiles in passthrough mode keep the signatures and hashes
+	// of the branches.
+	if p := s.Profiles()[profile]; p != nil && p.Passthrough {
+		slog.Debug("passthrough: not signing and hashing", "profile", profile)
+		return nil
+	}
+
+	// Sign and hash the relevant files.
+	patterns, err := s.buildPatternActions(profile, key)
+	if err != nil {
+		return fmt.Errorf("building patterns failed: %w", err)
+	}
+	if err := patterns.Apply(targetDir, int64(s.cfg.Providers.MaxTemplateMB)<<20, cache); err != nil {
+		return fmt.Errorf("applying actions failed: %w", err)
+	}
+
+	// Write a signed manifest over all files if requested.
+	if s.cfg.Signing.Manifest {
+		if err := writeManifest(targetDir, s.signer(key), s.signTime, s.armorHeaders()); err != nil {
+			return fmt.Errorf("writing manifest failed: %w", err)
 		}
+	}
+	return nil
+}
 
-		result <- nil
+// armorHeaders returns the configured armor headers of the signatures.
+func (s *System) armorHeaders() armorHeaders {
+	return armorHeaders{
+		version: s.cfg.Signing.ArmorVersion,
+		comment: s.cfg.Signing.ArmorComment,
 	}
-	return <-result
 }
 
 // buildPatternActions builds a PatternActions slice allowing to
 // insert additional info if necessary.
-func (s *System) buildPatternActions() (PatternActions, error) {
-	signing, err := encloseSignFile(s.key)
+func (s *System) buildPatternActions(profile string, key *crypto.Key) (PatternActions, error) {
+	signing, err := encloseSignFile(
+		s.signer(key),
+		s.cfg.Signing.Mode,
+		s.cfg.Signing.VerifyAfterSign,
+		s.signTime,
+		s.armorHeaders(),
+		s.signSlots)
 	if err != nil {
 		return nil, fmt.Errorf("creating signing failed: %w", err)
 	}
+	hashing := encloseHashFile(s.cfg.Signing.HashFormat, s.hashes(profile))
 	return PatternActions{
 		{regexp.MustCompile(`csaf-feed-tlp-[^\.]*\.json$`), nil},
 		{regexp.MustCompile(`(\.directories|provider-metadata|service|category)[^\.]*\.json$`), nil},
-		{regexp.MustCompile(`\.json$`), []Action{hashFile, signing}},
+		{regexp.MustCompile(`\.json$`), []Action{hashing, signing}},
 	}, nil
 }
 
 // update checks the git repo for update and invalidates providers
 // which need regeneration.
-func (s *System) update() {
-	refreshed, err := updateBranches(
+func (s *System) update(ctx context.Context) {
+	start := time.Now()
+	refreshed, err := s.git.updateBranches(
+		ctx,
 		s.cfg.Providers.WorkDir,
-		s.cfg.Providers.Profiles.AllBranches())
+		s.Profiles().AllBranches(),
+		newCheckoutOptions(&s.cfg.Providers))
+	metrics.ObserveUpdate(time.Since(start))
 	if err != nil {
 		slog.Error("updating branches failed", "error", err)
 	}
+	s.updated.Store(&updateStatus{updated: time.Now(), err: err})
+	s.cleanupPreviews(time.Now())
 	// Even if there where errors there might be some links to delete.
-	profiles := s.cfg.Providers.Profiles.DependingProfiles(refreshed)
-	for _, profile := range profiles {
-		link := path.Join(s.cfg.Web.Root, profile)
-		info, err := os.Lstat(link)
-		// Delete only the exported profile with symlinks to them.
-		if err != nil || info.Mode()&os.ModeSymlink != os.ModeSymlink {
-			continue
-		}
-		exported, err := filepath.EvalSymlinks(link)
-		if err != nil {
-			slog.Error("evaluating symlink failed", "error", err)
-			continue
-		}
-		// Remove the linked profile export.
-		if err := os.RemoveAll(exported); err != nil {
-			slog.Error("removing symlinked dir failed", "error", err, "branch", profile)
+	for _, profile := range s.Profiles().DependingProfiles(refreshed) {
+		s.stale[profile] = true
+	}
+	s.invalidateStale(time.Now())
+}
+
+// invalidateStale invalidates the exports of the profiles whose
+// branches have changed. Profiles built less than the minimal
+// rebuild interval ago keep their exports until it is over.
+func (s *System) invalidateStale(now time.Time) {
+	for profile := range s.stale {
+		if interval := s.cfg.Providers.MinRebuildInterval; interval > 0 {
+			bi, err := loadBuildInfo(path.Join(s.cfg.Web.Root, profile, buildInfoFile))
+			if err == nil && now.Sub(bi.Created) < interval {
+				slog.Debug("deferring rebuild", "profile", profile,
+					"until", bi.Created.Add(interval))
+				continue
+			}
 		}
-		// Remove the link itself.
-		if err := os.Remove(link); err != nil {
-			slog.Error("removing link to profile failed", "error", err, "branch", profile)
+		delete(s.stale, profile)
+		if s.cfg.Providers.KeepExports > 0 {
+			s.shelveProfile(profile)
+		} else {
+			s.removeProfile(profile, s.cfg.Providers.DeleteGrace)
 		}
 	}
 }
 
 // fillTemplateData fills in the data needed to be interpolated into the templates.
-func (s *System) fillTemplateData(profile string) *templateData {
+// The base URL of the profile is preferred over the one of the providers section.
+func (s *System) fillTemplateData(profile, profilePath string, key *crypto.Key) *templateData {
+	tmpl := s.cfg.Providers.BaseURL
+	vars := maps.Clone(s.cfg.Providers.TemplateVars)
+	if p := s.Profiles()[profile]; p != nil {
+		if p.BaseURL != "" {
+			tmpl = p.BaseURL
+		}
+		if vars == nil && len(p.TemplateVars) > 0 {
+			vars = make(map[string]string, len(p.TemplateVars))
+		}
+		maps.Copy(vars, p.TemplateVars)
+	}
 	var (
 		r = strings.NewReplacer(
 			"{protocol}", s.cfg.Web.Protocol,
 			"{host}", s.cfg.Web.Host,
 			"{port}", strconv.Itoa(s.cfg.Web.Port),
-			"{profile}", profile,
+			"{profile}", profilePath,
 		)
-		baseURL     = r.Replace(s.cfg.Providers.BaseURL)
-		fingerprint = s.key.GetFingerprint()
-		keyURL      = baseURL + "/" + s.key.GetHexKeyID() + ".asc"
+		baseURL     = r.Replace(tmpl)
+		fingerprint = key.GetFingerprint()
+		keyURL      = baseURL + "/" + key.GetHexKeyID() + ".asc"
+		distURL     = baseURL + "/.well-known/csaf"
+		namespace   = baseURL
 	)
+	if u, err := url.Parse(baseURL); err == nil && u.Scheme != "" && u.Host != "" {
+		namespace = u.Scheme + "://" + u.Host
+	}
 	return &templateData{
 		BaseURL:                     baseURL,
 		PublicOpenPGPKeyFingerprint: fingerprint,
 		PublicOpenPGPKeyURL:         keyURL,
+		CanonicalURL:                distURL + "/provider-metadata.json",
+		DistributionURL:             distURL,
+		PublisherNamespaceURL:       namespace,
+		Vars:                        vars,
 	}
 }
@@ -0,0 +1,754 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+// testGit runs git in dir and returns its trimmed output.
+func testGit(t testing.TB, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{
+		"-c", "user.name=test",
+		"-c", "user.email=test@example.com",
+		"-c", "init.defaultBranch=main",
+	}, args...)...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// writeFiles writes files given by their slash separated paths into dir.
+func writeFiles(t testing.TB, dir string, files map[string]string) {
+	t.Helper()
+	for file, content := range files {
+		fname := filepath.Join(dir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(fname), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fname, []byte(content), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// testOrigin creates a git repository with the given branches holding
+// files by their slash separated paths below the data folder. The
+// other branches are forked from "main".
+func testOrigin(t testing.TB, branches map[string]map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	origin := t.TempDir()
+	testGit(t, origin, "init", "-q")
+	commit := func(files map[string]string) {
+		t.Helper()
+		writeFiles(t, filepath.Join(origin, "data"), files)
+		testGit(t, origin, "add", "-A")
+		testGit(t, origin, "commit", "-q", "--allow-empty", "-m", "update")
+	}
+	commit(branches["main"])
+	for _, branch := range slices.Sorted(maps.Keys(branches)) {
+		if branch == "main" {
+			continue
+		}
+		testGit(t, origin, "checkout", "-q", "-b", branch, "main")
+		commit(branches[branch])
+	}
+	testGit(t, origin, "checkout", "-q", "main")
+	return origin
+}
+
+// writeKey writes the armored key into a file in dir.
+func writeKey(t testing.TB, dir string, key *crypto.Key) string {
+	t.Helper()
+	armored, err := key.Armor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, key.GetHexKeyID()+".key")
+	if err := os.WriteFile(file, []byte(armored), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// testConfig returns the default config of a system building the
+// given profiles from origin. It signs with key.
+func testConfig(
+	t testing.TB,
+	origin string,
+	key *crypto.Key,
+	profiles config.Profiles,
+) *config.Config {
+	t.Helper()
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	cfg.Providers.GitURL = "file://" + origin
+	cfg.Providers.WorkDir = filepath.Join(dir, "work")
+	cfg.Providers.Profiles = profiles
+	cfg.Providers.Update = time.Hour
+	cfg.Web.Root = filepath.Join(dir, "web")
+	cfg.Signing.Key = writeKey(t, dir, key)
+	return cfg
+}
+
+// startSystem creates a system and runs it until the end of the test.
+func startSystem(t testing.TB, cfg *config.Config) *System {
+	t.Helper()
+	s, err := NewSystem(t.Context(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The context of the test is already done in the cleanup.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return s
+}
+
+// testKey generates a signing key.
+func testKey(t testing.TB) *crypto.Key {
+	t.Helper()
+	key, err := crypto.PGP().KeyGeneration().
+		AddUserId("contravider", "test@example.com").
+		New().
+		GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// serve builds a profile and returns the directory of its export.
+func serve(t testing.TB, s *System, profile string) string {
+	t.Helper()
+	if err := s.Serve(t.Context(), profile); err != nil {
+		t.Fatal(err)
+	}
+	hash, ok := s.CurrentExport(profile)
+	if !ok {
+		t.Fatalf("profile %q is not exported", profile)
+	}
+	return filepath.Join(s.cfg.Web.Root, hash)
+}
+
+// verifies checks if the detached signature of a file verifies with key.
+func verifies(t testing.TB, file string, key *crypto.Key) bool {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := os.ReadFile(file + ".asc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := crypto.PGP().Verify().VerificationKey(key).New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := verifier.VerifyDetached(data, signature, crypto.Armor)
+	return err == nil && result.SignatureError() == nil
+}
+
+func TestProfileKeys(t *testing.T) {
+	origin := testOrigin(t, map[string]map[string]string{
+		"main": {
+			"white/advisory.json": `{"document":{}}`,
+			"white/key.json": `{"fingerprint":"$((.PublicOpenPGPKeyFingerprint))$",` +
+				`"url":"$((.PublicOpenPGPKeyURL))$"}`,
+		},
+	})
+	defaultKey, keyA, keyB, named := testKey(t), testKey(t), testKey(t), testKey(t)
+	cfg := testConfig(t, origin, defaultKey, nil)
+	keysDir := t.TempDir()
+	cfg.Signing.Keys = []config.SigningKey{{Name: "mismatch", Key: writeKey(t, keysDir, named)}}
+	cfg.Providers.Profiles = config.Profiles{
+		"a":       {Branches: []string{"main"}, Key: writeKey(t, keysDir, keyA)},
+		"b":       {Branches: []string{"main"}, Key: writeKey(t, keysDir, keyB)},
+		"named":   {Branches: []string{"main"}, SigningKey: "mismatch"},
+		"default": {Branches: []string{"main"}},
+	}
+	s := startSystem(t, cfg)
+
+	keys := map[string]*crypto.Key{"a": keyA, "b": keyB, "named": named, "default": defaultKey}
+	for _, profile := range slices.Sorted(maps.Keys(keys)) {
+		export := serve(t, s, profile)
+		file := filepath.Join(export, "white", "advisory.json")
+		for name, key := range keys {
+			if got, want := verifies(t, file, key), name == profile; got != want {
+				t.Errorf("profile %s: signature verifies with key of %s: %t", profile, name, got)
+			}
+		}
+		// The public key of the profile is exported with it.
+		key := keys[profile]
+		if _, err := os.Stat(filepath.Join(export, key.GetHexKeyID()+".asc")); err != nil {
+			t.Errorf("profile %s: public key not exported: %v", profile, err)
+		}
+		// The templates advertise the key signing the profile.
+		data, err := os.ReadFile(filepath.Join(export, "white", "key.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var advertised struct {
+			Fingerprint string `json:"fingerprint"`
+			URL         string `json:"url"`
+		}
+		if err := json.Unmarshal(data, &advertised); err != nil {
+			t.Fatalf("profile %s: %v", profile, err)
+		}
+		if advertised.Fingerprint != key.GetFingerprint() {
+			t.Errorf("profile %s: got fingerprint %q, want %q",
+				profile, advertised.Fingerprint, key.GetFingerprint())
+		}
+		if !strings.HasSuffix(advertised.URL, "/"+key.GetHexKeyID()+".asc") {
+			t.Errorf("profile %s: got key URL %q", profile, advertised.URL)
+		}
+	}
+}
+
+func TestProfileHashes(t *testing.T) {
+	origin := testOrigin(t, map[string]map[string]string{
+		"main": {"white/advisory.json": `{"document":{}}`},
+	})
+	main := []string{"main"}
+	cfg := testConfig(t, origin, testKey(t), config.Profiles{
+		"default": {Branches: main},
+		"sha512":  {Branches: main, Hashes: []string{config.HashSHA512}},
+		"both":    {Branches: main, Hashes: []string{config.HashSHA256, config.HashSHA512}},
+	})
+	cfg.Signing.Hashes = []string{config.HashSHA256}
+	s := startSystem(t, cfg)
+	for profile, want := range map[string][]string{
+		"default": {"advisory.json.sha256"},
+		"sha512":  {"advisory.json.sha512"},
+		"both":    {"advisory.json.sha256", "advisory.json.sha512"},
+	} {
+		entries, err := os.ReadDir(filepath.Join(serve(t, s, profile), "white"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, entry := range entries {
+			if strings.Contains(entry.Name(), ".sha") {
+				got = append(got, entry.Name())
+			}
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("profile %s: got hash files %q, want %q", profile, got, want)
+		}
+	}
+}
+
+func TestBinaryFilesVerbatim(t *testing.T) {
+	// Binary content looking like a template and a text
+	// file which does not parse as one.
+	var (
+		binary = "\x89PNG\r\n\x1a\n\x00$((.BaseURL))$\x00$((broken\xff"
+		pdf    = "%PDF-1.7 $((broken"
+	)
+	origin := testOrigin(t, map[string]map[string]string{
+		"main": {
+			"white/logo.png":      binary,
+			"white/sample.pdf":    pdf,
+			"white/advisory.json": `{"url":"$((.BaseURL))$"}`,
+		},
+	})
+	cfg := testConfig(t, origin, testKey(t), config.Profiles{
+		"main": {Branches: []string{"main"}},
+	})
+	cfg.Providers.VerbatimExtensions = []string{".pdf"}
+	s := startSystem(t, cfg)
+	white := filepath.Join(serve(t, s, "main"), "white")
+	for file, want := range map[string]string{
+		"logo.png":   binary,
+		"sample.pdf": pdf,
+	} {
+		got, err := os.ReadFile(filepath.Join(white, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: got %q, want %q", file, got, want)
+		}
+	}
+	got, err := os.ReadFile(filepath.Join(white, "advisory.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(got), "$((") {
+		t.Errorf("JSON file is not templated: %s", got)
+	}
+}
+
+func TestClearsignMode(t *testing.T) {
+	const document = `{"document":{}}`
+	origin := testOrigin(t, map[string]map[string]string{
+		"main": {"white/advisory.json": document},
+	})
+	key := testKey(t)
+	cfg := testConfig(t, origin, key, config.Profiles{
+		"main": {Branches: []string{"main"}},
+	})
+	cfg.Signing.Mode = config.SigningModeClearsign
+	s := startSystem(t, cfg)
+	file := filepath.Join(serve(t, s, "main"), "white", "advisory.json")
+	// The document itself is served unchanged next to its signed message.
+	got, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != document {
+		t.Errorf("got document %q, want %q", got, document)
+	}
+	signed, err := os.ReadFile(file + ".asc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(signed, []byte("-----BEGIN PGP SIGNED MESSAGE-----")) {
+		t.Fatalf("no clearsigned message:\n%s", signed)
+	}
+	verifier, err := crypto.PGP().Verify().VerificationKey(key).New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := verifier.VerifyCleartext(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := result.SignatureError(); err != nil {
+		t.Errorf("clearsigned message does not verify: %v", err)
+	}
+	if text := string(result.Cleartext()); text != document {
+		t.Errorf("got signed text %q, want %q", text, document)
+	}
+	// The hashes are taken over the document, not the signed message.
+	hash, err := os.ReadFile(file + ".sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(document))
+	if want := hex.EncodeToString(sum[:]) + "  advisory.json\n"; string(hash) != want {
+		t.Errorf("got hash %q, want %q", hash, want)
+	}
+}
+
+func TestMinRebuildInterval(t *testing.T) {
+	origin := testOrigin(t, map[string]map[string]string{
+		"main": {"white/advisory.json": `{"document":{"version":0}}`},
+	})
+	cfg := testConfig(t, origin, testKey(t), config.Profiles{
+		"main": {Branches: []string{"main"}},
+	})
+	cfg.Providers.MinRebuildInterval = time.Hour
+	s := startSystem(t, cfg)
+	first := serve(t, s, "main")
+	push := func(version int) {
+		t.Helper()
+		writeFiles(t, filepath.Join(origin, "data"), map[string]string{
+			"white/advisory.json": fmt.Sprintf(`{"document":{"version":%d}}`, version),
+		})
+		testGit(t, origin, "commit", "-q", "-am", "update")
+	}
+	update := func() {
+		s.do(func(s *System) error {
+			s.update(t.Context())
+			return nil
+		})
+	}
+
+	// Rapid changes within the interval keep the current export.
+	for version := 1; version <= 3; version++ {
+		push(version)
+		update()
+		if hash, ok := s.CurrentExport("main"); !ok || filepath.Join(cfg.Web.Root, hash) != first {
+			t.Fatalf("version %d: export %q replaced within the interval", version, hash)
+		}
+	}
+	// After the interval the changes are coalesced into one rebuild.
+	s.do(func(s *System) error {
+		s.invalidateStale(time.Now().Add(2 * time.Hour))
+		return nil
+	})
+	if _, ok := s.CurrentExport("main"); ok {
+		t.Fatal("stale profile not invalidated after the interval")
+	}
+	second := serve(t, s, "main")
+	data, err := os.ReadFile(filepath.Join(second, "white", "advisory.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"document":{"version":3}}`; string(data) != want {
+		t.Errorf("got advisory %s, want %s", data, want)
+	}
+	s.do(func(s *System) error {
+		if len(s.stale) != 0 {
+			t.Errorf("profiles still stale: %v", s.stale)
+		}
+		return nil
+	})
+}
+
+func TestProfileBaseURL(t *testing.T) {
+	origin := testOrigin(t, map[string]map[string]string{
+		"main": {"white/urls.json": `{"base":"$((.BaseURL))$"}`},
+	})
+	main := []string{"main"}
+	cfg := testConfig(t, origin, testKey(t), config.Profiles{
+		"default": {Branches: main},
+		"foreign": {Branches: main, BaseURL: "https://foreign.example/{profile}"},
+	})
+	cfg.Web.Protocol, cfg.Web.Host, cfg.Web.Port = "https", "example.com", 8443
+	s := startSystem(t, cfg)
+	check := func(profile, want string) {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(serve(t, s, profile), "white", "urls.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var urls struct {
+			Base string `json:"base"`
+		}
+		if err := json.Unmarshal(data, &urls); err != nil {
+			t.Fatal(err)
+		}
+		if urls.Base != want {
+			t.Errorf("%s: got base URL %q, want %q", profile, urls.Base, want)
+		}
+	}
+	check("default", "https://example.com:8443/default")
+	check("foreign", "https://foreign.example/foreign")
+
+	// A changed override builds the profile again.
+	if err := s.UpdateProfiles(t.Context(), config.Profiles{
+		"default": {Branches: main},
+		"foreign": {Branches: main, BaseURL: "http://other.example:{port}"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	check("foreign", "http://other.example:8443")
+}
+
+func TestCSAFTemplateURLs(t *testing.T) {
+	origin := testOrigin(t, map[string]map[string]string{
+		"main": {"white/urls.json": `{"canonical":"$((.CanonicalURL))$",` +
+			`"distribution":"$((.DistributionURL))$","namespace":"$((.PublisherNamespaceURL))$"}`},
+	})
+	cfg := testConfig(t, origin, testKey(t), config.Profiles{
+		"main": {Branches: []string{"main"}, BaseURL: "https://csaf.example:8443/providers/{profile}"},
+	})
+	s := startSystem(t, cfg)
+	data, err := os.ReadFile(filepath.Join(serve(t, s, "main"), "white", "urls.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var urls map[string]string
+	if err := json.Unmarshal(data, &urls); err != nil {
+		t.Fatal(err)
+	}
+	const base = "https://csaf.example:8443/providers/main"
+	for field, want := range map[string]string{
+		"canonical":    base + "/.well-known/csaf/provider-metadata.json",
+		"distribution": base + "/.well-known/csaf",
+		// The namespace is the origin of the base URL without its path.
+		"namespace": "https://csaf.example:8443",
+	} {
+		if got := urls[field]; got != want {
+			t.Errorf("got %s URL %q, want %q", field, got, want)
+		}
+	}
+}
+
+func TestInvalidTemplateData(t *testing.T) {
+	origin := testOrigin(t, map[string]map[string]string{
+		"main": {"white/urls.json": `{"base":"$((.BaseURL))$"}`},
+	})
+	for _, check := range []struct {
+		baseURL string
+		want    string
+	}{
+		{"", `building profile "main" failed: invalid template data: BaseURL is empty`},
+		{"csaf.example/{profile}", `invalid template data: BaseURL "csaf.example/main" is no absolute URL`},
+	} {
+		cfg := testConfig(t, origin, testKey(t), config.Profiles{
+			"main": {Branches: []string{"main"}},
+		})
+		cfg.Providers.BaseURL = check.baseURL
+		s := startSystem(t, cfg)
+		err := s.Serve(t.Context(), "main")
+		if err == nil || !strings.Contains(err.Error(), check.want) {
+			t.Errorf("%q: got error %v, want %q", check.baseURL, err, check.want)
+		}
+		if _, ok := s.CurrentExport("main"); ok {
+			t.Errorf("%q: profile is exported", check.baseURL)
+		}
+	}
+}
+
+func TestReproducibleSignatures(t *testing.T) {
+	origin := testOrigin(t, map[string]map[string]string{
+		"main": {"white/advisory.json": `{"document":{}}`},
+	})
+	key := testKey(t)
+	signTime := key.GetEntity().PrimaryKey.CreationTime
+	cfg := testConfig(t, origin, key, config.Profiles{
+		"main": {Branches: []string{"main"}},
+	})
+	cfg.Signing.FixedTimestamp = signTime.UTC().Format(time.RFC3339)
+	signature := filepath.Join("white", "advisory.json.asc")
+	for _, mode := range []string{config.SigningModeDetached, config.SigningModeClearsign} {
+		t.Run(mode, func(t *testing.T) {
+			cfg := *cfg
+			cfg.Signing.Mode = mode
+			cfg.Web.Root = filepath.Join(t.TempDir(), "web")
+			s := startSystem(t, &cfg)
+			export := serve(t, s, "main")
+			first, err := os.ReadFile(filepath.Join(export, signature))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Export the same revisions again.
+			if err := s.Rebuild(t.Context(), "main"); err != nil {
+				t.Fatal(err)
+			}
+			second, err := os.ReadFile(filepath.Join(serve(t, s, "main"), signature))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(first, second) {
+				t.Errorf("signatures differ:\n%s\n%s", first, second)
+			}
+		})
+	}
+
+	// The signatures are made at the fixed time.
+	data := []byte(`{"document":{}}`)
+	signed, err := newFixedSigner(key, signTime).Sign(data, crypto.Armor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := crypto.PGP().Verify().VerificationKey(key).New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := verifier.VerifyDetached(data, signed, crypto.Armor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created := result.SignatureCreationTime(); created != signTime.Unix() {
+		t.Errorf("got creation time %d, want %d", created, signTime.Unix())
+	}
+}
+
+func TestTemplateVars(t *testing.T) {
+	origin := testOrigin(t, map[string]map[string]string{
+		"main": {"white/vars.json": `{"publisher":"$((.Vars.publisher))$","contact":"$((.Vars.contact))$"}`},
+	})
+	main := []string{"main"}
+	cfg := testConfig(t, origin, testKey(t), config.Profiles{
+		"global":   {Branches: main},
+		"override": {Branches: main, TemplateVars: map[string]string{"publisher": "Override Corp"}},
+	})
+	cfg.Providers.TemplateVars = map[string]string{
+		"publisher": "Global Corp",
+		"contact":   "csaf@example.com",
+	}
+	s := startSystem(t, cfg)
+	for _, check := range []struct {
+		profile   string
+		publisher string
+	}{
+		{"global", "Global Corp"},
+		{"override", "Override Corp"},
+	} {
+		data, err := os.ReadFile(filepath.Join(serve(t, s, check.profile), "white", "vars.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var vars struct {
+			Publisher string `json:"publisher"`
+			Contact   string `json:"contact"`
+		}
+		if err := json.Unmarshal(data, &vars); err != nil {
+			t.Fatalf("%s: %v", check.profile, err)
+		}
+		if vars.Publisher != check.publisher {
+			t.Errorf("%s: got publisher %q, want %q", check.profile, vars.Publisher, check.publisher)
+		}
+		// The variables not overridden are taken from the global ones.
+		if vars.Contact != "csaf@example.com" {
+			t.Errorf("%s: got contact %q", check.profile, vars.Contact)
+		}
+	}
+}
+
+func TestProfileTemplateVars(t *testing.T) {
+	origin := testOrigin(t, map[string]map[string]string{
+		"main": {"white/publisher.txt": "$((.Vars.publisher))$"},
+	})
+	main := []string{"main"}
+	cfg := testConfig(t, origin, testKey(t), config.Profiles{
+		"a": {Branches: main, TemplateVars: map[string]string{"publisher": "Publisher A"}},
+		"b": {Branches: main, TemplateVars: map[string]string{"publisher": "Publisher B"}},
+	})
+	s := startSystem(t, cfg)
+	// The same template renders the publisher of each profile.
+	for profile, want := range map[string]string{"a": "Publisher A", "b": "Publisher B"} {
+		data, err := os.ReadFile(filepath.Join(serve(t, s, profile), "white", "publisher.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(data); got != want {
+			t.Errorf("%s: got publisher %q, want %q", profile, got, want)
+		}
+	}
+}
+
+func TestPrepareWebRoot(t *testing.T) {
+	base := t.TempDir()
+	missing := filepath.Join(base, "missing", "web")
+	if err := prepareWebRoot(missing); err != nil {
+		t.Fatalf("missing root: %v", err)
+	}
+	if info, err := os.Stat(missing); err != nil || !info.IsDir() {
+		t.Fatalf("missing root not created: %v", err)
+	}
+	if entries, _ := os.ReadDir(missing); len(entries) != 0 {
+		t.Errorf("probe left in root: %d entries", len(entries))
+	}
+
+	file := filepath.Join(base, "file")
+	writeFiles(t, base, map[string]string{"file": ""})
+	if err := prepareWebRoot(file); err == nil || !strings.Contains(err.Error(), "is not a directory") {
+		t.Errorf("file as root: got error %v", err)
+	}
+
+	t.Run("read-only", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("root can write to read-only directories")
+		}
+		readOnly := filepath.Join(base, "read-only")
+		if err := os.Mkdir(readOnly, 0o555); err != nil {
+			t.Fatal(err)
+		}
+		if err := prepareWebRoot(readOnly); err == nil || !strings.Contains(err.Error(), "is not writable") {
+			t.Errorf("read-only root: got error %v", err)
+		}
+	})
+}
+
+func TestServeSingleBranchFastPath(t *testing.T) {
+	origin := testOrigin(t, map[string]map[string]string{
+		"main": {"white/advisory.json": `{}`},
+		"a":    {"white/a.json": `{}`},
+	})
+	cfg := testConfig(t, origin, testKey(t), config.Profiles{
+		"single": {Branches: []string{"main"}},
+		"merged": {Branches: []string{"main", "a"}},
+	})
+	s := startSystem(t, cfg)
+	// Merges fail without a directory for their temporary worktrees.
+	t.Setenv("TMPDIR", filepath.Join(t.TempDir(), "missing"))
+	export := serve(t, s, "single")
+	if _, err := os.Stat(filepath.Join(export, "white", "advisory.json")); err != nil {
+		t.Errorf("single branch profile: %v", err)
+	}
+	if err := s.Serve(t.Context(), "merged"); err == nil {
+		t.Error("merged profile is built without a temporary worktree")
+	}
+}
+
+func TestParallelBuildsSharingBranch(t *testing.T) {
+	origin := testOrigin(t, map[string]map[string]string{
+		"main": {"white/main.json": `{}`},
+		"a":    {"white/a.json": `{}`},
+		"b":    {"white/b.json": `{}`},
+	})
+	profiles := config.Profiles{}
+	want := map[string][]string{}
+	for i := range 8 {
+		branch := []string{"a", "b"}[i%2]
+		profile := branch + strconv.Itoa(i)
+		profiles[profile] = &config.Profile{Branches: []string{"main", branch}}
+		want[profile] = []string{branch + ".json", "main.json"}
+	}
+	s := startSystem(t, testConfig(t, origin, testKey(t), profiles))
+
+	var wg sync.WaitGroup
+	for profile := range profiles {
+		wg.Go(func() {
+			if err := s.Serve(t.Context(), profile); err != nil {
+				t.Errorf("%s: %v", profile, err)
+			}
+		})
+	}
+	wg.Wait()
+	for profile, files := range want {
+		hash, ok := s.CurrentExport(profile)
+		if !ok {
+			t.Errorf("%s: not exported", profile)
+			continue
+		}
+		var got []string
+		entries, _ := os.ReadDir(filepath.Join(s.cfg.Web.Root, hash, "white"))
+		for _, entry := range entries {
+			if strings.HasSuffix(entry.Name(), ".json") {
+				got = append(got, entry.Name())
+			}
+		}
+		if !slices.Equal(got, files) {
+			t.Errorf("%s: got files %q, want %q", profile, got, files)
+		}
+	}
+	// The temporary worktrees are removed and the shared
+	// checkouts are left untouched.
+	clone := filepath.Join(s.cfg.Providers.WorkDir, "main")
+	if list := testGit(t, clone, "worktree", "list"); strings.Contains(list, "contravider-merge-") {
+		t.Errorf("temporary worktrees left:\n%s", list)
+	}
+	if status := testGit(t, clone, "status", "--porcelain"); status != "" {
+		t.Errorf("shared checkout left dirty:\n%s", status)
+	}
+}
@@ -0,0 +1,233 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+// verifyExports verifies the exports of the instantiated profiles
+// and rebuilds the ones failing the verification.
+func (s *System) verifyExports(ctx context.Context) {
+	var verified, rebuilt int
+	for profile := range s.Profiles() {
+		hash, ok := s.CurrentExport(profile)
+		if !ok {
+			continue
+		}
+		// The keys are replaced by reloads in the control goroutine.
+		var key *crypto.Key
+		s.do(func(s *System) error {
+			key = s.signingKey(profile)
+			return nil
+		})
+		err := s.verifyExport(profile, filepath.Join(s.cfg.Web.Root, hash), key)
+		if err == nil {
+			verified++
+			continue
+		}
+		slog.Warn("export does not verify", "profile", profile, "hash", hash, "error", err)
+		s.do(func(s *System) error {
+			s.removeProfile(profile, 0)
+			return nil
+		})
+		if err := s.Serve(ctx, profile); err != nil {
+			slog.Error("rebuilding profile failed", "profile", profile, "error", err)
+			continue
+		}
+		rebuilt++
+	}
+	slog.Info("verified exports", "verified", verified, "rebuilt", rebuilt)
+}
+
+// verifyExport checks if an export of a profile signed with key
+// is complete and intact. If the export has manifests all files are
+// checked against them. Otherwise the files signed and hashed by the
+// build are checked against their signatures and hashes.
+func (s *System) verifyExport(profile, dir string, key *crypto.Key) error {
+	bi, err := loadBuildInfo(filepath.Join(dir, buildInfoFile))
+	if err != nil {
+		return fmt.Errorf("no build info: %w", err)
+	}
+	if bi.Profile != profile || bi.Hash != filepath.Base(dir) {
+		return fmt.Errorf("build info of %q@%s does not match", bi.Profile, bi.Hash)
+	}
+	// Profiles in passthrough mode are neither signed nor hashed.
+	if p := s.Profiles()[profile]; p != nil && p.Passthrough {
+		return nil
+	}
+	manifestPath := filepath.Join(dir, manifestFile)
+	if !checkFileNotExists(manifestPath) {
+		return verifyManifests(dir, key)
+	}
+	patterns, err := s.buildPatternActions(profile, key)
+	if err != nil {
+		return fmt.Errorf("building patterns failed: %w", err)
+	}
+	// Clearsigned files do not sign the content of the files next to them.
+	var verifier crypto.PGPVerify
+	if s.cfg.Signing.Mode != config.SigningModeClearsign {
+		publicKey, err := exportedPublicKey(key)
+		if err != nil {
+			return err
+		}
+		if verifier, err = crypto.PGP().Verify().VerificationKey(publicKey).New(); err != nil {
+			return fmt.Errorf("building verifier failed: %w", err)
+		}
+	}
+	hashes := s.hashes(profile)
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// The build info is written after signing and hashing.
+		if !info.Mode().IsRegular() || info.Name() == buildInfoFile ||
+			!patterns.hasActions(info.Name()) {
+			return nil
+		}
+		for _, ext := range append([]string{"asc"}, hashes...) {
+			if checkFileNotExists(path + "." + ext) {
+				return fmt.Errorf("%q is missing", path+"."+ext)
+			}
+		}
+		return verifyContent(path, verifier, hashes)
+	})
+}
+
+// verifyContent checks if the content of a file matches its signature
+// or its hashes. The branches may supply broken signatures and hashes
+// on purpose, which are kept by the build. So the content is only
+// reported if none of them matches. The content is streamed.
+func verifyContent(file string, verifier crypto.PGPVerify, hashes []string) error {
+	if verifier == nil && len(hashes) == 0 {
+		return nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if verifier != nil {
+		if verifyDetached(file, f, verifier) == nil {
+			return nil
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	// All hashes are computed in one pass over the content.
+	hashers := make([]hash.Hash, len(hashes))
+	writers := make([]io.Writer, len(hashes))
+	for i, name := range hashes {
+		switch name {
+		case config.HashSHA256:
+			hashers[i] = sha256.New()
+		case config.HashSHA512:
+			hashers[i] = sha512.New()
+		}
+		writers[i] = hashers[i]
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), f); err != nil {
+		return err
+	}
+	for i, name := range hashes {
+		sum := hashers[i].Sum(nil)
+		// All hash formats start with the hex encoded sum.
+		line, err := os.ReadFile(file + "." + name)
+		if err != nil {
+			return err
+		}
+		if fields := strings.Fields(string(line)); len(fields) > 0 &&
+			fields[0] == hex.EncodeToString(sum) {
+			return nil
+		}
+	}
+	return fmt.Errorf("content of %q matches neither its signature nor its hashes", file)
+}
+
+// verifyManifests verifies the manifests of the root and
+// the protected folders of an export.
+func verifyManifests(dir string, key *crypto.Key) error {
+	directory, err := LoadDirectory(filepath.Join(dir, ".directories.json"))
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, d := range manifestDirs(directory) {
+		folder := filepath.Join(dir, filepath.FromSlash(d))
+		if info, err := os.Stat(folder); err != nil || !info.IsDir() {
+			continue
+		}
+		errs = append(errs, verifyManifest(folder, key))
+	}
+	return errors.Join(errs...)
+}
+
+// verifyManifest verifies the signature of the manifest in a folder
+// and the hashes of all files listed in it.
+func verifyManifest(dir string, key *crypto.Key) error {
+	manifestPath := filepath.Join(dir, manifestFile)
+	manifest, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+	publicKey, err := exportedPublicKey(key)
+	if err != nil {
+		return err
+	}
+	verifier, err := crypto.PGP().Verify().VerificationKey(publicKey).New()
+	if err != nil {
+		return fmt.Errorf("building verifier failed: %w", err)
+	}
+	if err := verifyDetached(manifestPath, bytes.NewReader(manifest), verifier); err != nil {
+		return err
+	}
+	var errs []error
+	hash := sha256.New()
+	for sc := bufio.NewScanner(bytes.NewReader(manifest)); sc.Scan(); {
+		sum, rel, ok := strings.Cut(sc.Text(), "  ")
+		if !ok {
+			return fmt.Errorf("invalid manifest line %q", sc.Text())
+		}
+		f, err := os.Open(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		hash.Reset()
+		_, err = io.Copy(hash, f)
+		f.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to hash %q: %w", rel, err))
+			continue
+		}
+		if hex.EncodeToString(hash.Sum(nil)) != sum {
+			errs = append(errs, fmt.Errorf("hash of %q does not match", rel))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,115 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+func TestVerifyOnStart(t *testing.T) {
+	origin := testOrigin(t, map[string]map[string]string{
+		"main":  {"white/advisory.json": `{"document":{}}`},
+		"other": {"white/advisory.json": `{"document":{"other":true}}`},
+		"third": {"white/advisory.json": `{"document":{"third":true}}`},
+		// A negative test supplying a broken signature on purpose.
+		"negative": {
+			"white/advisory.json":     `{"document":{"negative":true}}`,
+			"white/advisory.json.asc": "broken on purpose",
+		},
+	})
+	cfg := testConfig(t, origin, testKey(t), config.Profiles{
+		"broken":    {Branches: []string{"main"}},
+		"corrupted": {Branches: []string{"third"}},
+		"intact":    {Branches: []string{"other"}},
+		"negative":  {Branches: []string{"negative"}},
+	})
+	cfg.Providers.VerifyOnStart = true
+	exports := map[string]string{}
+	t.Run("first boot", func(t *testing.T) {
+		s := startSystem(t, cfg)
+		for profile := range cfg.Providers.Profiles {
+			exports[profile] = serve(t, s, profile)
+		}
+	})
+	// Break two exports while the system is down and mark the
+	// others to see that they are kept.
+	signature := filepath.Join(exports["broken"], "white", "advisory.json.asc")
+	if err := os.Remove(signature); err != nil {
+		t.Fatal(err)
+	}
+	// The signature and the hashes of a truncated file are still there.
+	corrupted := filepath.Join(exports["corrupted"], "white", "advisory.json")
+	if err := os.WriteFile(corrupted, []byte(`{"document":`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, profile := range []string{"intact", "negative"} {
+		writeFiles(t, exports[profile], map[string]string{"marker": ""})
+	}
+
+	var log lockedBuffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&log, nil)))
+	t.Cleanup(func() { slog.SetDefault(old) })
+	s := startSystem(t, cfg)
+	deadline := time.Now().Add(10 * time.Second)
+	for !strings.Contains(log.String(), "verified exports") {
+		if time.Now().After(deadline) {
+			t.Fatal("exports are not verified")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if want := "verified=2 rebuilt=2"; !strings.Contains(log.String(), want) {
+		t.Errorf("missing %q in log:\n%s", want, log.String())
+	}
+	if data, _ := os.ReadFile(corrupted); string(data) != `{"document":{"third":true}}` {
+		t.Errorf("corrupted file is not rebuilt: %q", data)
+	}
+	for _, profile := range []string{"broken", "corrupted"} {
+		if hash, _ := s.CurrentExport(profile); filepath.Join(cfg.Web.Root, hash) != exports[profile] {
+			t.Errorf("rebuilt profile %s has export %q", profile, hash)
+		}
+		if !verifies(t, filepath.Join(exports[profile], "white", "advisory.json"), s.signingKey(profile)) {
+			t.Errorf("signature of the rebuilt export of %s does not verify", profile)
+		}
+	}
+	for _, profile := range []string{"intact", "negative"} {
+		if checkFileNotExists(filepath.Join(exports[profile], "marker")) {
+			t.Errorf("intact export of %s is rebuilt", profile)
+		}
+	}
+}
+
+// lockedBuffer is a buffer safe for concurrent use.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (lb *lockedBuffer) Write(p []byte) (int, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.buf.Write(p)
+}
+
+func (lb *lockedBuffer) String() string {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.buf.String()
+}
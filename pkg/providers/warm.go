@@ -0,0 +1,103 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+// servedFile is the name of the file in the web root
+// storing when the profiles were served last.
+const servedFile = ".served.json"
+
+// loadServed loads the times the given profiles were served last.
+// Entries of profiles which are not configured any more are dropped.
+func loadServed(root string, profiles config.Profiles) (map[string]time.Time, error) {
+	served := map[string]time.Time{}
+	data, err := os.ReadFile(filepath.Join(root, servedFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return served, nil
+		}
+		return nil, fmt.Errorf("reading served profiles failed: %w", err)
+	}
+	if err := json.Unmarshal(data, &served); err != nil {
+		return nil, fmt.Errorf("decoding served profiles failed: %w", err)
+	}
+	maps.DeleteFunc(served, func(profile string, _ time.Time) bool {
+		_, ok := profiles[profile]
+		return !ok
+	})
+	return served, nil
+}
+
+// saveServed stores the times the profiles were served last.
+func (s *System) saveServed() {
+	data, err := json.Marshal(s.served)
+	if err != nil {
+		slog.Error("encoding served profiles failed", "error", err)
+		return
+	}
+	fname := filepath.Join(s.cfg.Web.Root, servedFile)
+	tmp := fname + ".tmp"
+	if err := errors.Join(
+		os.WriteFile(tmp, data, 0644),
+		os.Rename(tmp, fname),
+	); err != nil {
+		slog.Error("storing served profiles failed", "error", err)
+	}
+}
+
+// markServed records that a profile was served. The state file
+// is only updated if the profile was not served before.
+func (s *System) markServed(profile string) {
+	_, known := s.served[profile]
+	s.served[profile] = time.Now()
+	if !known {
+		s.saveServed()
+	}
+}
+
+// forgetServed removes a profile from the served profiles.
+func (s *System) forgetServed(profile string) {
+	if _, known := s.served[profile]; known {
+		delete(s.served, profile)
+		s.saveServed()
+	}
+}
+
+// prewarm builds the profiles served before the last shutdown.
+// The least recently served ones are built first so they are
+// evicted first if there are too many.
+func (s *System) prewarm(ctx context.Context, served map[string]time.Time) {
+	profiles := slices.SortedFunc(maps.Keys(served), func(a, b string) int {
+		return cmp.Or(served[a].Compare(served[b]), cmp.Compare(a, b))
+	})
+	for _, profile := range profiles {
+		slog.Debug("prewarming profile", "profile", profile)
+		if err := s.Serve(ctx, profile); err != nil {
+			slog.Error("prewarming profile failed", "profile", profile, "error", err)
+		}
+	}
+	slog.Info("prewarmed profiles", "profiles", len(profiles))
+}
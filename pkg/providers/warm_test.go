@@ -0,0 +1,61 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package providers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+func TestPrewarmServed(t *testing.T) {
+	origin := testOrigin(t, map[string]map[string]string{
+		"main": {"white/advisory.json": `{}`},
+	})
+	main := []string{"main"}
+	cfg := testConfig(t, origin, testKey(t), config.Profiles{
+		"a": {Branches: main},
+		"b": {Branches: main},
+		"c": {Branches: main},
+	})
+	cfg.Providers.Prewarm = true
+	t.Run("first boot", func(t *testing.T) {
+		s := startSystem(t, cfg)
+		serve(t, s, "a")
+		serve(t, s, "c")
+	})
+	data, err := os.ReadFile(filepath.Join(cfg.Web.Root, servedFile))
+	if err != nil {
+		t.Fatalf("served profiles not persisted: %v", err)
+	}
+
+	// Boot again with an empty web root except of the served profiles.
+	cfg.Web.Root = filepath.Join(t.TempDir(), "web")
+	writeFiles(t, cfg.Web.Root, map[string]string{servedFile: string(data)})
+	s := startSystem(t, cfg)
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		a, c := s.instantiated("a"), s.instantiated("c")
+		if a && c {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("served profiles not prewarmed: a %t, c %t", a, c)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s.instantiated("b") {
+		t.Error("profile not served before is prewarmed")
+	}
+}
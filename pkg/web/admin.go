@@ -0,0 +1,195 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package web
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/csaf-testsuite/contravider/pkg/providers"
+)
+
+// adminCredentials are the credentials of the admin endpoints.
+type adminCredentials struct {
+	user     string
+	password string
+}
+
+// UpdateAdminCredentials replaces the credentials of the admin
+// endpoints. An empty user disables the admin endpoints.
+func (c *Controller) UpdateAdminCredentials(user, password string) {
+	c.admin.Store(&adminCredentials{user: user, password: password})
+}
+
+// SetReload sets the function reloading the configuration on
+// POST /admin/reload. It has to be set before binding the endpoints.
+func (c *Controller) SetReload(reload func(context.Context) error) {
+	c.reload = reload
+}
+
+// reloadConfig reloads the configuration like a SIGHUP does.
+// The answer lists the profiles served afterwards.
+func (c *Controller) reloadConfig(rw http.ResponseWriter, req *http.Request) {
+	if err := c.reload(req.Context()); err != nil {
+		http.Error(rw,
+			"reloading configuration failed: "+err.Error(),
+			http.StatusUnprocessableEntity)
+		return
+	}
+	writeJSON(rw, http.StatusOK, struct {
+		Profiles []string `json:"profiles"`
+	}{
+		Profiles: c.sys.Profiles().Tagged(""),
+	})
+}
+
+// adminAuth protects an admin endpoint with the admin credentials.
+// Without configured credentials the admin endpoints are forbidden.
+func (c *Controller) adminAuth(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		admin := c.admin.Load()
+		if admin.user == "" {
+			http.Error(rw, "Forbidden: no admin credentials configured", http.StatusForbidden)
+			return
+		}
+		user, password, ok := req.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(user), []byte(admin.user)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(admin.password)) != 1 {
+			if ok {
+				slog.Warn("admin authentication failed",
+					"user", user,
+					"path", req.URL.Path,
+					"client", clientIP(req))
+			}
+			rw.Header().Set("WWW-Authenticate", `Basic realm="admin"`)
+			http.Error(rw, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(rw, req)
+	})
+}
+
+// createPreview builds a preview of a profile with some of its
+// branches replaced by other refs of the git remote.
+func (c *Controller) createPreview(rw http.ResponseWriter, req *http.Request) {
+	var input struct {
+		Profile string            `json:"profile"`
+		Refs    map[string]string `json:"refs"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(rw, req.Body, 1<<16)).Decode(&input); err != nil {
+		http.Error(rw, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	preview, err := c.sys.BuildPreview(req.Context(), input.Profile, input.Refs)
+	switch {
+	case errors.Is(err, providers.ErrProfileNotFound):
+		http.NotFound(rw, req)
+		return
+	case errors.Is(err, providers.ErrInsufficientStorage):
+		http.Error(rw, err.Error(), http.StatusInsufficientStorage)
+		return
+	case errors.Is(err, providers.ErrInvalidPreview):
+		http.Error(rw, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(rw,
+			"internal server error: "+err.Error(),
+			http.StatusInternalServerError)
+		return
+	}
+	writeJSON(rw, http.StatusCreated, struct {
+		Token   string    `json:"token"`
+		Path    string    `json:"path"`
+		Expires time.Time `json:"expires"`
+	}{
+		Token:   preview.Token,
+		Path:    "/preview/" + preview.Token + "/",
+		Expires: preview.Expires,
+	})
+}
+
+// rebuild rebuilds the profiles given by the query parameters
+// profile (repeatable) and tag.
+func (c *Controller) rebuild(rw http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	names := query["profile"]
+	if tag := query.Get("tag"); tag != "" {
+		names = append(names, c.sys.Profiles().Tagged(tag)...)
+	} else if len(names) == 0 {
+		http.Error(rw, "bad request: missing parameter profile or tag", http.StatusBadRequest)
+		return
+	}
+	slices.Sort(names)
+	names = slices.Compact(names)
+	if len(names) == 0 {
+		http.NotFound(rw, req)
+		return
+	}
+	var (
+		rebuilt = []string{}
+		failed  = map[string]string{}
+	)
+	for _, name := range names {
+		if err := c.sys.Rebuild(req.Context(), name); err != nil {
+			slog.Error("rebuilding profile failed", "profile", name, "error", err)
+			failed[name] = err.Error()
+			continue
+		}
+		rebuilt = append(rebuilt, name)
+	}
+	status := http.StatusOK
+	if len(failed) > 0 {
+		status = http.StatusInternalServerError
+	}
+	writeJSON(rw, status, struct {
+		Rebuilt []string          `json:"rebuilt"`
+		Failed  map[string]string `json:"failed,omitempty"`
+	}{
+		Rebuilt: rebuilt,
+		Failed:  failed,
+	})
+}
+
+// rebuildProfile removes the current export of a single profile
+// and builds it again, e.g. to sign it again after a key rotation.
+// The answer contains the hash of the new export.
+func (c *Controller) rebuildProfile(rw http.ResponseWriter, req *http.Request) {
+	name := req.PathValue("name")
+	switch err := c.sys.Rebuild(req.Context(), name); {
+	case errors.Is(err, providers.ErrProfileNotFound):
+		http.NotFound(rw, req)
+		return
+	case errors.Is(err, providers.ErrInsufficientStorage):
+		http.Error(rw, err.Error(), http.StatusInsufficientStorage)
+		return
+	case err != nil:
+		slog.Error("rebuilding profile failed", "profile", name, "error", err)
+		http.Error(rw,
+			"internal server error: "+err.Error(),
+			http.StatusInternalServerError)
+		return
+	}
+	export, _ := c.sys.CurrentExport(name)
+	writeJSON(rw, http.StatusAccepted, struct {
+		Profile string `json:"profile"`
+		Export  string `json:"export"`
+	}{
+		Profile: name,
+		Export:  export,
+	})
+}
@@ -0,0 +1,199 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+// originOf returns the directory of the git remote of a test server.
+func originOf(c *Controller) string {
+	return strings.TrimPrefix(c.cfg.Providers.GitURL, "file://")
+}
+
+// decodeJSON decodes a JSON answer.
+func decodeJSON(t *testing.T, body string, v any) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(body), v); err != nil {
+		t.Fatalf("invalid JSON %q: %v", body, err)
+	}
+}
+
+// adminRequest returns a request authenticated with the admin credentials.
+func adminRequest(c *Controller, method, path, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.SetBasicAuth(c.cfg.Web.AdminUser, c.cfg.Web.AdminPassword)
+	return req
+}
+
+// withAdmin configures the admin credentials of a test server.
+func withAdmin(cfg *config.Config) {
+	cfg.Web.AdminUser = "admin"
+	cfg.Web.AdminPassword = "secret"
+}
+
+func TestPreview(t *testing.T) {
+	c := newTestServer(t, withAdmin)
+	// A pull request changing the advisory.
+	origin := originOf(c)
+	testGit(t, origin, "checkout", "-q", "-b", "feature")
+	writeFile(t, origin, "data/white/advisory.json", `{"document":{"changed":true}}`)
+	testGit(t, origin, "commit", "-q", "-am", "change")
+	testGit(t, origin, "checkout", "-q", "main")
+
+	public, admin := c.Bind(), c.BindAdmin()
+	code, body := request(t, admin, adminRequest(c, http.MethodPost, "/admin/preview",
+		`{"profile":"main","refs":{"main":"feature"}}`))
+	if code != http.StatusCreated {
+		t.Fatalf("creating preview: got status %d: %s", code, body)
+	}
+	var preview struct {
+		Path string `json:"path"`
+	}
+	decodeJSON(t, body, &preview)
+
+	code, body = getPath(t, public, preview.Path+"white/advisory.json")
+	if code != http.StatusOK || body != `{"document":{"changed":true}}` {
+		t.Errorf("preview: got status %d, body %q", code, body)
+	}
+
+	// The profile is not changed by the preview.
+	code, body = getPath(t, public, "/main/white/advisory.json")
+	if code != http.StatusOK || body != testFiles["white/advisory.json"] {
+		t.Errorf("profile: got status %d, body %q", code, body)
+	}
+
+	// Unknown refs and branches not in the profile are rejected.
+	for _, input := range []string{
+		`{"profile":"main","refs":{"main":"missing"}}`,
+		`{"profile":"main","refs":{"other":"feature"}}`,
+	} {
+		if code, _ := request(t, admin, adminRequest(c, http.MethodPost, "/admin/preview",
+			input)); code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, want %d", input, code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestAdminListener(t *testing.T) {
+	c := newTestServer(t, withAdmin)
+	public, admin := c.Bind(), c.BindAdmin()
+	// The public listener doesn't know the admin endpoints
+	// even with valid credentials.
+	if code, _ := request(t, public, adminRequest(c, http.MethodPost, "/admin/preview", "")); code != http.StatusNotFound {
+		t.Errorf("public preview: got status %d, want %d", code, http.StatusNotFound)
+	}
+	// An empty preview request passes the authentication only.
+	if code, body := request(t, admin, adminRequest(c, http.MethodPost, "/admin/preview", "")); code != http.StatusBadRequest {
+		t.Errorf("admin preview: got status %d, want %d: %s", code, http.StatusBadRequest, body)
+	}
+	if code, _ := request(t, admin, httptest.NewRequest(http.MethodPost, "/admin/preview", nil)); code != http.StatusUnauthorized {
+		t.Errorf("admin preview without credentials: got status %d, want %d", code, http.StatusUnauthorized)
+	}
+	// The admin listener serves no profiles.
+	if code, _ := getPath(t, admin, "/main/white/advisory.json"); code != http.StatusNotFound {
+		t.Errorf("profile on admin listener: got status %d, want %d", code, http.StatusNotFound)
+	}
+	if code, _ := getPath(t, admin, "/api/archive/main?format=tar"); code != http.StatusOK {
+		t.Errorf("API on admin listener: got status %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestAdminWithoutCredentials(t *testing.T) {
+	c := newTestServer(t, nil)
+	admin := c.BindAdmin()
+	// Without admin credentials the admin endpoints are forbidden
+	// instead of open to everyone reaching the admin listener.
+	for _, path := range []string{
+		"/admin/preview",
+		"/admin/rebuild?profile=main",
+		"/admin/maintenance?enabled=true",
+		"/api/profiles/main/rebuild",
+	} {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		if code, _ := request(t, admin, req); code != http.StatusForbidden {
+			t.Errorf("%s: got status %d, want %d", path, code, http.StatusForbidden)
+		}
+	}
+	// The read only API is still served.
+	if code, _ := getPath(t, admin, "/api/profiles"); code != http.StatusOK {
+		t.Errorf("API: got status %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestPprof(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		c := newTestServer(t, func(cfg *config.Config) {
+			cfg.Debug.Pprof = enabled
+			withAdmin(cfg)
+		})
+		want := http.StatusNotFound
+		if enabled {
+			want = http.StatusOK
+		}
+		if code, _ := request(t, c.BindAdmin(), adminRequest(c, http.MethodGet, "/debug/pprof/cmdline", "")); code != want {
+			t.Errorf("enabled %t: admin listener: got status %d, want %d", enabled, code, want)
+		}
+		if code, _ := getPath(t, c.Bind(), "/debug/pprof/cmdline"); code != http.StatusNotFound {
+			t.Errorf("enabled %t: public listener: got status %d, want %d",
+				enabled, code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	c := newTestServer(t, withAdmin)
+	public, admin := c.Bind(), c.BindAdmin()
+	maintenance := func(enabled string) {
+		t.Helper()
+		req := adminRequest(c, http.MethodPost, "/admin/maintenance?enabled="+enabled, "")
+		if code, body := request(t, admin, req); code != http.StatusOK {
+			t.Fatalf("maintenance %s: got status %d: %s", enabled, code, body)
+		}
+	}
+	content := []string{"/main/white/advisory.json", "/", "/api/profiles", "/robots.txt"}
+	check := func(want int) {
+		t.Helper()
+		for _, path := range content {
+			rec := httptest.NewRecorder()
+			public.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			if rec.Code != want {
+				t.Errorf("%s: got status %d, want %d", path, rec.Code, want)
+			}
+			if retry := rec.Header().Get("Retry-After"); (retry != "") != (want == http.StatusServiceUnavailable) {
+				t.Errorf("%s: got Retry-After %q", path, retry)
+			}
+		}
+	}
+	check(http.StatusOK)
+	maintenance("true")
+	check(http.StatusServiceUnavailable)
+	// The health checks and the admin endpoints still work.
+	if code, _ := getPath(t, public, "/healthz"); code != http.StatusOK {
+		t.Errorf("health check in maintenance: got status %d", code)
+	}
+	if code, _ := getPath(t, admin, "/api/profiles"); code != http.StatusOK {
+		t.Errorf("admin API in maintenance: got status %d", code)
+	}
+	maintenance("false")
+	check(http.StatusOK)
+
+	req := adminRequest(c, http.MethodPost, "/admin/maintenance?enabled=maybe", "")
+	if code, _ := request(t, admin, req); code != http.StatusBadRequest {
+		t.Errorf("invalid parameter: got status %d, want %d", code, http.StatusBadRequest)
+	}
+}
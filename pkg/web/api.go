@@ -0,0 +1,95 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/csaf-testsuite/contravider/pkg/providers"
+)
+
+// writeJSON writes a JSON document as response.
+func writeJSON(rw http.ResponseWriter, status int, v any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		slog.Error("cannot write JSON response", "error", err)
+	}
+}
+
+// diff compares the files of two exports given by the
+// query parameters a and b as "profile" or "profile@hash".
+func (c *Controller) diff(rw http.ResponseWriter, req *http.Request) {
+	a, b := req.URL.Query().Get("a"), req.URL.Query().Get("b")
+	if a == "" || b == "" {
+		http.Error(rw, "bad request: missing parameter a or b", http.StatusBadRequest)
+		return
+	}
+	diff, err := c.sys.Diff(req.Context(), a, b)
+	switch {
+	case errors.Is(err, providers.ErrProfileNotFound),
+		errors.Is(err, providers.ErrExportNotFound):
+		http.Error(rw, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, providers.ErrInsufficientStorage):
+		http.Error(rw, err.Error(), http.StatusInsufficientStorage)
+		return
+	case err != nil:
+		http.Error(rw,
+			"internal server error: "+err.Error(),
+			http.StatusInternalServerError)
+		return
+	}
+	writeJSON(rw, http.StatusOK, diff)
+}
+
+// signingKey describes the default signing key and its expiry.
+func (c *Controller) signingKey(rw http.ResponseWriter, _ *http.Request) {
+	writeJSON(rw, http.StatusOK, c.sys.SigningKeyInfo())
+}
+
+// listProfiles lists the profiles. The query parameter tag
+// restricts the list to the profiles with this tag.
+func (c *Controller) listProfiles(rw http.ResponseWriter, req *http.Request) {
+	type profile struct {
+		Name        string   `json:"name"`
+		Branches    []string `json:"branches"`
+		Tags        []string `json:"tags,omitempty"`
+		Crawlable   bool     `json:"crawlable,omitempty"`
+		Passthrough bool     `json:"passthrough,omitempty"`
+		// Instantiated profiles have the hash of their current export.
+		Instantiated bool   `json:"instantiated"`
+		Export       string `json:"export,omitempty"`
+		// Progress is the fraction of the running build.
+		Progress *float64 `json:"progress,omitempty"`
+	}
+	profiles := c.sys.Profiles()
+	list := []profile{}
+	for _, name := range profiles.Tagged(req.URL.Query().Get("tag")) {
+		p := profiles[name]
+		entry := profile{
+			Name:        name,
+			Branches:    profiles.Branches(name),
+			Tags:        p.Tags,
+			Crawlable:   p.Crawlable,
+			Passthrough: p.Passthrough,
+		}
+		entry.Export, entry.Instantiated = c.sys.CurrentExport(name)
+		if progress, ok := c.sys.Progress(name); ok {
+			entry.Progress = &progress
+		}
+		list = append(list, entry)
+	}
+	writeJSON(rw, http.StatusOK, list)
+}
@@ -0,0 +1,160 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+func TestProfileTags(t *testing.T) {
+	c := newTestServer(t, func(cfg *config.Config) {
+		main := []string{"main"}
+		cfg.Providers.Profiles = config.Profiles{
+			"a": {Branches: main, Tags: []string{"negative", "req-7.1.5"}},
+			"b": {Branches: main, Tags: []string{"negative"}},
+			"c": {Branches: main},
+		}
+		withAdmin(cfg)
+	})
+	public, admin := c.Bind(), c.BindAdmin()
+	for _, check := range []struct {
+		tag  string
+		want []string
+	}{
+		{"", []string{"a", "b", "c"}},
+		{"negative", []string{"a", "b"}},
+		{"req-7.1.5", []string{"a"}},
+		{"unknown", nil},
+	} {
+		var profiles []struct {
+			Name string `json:"name"`
+		}
+		_, body := getPath(t, public, "/api/profiles?tag="+check.tag)
+		decodeJSON(t, body, &profiles)
+		var names []string
+		for _, p := range profiles {
+			names = append(names, p.Name)
+		}
+		if !slices.Equal(names, check.want) {
+			t.Errorf("tag %q: got profiles %q, want %q", check.tag, names, check.want)
+		}
+	}
+
+	// Mark the exports to see which ones are built again.
+	markers := map[string]string{}
+	for _, profile := range []string{"a", "b", "c"} {
+		if code, body := getPath(t, public, "/"+profile+"/white/advisory.json"); code != http.StatusOK {
+			t.Fatalf("%s: got status %d: %s", profile, code, body)
+		}
+		export, err := filepath.EvalSymlinks(filepath.Join(c.cfg.Web.Root, profile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		markers[profile] = filepath.Join(export, "marker")
+		writeFile(t, export, "marker", "")
+	}
+	code, body := request(t, admin, adminRequest(c, http.MethodPost, "/admin/rebuild?tag=negative", ""))
+	if code != http.StatusOK {
+		t.Fatalf("rebuild: got status %d: %s", code, body)
+	}
+	var result struct {
+		Rebuilt []string `json:"rebuilt"`
+	}
+	decodeJSON(t, body, &result)
+	if want := []string{"a", "b"}; !slices.Equal(result.Rebuilt, want) {
+		t.Errorf("got rebuilt %q, want %q", result.Rebuilt, want)
+	}
+	for profile, marker := range markers {
+		_, err := os.Stat(marker)
+		if rebuilt := err != nil; rebuilt != slices.Contains(result.Rebuilt, profile) {
+			t.Errorf("%s: export rebuilt: %t", profile, rebuilt)
+		}
+	}
+	if code, _ := request(t, admin, adminRequest(c, http.MethodPost, "/admin/rebuild?tag=unknown", "")); code != http.StatusNotFound {
+		t.Errorf("unknown tag: got status %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+// listedProfile is a profile listed by the API.
+type listedProfile struct {
+	Name         string   `json:"name"`
+	Branches     []string `json:"branches"`
+	Instantiated bool     `json:"instantiated"`
+	Export       string   `json:"export"`
+}
+
+func TestListProfiles(t *testing.T) {
+	c := newTestServer(t, func(cfg *config.Config) {
+		cfg.Providers.Profiles = config.Profiles{
+			"main":  {Branches: []string{"main"}},
+			"again": {Branches: []string{"#main"}},
+		}
+	})
+	handler := c.Bind()
+	list := func(path string) []listedProfile {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: got status %d: %s", path, rec.Code, rec.Body)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: got content type %q", path, ct)
+		}
+		var profiles []listedProfile
+		decodeJSON(t, rec.Body.String(), &profiles)
+		return profiles
+	}
+	want := []listedProfile{
+		{Name: "again", Branches: []string{"main"}},
+		{Name: "main", Branches: []string{"main"}},
+	}
+	for _, path := range []string{"/api/profiles", "/?format=json"} {
+		if got := list(path); !slices.EqualFunc(got, want, func(a, b listedProfile) bool {
+			return a.Name == b.Name && slices.Equal(a.Branches, b.Branches) &&
+				a.Instantiated == b.Instantiated && a.Export == b.Export
+		}) {
+			t.Errorf("%s: got %+v, want %+v", path, got, want)
+		}
+	}
+	// The root path stays HTML without the format parameter.
+	if _, body := getPath(t, handler, "/"); !strings.Contains(body, "<html") {
+		t.Errorf("root path is not HTML: %q", body)
+	}
+
+	if code, body := getPath(t, handler, "/main/white/advisory.json"); code != http.StatusOK {
+		t.Fatalf("got status %d: %s", code, body)
+	}
+	got := list("/api/profiles")
+	if got[0].Instantiated || got[0].Export != "" {
+		t.Errorf("profile %q is instantiated without a request", got[0].Name)
+	}
+	served := got[1]
+	if !served.Instantiated || served.Export == "" {
+		t.Fatalf("served profile is not instantiated: %+v", served)
+	}
+	if _, err := os.Stat(filepath.Join(c.cfg.Web.Root, "main")); err != nil {
+		t.Errorf("instantiated profile has no link: %v", err)
+	}
+
+	// The export is the folder of the current revision.
+	if _, err := os.Stat(filepath.Join(c.cfg.Web.Root, served.Export)); err != nil {
+		t.Errorf("export %q does not exist: %v", served.Export, err)
+	}
+}
@@ -0,0 +1,219 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package web
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/csaf-testsuite/contravider/pkg/providers"
+)
+
+// archiveTime is the modification time of all archive entries
+// to make archives of the same export byte-identical.
+var archiveTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// archiveFiles returns the sorted relative paths of the files of an
+// export to be archived. Internal files and files in protected
+// folders are left out.
+func archiveFiles(root string, dir *providers.Directory) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		parts := strings.Split(rel, "/")
+		if slices.Contains(internalFiles, parts[len(parts)-1]) ||
+			dir.FindProtection(parts[:len(parts)-1]) != nil {
+			return nil
+		}
+		files = append(files, rel)
+		return nil
+	})
+	// Don't depend on the order of the file system walk.
+	slices.Sort(files)
+	return files, err
+}
+
+// fileClasses selects which generated files are archived.
+type fileClasses struct {
+	sigs   bool
+	hashes bool
+}
+
+// filter removes the signatures and hashes not to be archived.
+// Only signatures of archived files count as signatures so that
+// the public key stays in the archive.
+func (fc fileClasses) filter(files []string) []string {
+	var filtered []string
+	for _, file := range files {
+		if signed, ok := strings.CutSuffix(file, ".asc"); ok {
+			if _, found := slices.BinarySearch(files, signed); found && !fc.sigs {
+				continue
+			}
+		} else if !fc.hashes &&
+			(strings.HasSuffix(file, ".sha256") || strings.HasSuffix(file, ".sha512")) {
+			continue
+		}
+		filtered = append(filtered, file)
+	}
+	return filtered
+}
+
+// archiveFile streams a file of an export into an archive. The
+// entry is created with the size of the file before its contents
+// are copied so that large files are not read into memory.
+func archiveFile(
+	root, file string,
+	entry func(size int64) (io.Writer, error),
+) error {
+	f, err := os.Open(filepath.Join(root, filepath.FromSlash(file)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	w, err := entry(info.Size())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// writeTar writes the files as a tar stream with fixed metadata.
+func writeTar(w io.Writer, root string, files []string) error {
+	tw := tar.NewWriter(w)
+	for _, file := range files {
+		if err := archiveFile(root, file, func(size int64) (io.Writer, error) {
+			return tw, tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     file,
+				Size:     size,
+				Mode:     0644,
+				ModTime:  archiveTime,
+				Format:   tar.FormatUSTAR,
+			})
+		}); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// writeZip writes the files as a zip archive with fixed metadata.
+func writeZip(w io.Writer, root string, files []string) error {
+	zw := zip.NewWriter(w)
+	for _, file := range files {
+		if err := archiveFile(root, file, func(int64) (io.Writer, error) {
+			hdr := &zip.FileHeader{
+				Name:     file,
+				Method:   zip.Deflate,
+				Modified: archiveTime,
+			}
+			hdr.SetMode(0644)
+			return zw.CreateHeader(hdr)
+		}); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// archive serves the export of a profile as a tar or zip archive.
+func (c *Controller) archive(rw http.ResponseWriter, req *http.Request) {
+	profile := req.PathValue("profile")
+	var write func(io.Writer, string, []string) error
+	switch format := req.URL.Query().Get("format"); format {
+	case "", "tar":
+		write = writeTar
+		rw.Header().Set("Content-Type", "application/x-tar")
+		rw.Header().Set("Content-Disposition", `attachment; filename="`+profile+`.tar"`)
+	case "zip":
+		write = writeZip
+		rw.Header().Set("Content-Type", "application/zip")
+		rw.Header().Set("Content-Disposition", `attachment; filename="`+profile+`.zip"`)
+	default:
+		http.Error(rw, fmt.Sprintf("bad request: unknown format %q", format), http.StatusBadRequest)
+		return
+	}
+	classes := fileClasses{sigs: true, hashes: true}
+	for param, value := range map[string]*bool{
+		"include_sigs":   &classes.sigs,
+		"include_hashes": &classes.hashes,
+	} {
+		if v := req.URL.Query().Get(param); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				http.Error(rw, fmt.Sprintf("bad request: invalid %s %q", param, v), http.StatusBadRequest)
+				return
+			}
+			*value = b
+		}
+	}
+	switch err := c.sys.Serve(req.Context(), profile); {
+	case errors.Is(err, providers.ErrProfileNotFound):
+		http.NotFound(rw, req)
+		return
+	case errors.Is(err, providers.ErrInsufficientStorage):
+		http.Error(rw, err.Error(), http.StatusInsufficientStorage)
+		return
+	case err != nil:
+		http.Error(rw,
+			"internal server error: "+err.Error(),
+			http.StatusInternalServerError)
+		return
+	}
+	root, err := filepath.EvalSymlinks(filepath.Join(c.cfg.Web.Root, profile))
+	if err != nil {
+		http.Error(rw,
+			"internal server error: "+err.Error(),
+			http.StatusInternalServerError)
+		return
+	}
+	dir, err := providers.LoadDirectory(filepath.Join(root, ".directories.json"))
+	if err != nil {
+		http.Error(rw,
+			"internal server error: "+err.Error(),
+			http.StatusInternalServerError)
+		return
+	}
+	files, err := archiveFiles(root, dir)
+	if err != nil {
+		http.Error(rw,
+			"internal server error: "+err.Error(),
+			http.StatusInternalServerError)
+		return
+	}
+	if err := write(rw, root, classes.filter(files)); err != nil {
+		slog.Error("writing archive failed", "profile", profile, "error", err)
+	}
+}
@@ -0,0 +1,238 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package web
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/csaf-testsuite/contravider/pkg/providers"
+)
+
+// writeExport writes an export with a public file and
+// a file in a protected folder into base.
+func writeExport(t *testing.T, base, name string) {
+	t.Helper()
+	writeFile(t, base, name+"/public.txt", "public")
+	writeFile(t, base, name+"/amber/secret.txt", "secret")
+	writeProtection(t, base, name, "user", "secret")
+}
+
+// writeProtection protects the amber folder of an export with
+// the given credentials.
+func writeProtection(t *testing.T, base, name, user, password string) {
+	t.Helper()
+	writeDirectory(t, base, name, &providers.Directory{Folders: []*providers.Directory{{
+		Name:       "amber",
+		Protection: &providers.Protection{User: user, Password: password},
+	}}})
+}
+
+// writeDirectory writes the directives of an export.
+func writeDirectory(t *testing.T, base, name string, dir *providers.Directory) {
+	t.Helper()
+	data, err := json.Marshal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, name, ".directories.json"), data, 0o666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// archiveExport archives an export in base with the given writer.
+func archiveExport(
+	t *testing.T,
+	base, name string,
+	write func(io.Writer, string, []string) error,
+) []byte {
+	t.Helper()
+	root := filepath.Join(base, name)
+	dir, err := providers.LoadDirectory(filepath.Join(root, ".directories.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := archiveFiles(root, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := write(&buf, root, files); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestArchiveFiles(t *testing.T) {
+	base := t.TempDir()
+	writeExport(t, base, "export")
+	root := filepath.Join(base, "export")
+	dir, err := providers.LoadDirectory(filepath.Join(root, ".directories.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := archiveFiles(root, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The internal files and the protected folder are left out.
+	if want := []string{"public.txt"}; !slices.Equal(files, want) {
+		t.Errorf("got files %q, want %q", files, want)
+	}
+}
+
+func TestArchivesByteIdentical(t *testing.T) {
+	base := t.TempDir()
+	writeExport(t, base, "a")
+	writeExport(t, base, "b")
+	// The second export differs in the metadata of its files only.
+	public := filepath.Join(base, "b", "public.txt")
+	if err := os.Chtimes(public, time.Now(), time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(public, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range []struct {
+		name  string
+		write func(io.Writer, string, []string) error
+	}{
+		{"tar", writeTar},
+		{"zip", writeZip},
+	} {
+		t.Run(format.name, func(t *testing.T) {
+			first := archiveExport(t, base, "a", format.write)
+			if again := archiveExport(t, base, "a", format.write); !bytes.Equal(first, again) {
+				t.Error("archives of the same export differ")
+			}
+			if other := archiveExport(t, base, "b", format.write); !bytes.Equal(first, other) {
+				t.Error("archives of exports differing in metadata differ")
+			}
+		})
+	}
+}
+
+// archiveEntries downloads the tar archive of a profile and
+// returns the names of its entries.
+func archiveEntries(t *testing.T, handler http.Handler, query string) []string {
+	t.Helper()
+	code, body := getPath(t, handler, "/api/archive/main"+query)
+	if code != http.StatusOK {
+		t.Fatalf("%s: got status %d: %s", query, code, body)
+	}
+	var names []string
+	tr := tar.NewReader(bytes.NewReader([]byte(body)))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return names
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+}
+
+func TestArchiveFileClasses(t *testing.T) {
+	handler := newTestServer(t, nil).Bind()
+	var (
+		docs   = []string{".well-known/csaf/provider-metadata.json", "white/advisory.json"}
+		sigs   = []string{"white/advisory.json.asc"}
+		hashes = []string{"white/advisory.json.sha256", "white/advisory.json.sha512"}
+		keys   []string
+	)
+	// The public key is named after its id.
+	for _, name := range archiveEntries(t, handler, "") {
+		if !strings.Contains(name, "/") && strings.HasSuffix(name, ".asc") {
+			keys = append(keys, name)
+		}
+	}
+	if len(keys) != 1 {
+		t.Fatalf("got public keys %q, want one", keys)
+	}
+	for _, check := range []struct {
+		query string
+		want  [][]string
+	}{
+		{"", [][]string{docs, keys, sigs, hashes}},
+		{"?include_sigs=true&include_hashes=true", [][]string{docs, keys, sigs, hashes}},
+		{"?include_sigs=false", [][]string{docs, keys, hashes}},
+		{"?include_hashes=false", [][]string{docs, keys, sigs}},
+		{"?include_sigs=false&include_hashes=false", [][]string{docs, keys}},
+	} {
+		// The public key is kept as it signs nothing in the archive.
+		want := slices.Sorted(slices.Values(slices.Concat(check.want...)))
+		if got := archiveEntries(t, handler, check.query); !slices.Equal(got, want) {
+			t.Errorf("%q: got entries %q, want %q", check.query, got, want)
+		}
+	}
+	if code, _ := getPath(t, handler, "/api/archive/main?include_sigs=maybe"); code != http.StatusBadRequest {
+		t.Errorf("invalid parameter: got status %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+func TestArchiveLargeFile(t *testing.T) {
+	const size = 100 << 20
+	root := t.TempDir()
+	f, err := os.Create(filepath.Join(root, "large.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A sparse file does not fill the disk of the test.
+	if err := errors.Join(f.Truncate(size), f.Close()); err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range []struct {
+		name  string
+		write func(io.Writer, string, []string) error
+	}{
+		{"tar", writeTar},
+		{"zip", writeZip},
+	} {
+		t.Run(format.name, func(t *testing.T) {
+			var (
+				before, after runtime.MemStats
+				counter       countingWriter
+			)
+			runtime.ReadMemStats(&before)
+			if err := format.write(&counter, root, []string{"large.bin"}); err != nil {
+				t.Fatal(err)
+			}
+			runtime.ReadMemStats(&after)
+			if allocated := after.TotalAlloc - before.TotalAlloc; allocated > size/4 {
+				t.Errorf("archiving allocated %d MiB", allocated>>20)
+			}
+			if counter == 0 {
+				t.Error("empty archive")
+			}
+		})
+	}
+}
+
+// countingWriter counts the bytes written to it.
+type countingWriter int64
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	*cw += countingWriter(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,80 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package web
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+// budgetWriter starts the deadline of a response with its first
+// write so that building a profile does not count against it.
+type budgetWriter struct {
+	http.ResponseWriter
+	budget  time.Duration
+	started time.Time
+	err     error
+}
+
+// start sets the write deadline of the response when it begins.
+func (bw *budgetWriter) start() {
+	if !bw.started.IsZero() {
+		return
+	}
+	bw.started = time.Now()
+	rc := http.NewResponseController(bw.ResponseWriter)
+	if err := rc.SetWriteDeadline(bw.started.Add(bw.budget)); err != nil &&
+		!errors.Is(err, http.ErrNotSupported) {
+		slog.Warn("cannot set write deadline", "error", err)
+	}
+}
+
+// WriteHeader implements [http.ResponseWriter].
+func (bw *budgetWriter) WriteHeader(status int) {
+	bw.start()
+	bw.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements [http.ResponseWriter].
+func (bw *budgetWriter) Write(p []byte) (int, error) {
+	bw.start()
+	n, err := bw.ResponseWriter.Write(p)
+	if err != nil && bw.err == nil {
+		bw.err = err
+	}
+	return n, err
+}
+
+// Unwrap gives [http.ResponseController] access to the original writer.
+func (bw *budgetWriter) Unwrap() http.ResponseWriter {
+	return bw.ResponseWriter
+}
+
+// budget aborts responses which are not written within the
+// configured budget and logs the slow clients. The server
+// closes the connection once the write deadline is exceeded.
+func (c *Controller) budget(next http.Handler) http.Handler {
+	budget := c.cfg.Web.ResponseBudget
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		bw := &budgetWriter{ResponseWriter: rw, budget: budget}
+		next.ServeHTTP(bw, req)
+		if errors.Is(bw.err, os.ErrDeadlineExceeded) {
+			slog.Warn("aborted response to slow client",
+				"client", clientIP(req),
+				"path", req.URL.Path,
+				"budget", budget,
+				"duration", time.Since(bw.started))
+		}
+	})
+}
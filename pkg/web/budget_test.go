@@ -0,0 +1,80 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResponseBudget(t *testing.T) {
+	const budget = 200 * time.Millisecond
+	c := newTestController(t, t.TempDir())
+	c.cfg.Web.ResponseBudget = budget
+	chunk := bytes.Repeat([]byte{'x'}, 64<<10)
+	server := httptest.NewServer(c.budget(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// Building the profile does not count against the budget.
+		time.Sleep(2 * budget)
+		size := 1 << 10
+		if req.URL.Path == "/large" {
+			size = 256 << 20
+		}
+		rw.Header().Set("Content-Length", fmt.Sprint(size))
+		for written := 0; written < size; written += len(chunk) {
+			if _, err := rw.Write(chunk[:min(len(chunk), size-written)]); err != nil {
+				return
+			}
+		}
+	})))
+	log := captureLog(t)
+
+	// A fast client gets the whole response.
+	resp, err := http.Get(server.URL + "/small")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || len(body) != 1<<10 {
+		t.Fatalf("small response: got %d bytes: %v", len(body), err)
+	}
+
+	// A slow client reading a large response is cut off.
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "GET /large HTTP/1.1\r\nHost: test\r\n\r\n")
+	// It stalls after the response has begun until the budget is used up.
+	r := bufio.NewReader(conn)
+	if _, err := r.ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(3 * budget)
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	read, _ := io.Copy(io.Discard, r)
+	if read >= 256<<20 {
+		t.Error("slow client got the whole response")
+	}
+	server.Close()
+	if !strings.Contains(log.String(), "aborted response to slow client") {
+		t.Errorf("slow client is not logged:\n%s", log)
+	}
+}
@@ -0,0 +1,154 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package web
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// compressMinSize is the size in bytes below which
+// the responses are not worth to be compressed.
+const compressMinSize = 1024
+
+// acceptedEncoding returns the preferred content encoding
+// the client accepts. It is empty if there is none.
+func acceptedEncoding(req *http.Request) string {
+	var (
+		best  string
+		bestQ float64
+	)
+	for _, part := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "deflate" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		// gzip wins ties as it is understood more widely.
+		if q > bestQ || q == bestQ && coding == "gzip" {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// compressible checks if a content type is textual.
+func compressible(ctype string) bool {
+	mediaType, _, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") ||
+		mediaType == "application/json" ||
+		mediaType == "application/xml" ||
+		strings.HasSuffix(mediaType, "+json") ||
+		strings.HasSuffix(mediaType, "+xml")
+}
+
+// compressWriter compresses a response if it is worth it.
+// It decides with the headers of the response.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	decided  bool
+	w        io.WriteCloser
+}
+
+// decide checks if the response is compressed. Only complete
+// textual responses of some size not encoded already are.
+func (cw *compressWriter) decide(status int) {
+	if cw.decided {
+		return
+	}
+	cw.decided = true
+	h := cw.Header()
+	if status != http.StatusOK || h.Get("Content-Encoding") != "" || !compressible(h.Get("Content-Type")) {
+		return
+	}
+	if n, err := strconv.ParseInt(h.Get("Content-Length"), 10, 64); err == nil && n < compressMinSize {
+		return
+	}
+	// The ranges are only served of the files as they are.
+	h.Del("Content-Length")
+	h.Del("Accept-Ranges")
+	h.Set("Content-Encoding", cw.encoding)
+	if cw.encoding == "gzip" {
+		cw.w = gzip.NewWriter(cw.ResponseWriter)
+	} else {
+		cw.w, _ = flate.NewWriter(cw.ResponseWriter, flate.DefaultCompression)
+	}
+}
+
+// WriteHeader implements [http.ResponseWriter].
+func (cw *compressWriter) WriteHeader(status int) {
+	cw.decide(status)
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements [http.ResponseWriter].
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.w != nil {
+		return cw.w.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// close finishes the compressed stream.
+func (cw *compressWriter) close() error {
+	if cw.w != nil {
+		return cw.w.Close()
+	}
+	return nil
+}
+
+// Unwrap gives [http.ResponseController] access to the original writer.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// compress compresses the textual responses of next with gzip or
+// deflate if the client accepts it. Range and HEAD requests are
+// answered uncompressed so that their Content-Length and byte
+// ranges refer to the files as they are.
+func (c *Controller) compress(next http.Handler) http.Handler {
+	if !c.cfg.Web.Compress {
+		return next
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Add("Vary", "Accept-Encoding")
+		encoding := acceptedEncoding(req)
+		if encoding == "" || req.Method == http.MethodHead || req.Header.Get("Range") != "" {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: rw, encoding: encoding}
+		defer cw.close()
+		next.ServeHTTP(cw, req)
+	})
+}
@@ -0,0 +1,163 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package web
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestAcceptedEncoding(t *testing.T) {
+	for _, check := range []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"br", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"GZIP;q=0.8, br", "gzip"},
+		{"gzip;q=0, deflate;q=0", ""},
+		{"gzip;q=bad, deflate;q=0.1", "deflate"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", check.accept)
+		if got := acceptedEncoding(req); got != check.want {
+			t.Errorf("%q: got %q, want %q", check.accept, got, check.want)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	base := t.TempDir()
+	writeExport(t, base, "export")
+	feed := bytes.Repeat([]byte(`{"advisory":"example"},`), 200)
+	for file, content := range map[string][]byte{
+		"feed.json": feed,
+		"tiny.json": []byte(`{}`),
+		"data.bin":  feed,
+	} {
+		if err := os.WriteFile(filepath.Join(base, "export", file), content, 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := newTestController(t, base)
+	c.cfg.Web.Compress = true
+	handler := exportHandler(c, base)
+
+	serve := func(method, path string, header map[string]string) *http.Response {
+		req := httptest.NewRequest(method, path, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+	decode := func(t *testing.T, res *http.Response) []byte {
+		t.Helper()
+		var r io.Reader = res.Body
+		switch res.Header.Get("Content-Encoding") {
+		case "gzip":
+			zr, err := gzip.NewReader(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r = zr
+		case "deflate":
+			r = flate.NewReader(res.Body)
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	for _, encoding := range []string{"gzip", "deflate"} {
+		t.Run(encoding, func(t *testing.T) {
+			res := serve(http.MethodGet, "/export/feed.json", map[string]string{
+				"Accept-Encoding": encoding,
+			})
+			if got := res.Header.Get("Content-Encoding"); got != encoding {
+				t.Fatalf("got content encoding %q, want %q", got, encoding)
+			}
+			if got := res.Header.Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("got vary %q", got)
+			}
+			if res.Header.Get("Content-Length") != "" || res.Header.Get("Accept-Ranges") != "" {
+				t.Error("compressed response has length or ranges of the file")
+			}
+			if data := decode(t, res); !bytes.Equal(data, feed) {
+				t.Error("decompressed response differs from the file")
+			}
+		})
+	}
+
+	for _, check := range []struct {
+		name   string
+		method string
+		path   string
+		header map[string]string
+		want   []byte
+	}{
+		{"not accepted", http.MethodGet, "/export/feed.json", nil, feed},
+		{"tiny", http.MethodGet, "/export/tiny.json",
+			map[string]string{"Accept-Encoding": "gzip"}, []byte(`{}`)},
+		{"binary", http.MethodGet, "/export/data.bin",
+			map[string]string{"Accept-Encoding": "gzip"}, feed},
+		{"range", http.MethodGet, "/export/feed.json",
+			map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=0-9"}, feed[:10]},
+		{"head", http.MethodHead, "/export/feed.json",
+			map[string]string{"Accept-Encoding": "gzip"}, nil},
+	} {
+		t.Run(check.name, func(t *testing.T) {
+			res := serve(check.method, check.path, check.header)
+			if got := res.Header.Get("Content-Encoding"); got != "" {
+				t.Fatalf("got content encoding %q", got)
+			}
+			if data := decode(t, res); !bytes.Equal(data, check.want) {
+				t.Errorf("got body %q, want %q", data, check.want)
+			}
+		})
+	}
+
+	// The lengths of ranges and HEAD requests refer to the file.
+	res := serve(http.MethodHead, "/export/feed.json", map[string]string{"Accept-Encoding": "gzip"})
+	if got := res.Header.Get("Content-Length"); got != strconv.Itoa(len(feed)) {
+		t.Errorf("HEAD: got content length %q, want %d", got, len(feed))
+	}
+	res = serve(http.MethodGet, "/export/feed.json", map[string]string{
+		"Accept-Encoding": "gzip",
+		"Range":           "bytes=0-9",
+	})
+	if res.StatusCode != http.StatusPartialContent || res.Header.Get("Content-Length") != "10" {
+		t.Errorf("range: got status %d, content length %q",
+			res.StatusCode, res.Header.Get("Content-Length"))
+	}
+
+	// Without the option nothing is compressed.
+	c.cfg.Web.Compress = false
+	res = serve(http.MethodGet, "/export/feed.json", map[string]string{"Accept-Encoding": "gzip"})
+	if got := res.Header.Get("Content-Encoding"); got != "" {
+		t.Errorf("disabled: got content encoding %q", got)
+	}
+}
@@ -8,28 +8,45 @@
 // * 2025 Intevation GmbH <https://intevation.de>
 // * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
 
-// Package web contains the web controller logic.
+// Package web contains the web controller logic. It only serves the
+// exports, which are signed and hashed by package providers.
 package web
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"html/template"
 	"log/slog"
 	"maps"
+	"mime"
+	"net"
 	"net/http"
+	"net/http/pprof"
+	"path"
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/csaf-testsuite/contravider/pkg/config"
+	"github.com/csaf-testsuite/contravider/pkg/metrics"
 	"github.com/csaf-testsuite/contravider/pkg/providers"
 	"github.com/csaf-testsuite/contravider/pkg/version"
 )
 
 // Controller binds the endpoints to the internal logic.
 type Controller struct {
-	cfg *config.Config
-	sys *providers.System
+	cfg         *config.Config
+	sys         *providers.System
+	lockout     *lockout
+	sessions    *sessions
+	idempotency *idempotency
+	maintenance atomic.Bool
+	admin       atomic.Pointer[adminCredentials]
+	// reload reloads the configuration if not nil.
+	reload func(context.Context) error
 }
 
 // NewController returns a new Controller.
@@ -37,17 +54,49 @@ func NewController(
 	cfg *config.Config,
 	sys *providers.System,
 ) (*Controller, error) {
-	return &Controller{
-		cfg: cfg,
-		sys: sys,
-	}, nil
+	for _, file := range cfg.Web.PublicFiles {
+		if err := config.CheckPublicFile(cfg.Providers.Result, file); err != nil {
+			return nil, fmt.Errorf("invalid public file: %w", err)
+		}
+	}
+	// The file servers detect the content types by the extensions.
+	for ext, ctype := range cfg.Web.ContentTypes {
+		if err := mime.AddExtensionType(ext, ctype); err != nil {
+			return nil, fmt.Errorf("invalid content type of %q: %w", ext, err)
+		}
+	}
+	var lo *lockout
+	if cfg.Web.AuthLockout.Attempts > 0 {
+		lo = newLockout(&cfg.Web.AuthLockout)
+	}
+	var se *sessions
+	if cfg.Web.Sessions.Enabled() {
+		key, err := cfg.Web.Sessions.Key()
+		if err != nil {
+			return nil, err
+		}
+		se = &sessions{
+			key:    key,
+			maxAge: cfg.Web.Sessions.MaxAge,
+			secure: cfg.Web.TLSEnabled(),
+		}
+	}
+	c := &Controller{
+		cfg:         cfg,
+		sys:         sys,
+		lockout:     lo,
+		sessions:    se,
+		idempotency: newIdempotency(cfg.Web.IdempotencyWindow),
+	}
+	c.UpdateAdminCredentials(cfg.Web.AdminUser, cfg.Web.AdminPassword)
+	return c, nil
 }
 
 // indexTmplText is a HTML template listing the available profiles.
 const indexTmplText = `<!DOCTYPE html>
-<html lang="en">
+<html lang="{{ .Lang }}">
   <head>
-    <title>Contravider</title>
+    <title>{{ .Title }}</title>
   </head>
   <body>
     <h1>Contravider v{{ .Version }}</h1>
@@ -68,12 +117,16 @@ var indexTmpl = template.Must(template.New("index").Parse(indexTmplText))
 // renderProfilesList renders an overview over the profiles available
 // on this server.
 func (c *Controller) renderProfilesList(rw http.ResponseWriter) {
-	profiles := slices.Collect(maps.Keys(c.cfg.Providers.Profiles))
+	profiles := slices.Collect(maps.Keys(c.sys.Profiles()))
 	slices.Sort(profiles)
 	if err := indexTmpl.Execute(rw, struct {
+		Title    string
+		Lang     string
 		Version  string
 		Profiles []string
 	}{
+		Title:    c.cfg.Web.IndexTitle,
+		Lang:     c.cfg.Web.IndexLang,
 		Version:  version.SemVersion,
 		Profiles: profiles,
 	}); err != nil {
@@ -81,37 +134,83 @@ func (c *Controller) renderProfilesList(rw http.ResponseWriter) {
 	}
 }
 
+// requestProfile returns the name of the profile requested.
+func (c *Controller) requestProfile(req *http.Request) string {
+	if header := c.cfg.Web.ProfileHeader; header != "" {
+		if profile := req.Header.Get(header); profile != "" {
+			return profile
+		}
+	}
+	profile, _, _ := strings.Cut(strings.TrimLeft(req.URL.Path, "/"), "/")
+	return profile
+}
+
 // profiles serves profiles.
 func (c *Controller) profiles(rw http.ResponseWriter, req *http.Request) {
 	path := strings.TrimLeft(req.URL.Path, "/")
 	parts := strings.Split(path, "/")
+	// Behind a proxy which strips the profile from the path
+	// it is passed in a configured header.
+	if header := c.cfg.Web.ProfileHeader; header != "" {
+		if profile := req.Header.Get(header); profile != "" {
+			parts = append([]string{profile}, parts...)
+			req = withPath(req, "/"+profile+"/"+path)
+		}
+	}
 	if len(parts) == 0 || parts[0] == "" {
 		// List available profiles.
+		if req.URL.Query().Get("format") == "json" {
+			c.listProfiles(rw, req)
+			return
+		}
 		c.renderProfilesList(rw)
 		return
 	}
-	// Don't leak the directories file.
-	if parts[len(parts)-1] == ".directories.json" {
-		http.Error(rw, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
 	// Request the profile to get instantiated.
 	profile := parts[0]
-	switch err := c.sys.Serve(profile); {
+	switch err := c.sys.Serve(req.Context(), profile); {
 	case errors.Is(err, providers.ErrProfileNotFound):
 		http.NotFound(rw, req)
 		return
+	case errors.Is(err, providers.ErrInsufficientStorage):
+		http.Error(rw, err.Error(), http.StatusInsufficientStorage)
+		return
 	case err != nil:
+		if mce, ok := errors.AsType[*providers.MergeConflictError](err); ok {
+			slog.Error("profile does not merge",
+				"profile", profile,
+				"branch", mce.Branch,
+				"files", mce.Files)
+		}
 		http.Error(rw,
 			"internal server error: "+err.Error(),
 			http.StatusInternalServerError)
 		return
 	}
+	c.serveExport(rw, req, c.cfg.Web.Root, parts)
+}
+
+// internalFiles are files in the exports which are not served.
+var internalFiles = []string{".directories.json", ".buildinfo.json"}
+
+// serveExport serves the files of an export. The first part of
+// the path is the name of the export folder in the base directory.
+func (c *Controller) serveExport(
+	rw http.ResponseWriter,
+	req *http.Request,
+	base string,
+	parts []string,
+) {
+	// Don't leak the internal files.
+	if slices.Contains(internalFiles, parts[len(parts)-1]) {
+		http.Error(rw, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
 	// Check for directories.
-	dirFile := filepath.Join(c.cfg.Web.Root, profile, ".directories.json")
+	dirFile := filepath.Join(base, parts[0], ".directories.json")
 	dir, err := providers.LoadDirectory(dirFile)
 	if err != nil {
-		slog.Error("cannot load directory", "profile", profile, "error", err)
+		slog.Error("cannot load directory", "export", parts[0], "error", err)
 		http.Error(rw,
 			"internal server error: "+err.Error(),
 			http.StatusInternalServerError)
@@ -120,18 +219,193 @@ func (c *Controller) profiles(rw http.ResponseWriter, req *http.Request) {
 	// Check if an authentication is needed.
 	if protection := dir.FindProtection(parts[1:]); protection != nil {
 		user, password, ok := req.BasicAuth()
-		if !ok || !protection.Validate(user, password) {
+		switch {
+		case ok && protection.Validate(user, password):
+			if c.lockout != nil {
+				c.lockout.succeeded(clientIP(req))
+			}
+			if c.sessions != nil {
+				c.sessions.issue(rw, parts[0], protectedPath(req, dir, parts), user, password)
+			}
+		// Requests with credentials are checked by them alone.
+		case !ok && c.sessions != nil && c.sessions.valid(req, parts[0], protection):
+		default:
+			// Missing credentials are only a challenge, not a failure.
+			if ok {
+				slog.Warn("authentication failed",
+					"user", user,
+					"path", req.URL.Path,
+					"client", clientIP(req))
+				if c.lockout != nil {
+					c.lockout.failed(clientIP(req), time.Now())
+				}
+			}
 			rw.Header().Set("WWW-Authenticate", `Basic realm="restricted"`)
 			http.Error(rw, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 	}
-	http.FileServer(http.Dir(c.cfg.Web.Root)).ServeHTTP(rw, req)
+	if len(parts) > 1 {
+		if folder := dir.Find(parts[1 : len(parts)-1]); folder != nil {
+			name := parts[len(parts)-1]
+			// Serve the aliased file if an extensionless alias is requested.
+			if file, ok := folder.Alias(name); ok {
+				req = withPath(req, strings.TrimSuffix(req.URL.Path, name)+file)
+				name = file
+			}
+			// Inject the faults configured for this file.
+			if slices.Contains(folder.ShortBody, name) {
+				serveShortBody(rw, req, localPath(base, req))
+				return
+			}
+			if slices.Contains(folder.Chunked, name) {
+				serveChunked(rw, req, localPath(base, req))
+				return
+			}
+		}
+	}
+	c.compress(http.FileServer(http.Dir(base))).ServeHTTP(rw, req)
 }
 
-// Bind returns an http.Handler to be used in a web server.
+// previews serves the previews.
+func (c *Controller) previews(rw http.ResponseWriter, req *http.Request) {
+	rest := strings.TrimPrefix(req.URL.Path, "/preview/")
+	parts := strings.Split(rest, "/")
+	dir, err := c.sys.PreviewDir(parts[0])
+	switch {
+	case errors.Is(err, providers.ErrPreviewNotFound):
+		http.NotFound(rw, req)
+		return
+	case err != nil:
+		http.Error(rw,
+			"internal server error: "+err.Error(),
+			http.StatusInternalServerError)
+		return
+	}
+	c.serveExport(rw, withPath(req, "/"+rest), filepath.Dir(dir), parts)
+}
+
+// localPath returns the path of the requested file in the base directory.
+func localPath(base string, req *http.Request) string {
+	return filepath.Join(base, filepath.FromSlash(path.Clean("/"+req.URL.Path)))
+}
+
+// clientIP returns the IP address of the client of the request.
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// withPath returns a shallow copy of the request with a replaced URL path.
+func withPath(req *http.Request, path string) *http.Request {
+	r := new(http.Request)
+	*r = *req
+	u := *req.URL
+	u.Path, u.RawPath = path, ""
+	r.URL = &u
+	return r
+}
+
+// healthz reports that the server is up.
+func healthz(rw http.ResponseWriter, _ *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(rw, "ok")
+}
+
+// readyz reports if the system is ready to build profiles.
+func (c *Controller) readyz(rw http.ResponseWriter, _ *http.Request) {
+	code := http.StatusOK
+	status, err := c.sys.Status()
+	if err != nil {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(rw, code, status)
+}
+
+// Bind returns an http.Handler to be used in the public web server.
+// The admin endpoints are only served by the handler of BindAdmin.
 func (c *Controller) Bind() http.Handler {
 	router := http.NewServeMux()
-	router.HandleFunc("/", c.profiles)
+	var profiles http.Handler = http.HandlerFunc(c.profiles)
+	if c.lockout != nil {
+		profiles = c.lockout.middleware(profiles)
+	}
+	router.Handle("/", c.noIndex(profiles))
+	var previews http.Handler = http.HandlerFunc(c.previews)
+	if c.lockout != nil {
+		previews = c.lockout.middleware(previews)
+	}
+	router.Handle("GET /preview/", c.noIndex(previews))
+	router.HandleFunc("GET /healthz", healthz)
+	router.HandleFunc("GET /readyz", c.readyz)
+	c.bindAPI(router)
+	// A robots.txt given as public file takes precedence.
+	if !slices.Contains(c.cfg.Web.PublicFiles, "robots.txt") {
+		router.HandleFunc("GET /robots.txt", c.robots)
+	}
+	for _, file := range c.cfg.Web.PublicFiles {
+		name := filepath.Join(c.cfg.Providers.Result, filepath.FromSlash(file))
+		router.Handle("GET /"+file, c.compress(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			http.ServeFile(rw, req, name)
+		})))
+	}
+	handler := c.maintained(router)
+	if c.cfg.Web.ResponseBudget > 0 {
+		return c.budget(handler)
+	}
+	return handler
+}
+
+// bindAdmin registers the admin endpoints.
+func (c *Controller) bindAdmin(router *http.ServeMux) {
+	router.Handle("POST /admin/preview", c.adminAuth(c.createPreview))
+	rebuild, rebuildProfile := c.rebuild, c.rebuildProfile
+	if c.cfg.Web.IdempotencyWindow > 0 {
+		rebuild = c.idempotency.middleware(rebuild)
+		rebuildProfile = c.idempotency.middleware(rebuildProfile)
+	}
+	router.Handle("POST /admin/rebuild", c.adminAuth(rebuild))
+	if c.reload != nil {
+		reload := c.reloadConfig
+		if c.cfg.Web.IdempotencyWindow > 0 {
+			reload = c.idempotency.middleware(reload)
+		}
+		router.Handle("POST /admin/reload", c.adminAuth(reload))
+	}
+	// The modifying API endpoints are only served on the admin listeners.
+	router.Handle("POST /api/profiles/{name}/rebuild", c.adminAuth(rebuildProfile))
+	router.Handle("POST /admin/maintenance", c.adminAuth(c.setMaintenance))
+	// The diffs are expensive and reveal the files of the exports.
+	router.Handle("GET /api/diff", c.adminAuth(c.diff))
+	// The profiling endpoints are only served on the admin listeners.
+	if c.cfg.Debug.Pprof {
+		router.Handle("/debug/pprof/", c.adminAuth(pprof.Index))
+		router.Handle("/debug/pprof/cmdline", c.adminAuth(pprof.Cmdline))
+		router.Handle("/debug/pprof/profile", c.adminAuth(pprof.Profile))
+		router.Handle("/debug/pprof/symbol", c.adminAuth(pprof.Symbol))
+		router.Handle("/debug/pprof/trace", c.adminAuth(pprof.Trace))
+	}
+}
+
+// bindAPI registers the API endpoints.
+func (c *Controller) bindAPI(router *http.ServeMux) {
+	router.HandleFunc("GET /api/profiles", c.listProfiles)
+	router.HandleFunc("GET /api/signing-key", c.signingKey)
+	router.HandleFunc("GET /api/archive/{profile}", c.archive)
+}
+
+// BindAdmin returns an http.Handler serving only the admin
+// and API endpoints to be used on the admin listener.
+func (c *Controller) BindAdmin() http.Handler {
+	router := http.NewServeMux()
+	c.bindAdmin(router)
+	c.bindAPI(router)
+	// The metrics reveal the profiles and are only served on the admin listeners.
+	if c.cfg.Metrics.Enabled {
+		router.Handle("GET /metrics", metrics.Handler())
+	}
 	return router
 }
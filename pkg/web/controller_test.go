@@ -0,0 +1,409 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package web
+
+import (
+	"bytes"
+	"context"
+	"go/build"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/csaf-testsuite/contravider/pkg/config"
+	"github.com/csaf-testsuite/contravider/pkg/providers"
+)
+
+// testGit runs git in dir.
+func testGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{
+		"-c", "user.name=test",
+		"-c", "user.email=test@example.com",
+		"-c", "init.defaultBranch=main",
+	}, args...)...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, output)
+	}
+}
+
+// writeFile writes a file given by its slash separated path into base.
+func writeFile(t *testing.T, base, file, content string) {
+	t.Helper()
+	fname := filepath.Join(base, filepath.FromSlash(file))
+	if err := os.MkdirAll(filepath.Dir(fname), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fname, []byte(content), 0o666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testOrigin creates a git repository with a branch "main" holding
+// files by their slash separated paths below the data folder.
+func testOrigin(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	origin := t.TempDir()
+	for file, content := range files {
+		writeFile(t, origin, "data/"+file, content)
+	}
+	testGit(t, origin, "init", "-q")
+	testGit(t, origin, "add", "-A")
+	testGit(t, origin, "commit", "-q", "--allow-empty", "-m", "init")
+	return origin
+}
+
+// testFiles are the files of the branch of the test servers.
+var testFiles = map[string]string{
+	"white/advisory.json":                     `{"document":{}}`,
+	".well-known/csaf/provider-metadata.json": `{"role":"csaf_provider"}`,
+	"amber/.directives.toml":                  "[protection]\nuser = \"user\"\npassword = \"secret\"\n",
+	"amber/secret.txt":                        "secret",
+}
+
+// newTestServer returns a controller of a running system serving the
+// profile "main" built from testFiles. The config may be changed by
+// configure before the system is created.
+func newTestServer(t *testing.T, configure func(*config.Config)) *Controller {
+	t.Helper()
+	return newFilesServer(t, testFiles, configure)
+}
+
+// newFilesServer is like [newTestServer] but builds
+// the profile "main" from the given files.
+func newFilesServer(t *testing.T, files map[string]string, configure func(*config.Config)) *Controller {
+	t.Helper()
+	origin := testOrigin(t, files)
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	key, err := crypto.PGP().KeyGeneration().
+		AddUserId("contravider", "test@example.com").
+		New().
+		GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	armored, err := key.Armor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Signing.Key = filepath.Join(dir, "signing.key")
+	if err := os.WriteFile(cfg.Signing.Key, []byte(armored), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Providers.GitURL = "file://" + origin
+	cfg.Providers.WorkDir = filepath.Join(dir, "work")
+	cfg.Providers.Result = dir
+	cfg.Providers.Update = time.Hour
+	cfg.Providers.Profiles = config.Profiles{"main": {Branches: []string{"main"}}}
+	cfg.Web.Root = filepath.Join(dir, "web")
+	if configure != nil {
+		configure(cfg)
+	}
+	sys, err := providers.NewSystem(t.Context(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The context of the test is already done in the cleanup.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sys.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	c, err := NewController(cfg, sys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// newTestController returns a controller with the default config
+// serving the exports in base.
+func newTestController(t *testing.T, base string) *Controller {
+	t.Helper()
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Web.Root = base
+	return &Controller{cfg: cfg}
+}
+
+// exportHandler serves the exports in base like the profiles do.
+func exportHandler(c *Controller, base string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		parts := strings.Split(strings.TrimLeft(req.URL.Path, "/"), "/")
+		c.serveExport(rw, req, base, parts)
+	})
+}
+
+// request sends a request to a handler and returns the status and the body.
+func request(t *testing.T, handler http.Handler, req *http.Request) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	body, err := io.ReadAll(rec.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, string(body)
+}
+
+// getPath sends a GET request for a path to a handler.
+func getPath(t *testing.T, handler http.Handler, path string) (int, string) {
+	t.Helper()
+	return request(t, handler, httptest.NewRequest(http.MethodGet, path, nil))
+}
+
+func TestProfileHeader(t *testing.T) {
+	c := newTestServer(t, func(cfg *config.Config) {
+		cfg.Web.ProfileHeader = "X-Profile"
+	})
+	handler := c.Bind()
+	for _, check := range []struct {
+		name    string
+		path    string
+		profile string
+		want    int
+	}{
+		{"stripped path", "/white/advisory.json", "main", http.StatusOK},
+		{"full path", "/main/white/advisory.json", "", http.StatusOK},
+		{"unknown profile", "/white/advisory.json", "missing", http.StatusNotFound},
+		{"profile twice", "/main/white/advisory.json", "main", http.StatusNotFound},
+	} {
+		req := httptest.NewRequest(http.MethodGet, check.path, nil)
+		if check.profile != "" {
+			req.Header.Set("X-Profile", check.profile)
+		}
+		code, body := request(t, handler, req)
+		if code != check.want {
+			t.Errorf("%s: got status %d, want %d", check.name, code, check.want)
+			continue
+		}
+		if code == http.StatusOK && body != testFiles["white/advisory.json"] {
+			t.Errorf("%s: got body %q", check.name, body)
+		}
+	}
+}
+
+func TestPublicFiles(t *testing.T) {
+	c := newTestServer(t, func(cfg *config.Config) {
+		for _, file := range []string{"aggregator.json", ".well-known/security.txt", "other.json"} {
+			writeFile(t, cfg.Providers.Result, file, file)
+		}
+		cfg.Web.PublicFiles = []string{"aggregator.json", ".well-known/security.txt"}
+	})
+	handler := c.Bind()
+	for _, check := range []struct {
+		path string
+		want int
+	}{
+		{"/aggregator.json", http.StatusOK},
+		{"/.well-known/security.txt", http.StatusOK},
+		{"/other.json", http.StatusNotFound},
+	} {
+		code, body := getPath(t, handler, check.path)
+		if code != check.want {
+			t.Errorf("%s: got status %d, want %d", check.path, code, check.want)
+			continue
+		}
+		if code == http.StatusOK && body != strings.TrimPrefix(check.path, "/") {
+			t.Errorf("%s: got body %q", check.path, body)
+		}
+	}
+
+	// Missing public files are rejected at startup.
+	cfg := *c.cfg
+	cfg.Web.PublicFiles = []string{"missing.json"}
+	if _, err := NewController(&cfg, c.sys); err == nil {
+		t.Error("missing public file accepted")
+	}
+}
+
+// captureLog collects the log output of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(old) })
+	return &buf
+}
+
+func TestAuthFailureLog(t *testing.T) {
+	handler := newTestServer(t, nil).Bind()
+	log := captureLog(t)
+
+	get := func(user, password string) {
+		req := httptest.NewRequest(http.MethodGet, "/main/amber/secret.txt", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if user != "" {
+			req.SetBasicAuth(user, password)
+		}
+		request(t, handler, req)
+	}
+	// Challenges and successful logins are not logged as failures.
+	get("", "")
+	get("user", "secret")
+	if strings.Contains(log.String(), "authentication failed") {
+		t.Fatalf("unexpected failure logged: %s", log)
+	}
+	get("mallory", "guessed-password")
+	line := log.String()
+	for _, want := range []string{
+		"level=WARN", "authentication failed", "user=mallory",
+		"path=/main/amber/secret.txt", "client=192.0.2.1",
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("missing %q in log: %s", want, line)
+		}
+	}
+	if strings.Contains(line, "guessed-password") {
+		t.Errorf("password logged: %s", line)
+	}
+}
+
+func TestEmptyPasswordDenies(t *testing.T) {
+	base := t.TempDir()
+	writeExport(t, base, "export")
+	writeDirectory(t, base, "export", &providers.Directory{Folders: []*providers.Directory{{
+		Name: "amber",
+		Protection: &providers.Protection{
+			Users: []providers.Credentials{{User: "anonymous"}, {User: "user", Password: "secret"}},
+		},
+	}}})
+	writeFile(t, base, "open/red/secret.txt", "secret")
+	writeDirectory(t, base, "open", &providers.Directory{Folders: []*providers.Directory{{
+		Name:       "red",
+		Protection: &providers.Protection{Users: []providers.Credentials{{User: "user"}}},
+	}}})
+	handler := exportHandler(newTestController(t, base), base)
+	get := func(path, user, password string, auth bool) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if auth {
+			req.SetBasicAuth(user, password)
+		}
+		code, _ := request(t, handler, req)
+		return code
+	}
+	for _, check := range []struct {
+		path, user, password string
+		auth                 bool
+		want                 int
+	}{
+		{"/export/amber/secret.txt", "anonymous", "", true, http.StatusUnauthorized},
+		{"/export/amber/secret.txt", "", "", true, http.StatusUnauthorized},
+		{"/export/amber/secret.txt", "", "", false, http.StatusUnauthorized},
+		{"/export/amber/secret.txt", "user", "secret", true, http.StatusOK},
+		// A protection without any password denies all requests.
+		{"/open/red/secret.txt", "user", "", true, http.StatusUnauthorized},
+		{"/open/red/secret.txt", "", "", false, http.StatusUnauthorized},
+		{"/open/red/secret.txt", "user", "guessed", true, http.StatusUnauthorized},
+	} {
+		if got := get(check.path, check.user, check.password, check.auth); got != check.want {
+			t.Errorf("%s as %q:%q: got status %d, want %d",
+				check.path, check.user, check.password, got, check.want)
+		}
+	}
+}
+
+func TestIndexTitleLang(t *testing.T) {
+	c := newTestServer(t, func(cfg *config.Config) {
+		cfg.Web.IndexTitle = "Test <Portal>"
+		cfg.Web.IndexLang = "de"
+	})
+	code, body := getPath(t, c.Bind(), "/")
+	if code != http.StatusOK {
+		t.Fatalf("got status %d", code)
+	}
+	for _, want := range []string{
+		`<html lang="de">`,
+		"<title>Test &lt;Portal&gt;</title>",
+		`<a href="main">main</a>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+}
+
+func TestContentTypes(t *testing.T) {
+	// The content types are registered for the whole process.
+	t.Cleanup(func() {
+		cfg, err := config.Load("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for ext, ctype := range cfg.Web.ContentTypes {
+			mime.AddExtensionType(ext, ctype)
+		}
+	})
+	contentType := func(handler http.Handler, path string) string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: got status %d", path, rec.Code)
+		}
+		return rec.Header().Get("Content-Type")
+	}
+	handler := newTestServer(t, nil).Bind()
+	for ext, want := range map[string]string{
+		"":        "application/json",
+		".asc":    "application/pgp-signature",
+		".sha256": "text/plain; charset=utf-8",
+		".sha512": "text/plain; charset=utf-8",
+	} {
+		if got := contentType(handler, "/main/white/advisory.json"+ext); got != want {
+			t.Errorf("%q: got content type %q, want %q", ext, got, want)
+		}
+	}
+	// A scenario may serve the signatures with a wrong content type.
+	handler = newTestServer(t, func(cfg *config.Config) {
+		cfg.Web.ContentTypes[".asc"] = "application/octet-stream"
+	}).Bind()
+	if got := contentType(handler, "/main/white/advisory.json.asc"); got != "application/octet-stream" {
+		t.Errorf("got configured content type %q", got)
+	}
+}
+
+func TestNoSigningInWeb(t *testing.T) {
+	pkg, err := build.ImportDir(".", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, imp := range pkg.Imports {
+		if strings.HasPrefix(imp, "github.com/ProtonMail/") {
+			t.Errorf("package web imports %s, the signing belongs to package providers", imp)
+		}
+	}
+}
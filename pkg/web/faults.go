@@ -0,0 +1,104 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package web
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// contentType returns the content type of a file based on its extension.
+func contentType(name string) string {
+	if ctype := mime.TypeByExtension(filepath.Ext(name)); ctype != "" {
+		return ctype
+	}
+	return "application/octet-stream"
+}
+
+// serveShortBody is a fault injection to test the handling of truncated
+// downloads. It advertises a Content-Length twice as large as the file
+// and closes the connection after sending the file.
+func serveShortBody(rw http.ResponseWriter, req *http.Request, name string) {
+	data, err := os.ReadFile(name)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		http.NotFound(rw, req)
+		return
+	case err != nil:
+		http.Error(rw, "internal server error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Hijack the connection as the server would not allow the mismatch.
+	conn, buf, err := http.NewResponseController(rw).Hijack()
+	if err != nil {
+		http.Error(rw, "cannot hijack connection: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer conn.Close()
+	fmt.Fprintf(buf,
+		"HTTP/1.1 200 OK\r\nContent-Type: %s\r\nContent-Length: %d\r\nConnection: close\r\n\r\n",
+		contentType(name), max(2*len(data), 1))
+	if req.Method != http.MethodHead {
+		buf.Write(data)
+	}
+	if err := buf.Flush(); err != nil {
+		slog.Debug("writing short body failed", "file", name, "error", err)
+	}
+}
+
+// chunkSize is the size of the chunks written by serveChunked.
+const chunkSize = 64
+
+// flushWriter flushes the response after every write.
+type flushWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+// Write implements [io.Writer].
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, fw.rc.Flush()
+}
+
+// serveChunked is a fault injection to test the handling of chunked
+// transfer encoding. It omits the Content-Length and streams the
+// file in small chunks.
+func serveChunked(rw http.ResponseWriter, req *http.Request, name string) {
+	f, err := os.Open(name)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		http.NotFound(rw, req)
+		return
+	case err != nil:
+		http.Error(rw, "internal server error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	rw.Header().Set("Content-Type", contentType(name))
+	rw.WriteHeader(http.StatusOK)
+	if req.Method == http.MethodHead {
+		return
+	}
+	fw := &flushWriter{w: rw, rc: http.NewResponseController(rw)}
+	if _, err := io.CopyBuffer(fw, struct{ io.Reader }{f}, make([]byte, chunkSize)); err != nil {
+		slog.Debug("writing chunked body failed", "file", name, "error", err)
+	}
+}
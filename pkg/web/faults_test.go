@@ -0,0 +1,120 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package web
+
+import (
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// faultFiles returns testFiles with a provider metadata in the white
+// folder and the given directives for it.
+func faultFiles(directives string) map[string]string {
+	files := maps.Clone(testFiles)
+	files["white/provider-metadata.json"] = `{"role":"csaf_provider"}`
+	files["white/other.json"] = `{}`
+	if directives != "" {
+		files["white/.directives.toml"] = directives
+	}
+	return files
+}
+
+func TestAliases(t *testing.T) {
+	get := func(handler http.Handler, path string) (int, string) {
+		return request(t, handler, httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// Without the alias only the file itself is served.
+	plain := newFilesServer(t, faultFiles(""), nil).Bind()
+	if code, _ := get(plain, "/main/white/provider-metadata"); code != http.StatusNotFound {
+		t.Errorf("without alias: got status %d, want %d", code, http.StatusNotFound)
+	}
+	handler := newFilesServer(t, faultFiles(`aliases = ["provider-metadata.json"]`), nil).Bind()
+	_, want := get(handler, "/main/white/provider-metadata.json")
+	for _, check := range []struct {
+		path string
+		want int
+	}{
+		{"/main/white/provider-metadata", http.StatusOK},
+		{"/main/white/provider-metadata.json", http.StatusOK},
+		{"/main/white/other", http.StatusNotFound},
+	} {
+		code, body := get(handler, check.path)
+		if code != check.want {
+			t.Errorf("%s: got status %d, want %d", check.path, code, check.want)
+			continue
+		}
+		if code == http.StatusOK && body != want {
+			t.Errorf("%s: got body %q, want %q", check.path, body, want)
+		}
+	}
+}
+
+// faultServer serves a profile with the fault given by the
+// directives injected into the delivery of white/advisory.json.
+func faultServer(t *testing.T, directives string) (*httptest.Server, string) {
+	t.Helper()
+	content := strings.Repeat(`{"document":{}}`, 50)
+	files := faultFiles(directives)
+	files["white/advisory.json"] = content
+	server := httptest.NewServer(newFilesServer(t, files, nil).Bind())
+	t.Cleanup(server.Close)
+	return server, content
+}
+
+func TestShortBody(t *testing.T) {
+	server, content := faultServer(t, `short_body = ["advisory.json"]`)
+	res, err := http.Get(server.URL + "/main/white/advisory.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.ContentLength <= int64(len(content)) {
+		t.Errorf("advertised length %d does not exceed the %d bytes of the file",
+			res.ContentLength, len(content))
+	}
+	body, err := io.ReadAll(res.Body)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("got error %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	if string(body) != content {
+		t.Errorf("got %d bytes, want the %d of the file", len(body), len(content))
+	}
+}
+
+func TestChunked(t *testing.T) {
+	server, content := faultServer(t, `chunked = ["advisory.json"]`)
+	res, err := http.Get(server.URL + "/main/white/advisory.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if !slices.Equal(res.TransferEncoding, []string{"chunked"}) || res.ContentLength != -1 {
+		t.Errorf("got transfer encoding %q, content length %d",
+			res.TransferEncoding, res.ContentLength)
+	}
+	if got := res.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("got content type %q", got)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != content {
+		t.Errorf("got %d bytes, want the %d of the file", len(body), len(content))
+	}
+}
@@ -0,0 +1,181 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package web
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// idempotencyHeader is the header of the requests carrying
+// the key to recognize repeated requests.
+const idempotencyHeader = "Idempotency-Key"
+
+// maxRecordedBody is the size of the largest response body recorded.
+// Requests with larger responses are not replayed but handled again.
+const maxRecordedBody = 1 << 20
+
+// idempotency answers repeated requests with the same key
+// with the recorded response of the first one.
+type idempotency struct {
+	window time.Duration
+
+	mu        sync.Mutex
+	responses map[string]*recorded
+}
+
+// recorded is the response of a request. done is closed
+// once the response is complete.
+type recorded struct {
+	done    chan struct{}
+	created time.Time
+	status  int
+	header  http.Header
+	body    bytes.Buffer
+	// oversized is set if the body exceeds maxRecordedBody.
+	oversized bool
+	// discarded is set if the response is not replayed.
+	discarded bool
+}
+
+// recorder records a response while writing it.
+type recorder struct {
+	http.ResponseWriter
+	rec         *recorded
+	wroteHeader bool
+}
+
+func newIdempotency(window time.Duration) *idempotency {
+	return &idempotency{
+		window:    window,
+		responses: map[string]*recorded{},
+	}
+}
+
+// WriteHeader implements [http.ResponseWriter].
+func (r *recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.wroteHeader = true
+		r.rec.status = status
+		r.rec.header = r.Header().Clone()
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements [http.ResponseWriter].
+func (r *recorder) Write(p []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	if !r.rec.oversized {
+		if r.rec.body.Len()+len(p) > maxRecordedBody {
+			r.rec.oversized = true
+			r.rec.body = bytes.Buffer{}
+		} else {
+			r.rec.body.Write(p)
+		}
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+// Unwrap gives [http.ResponseController] access to the original writer.
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// lookup returns the recorded response for a key. If there is none
+// a new one is registered and true is returned to signal that the
+// request has to be handled.
+func (id *idempotency) lookup(key string, now time.Time) (*recorded, bool) {
+	id.mu.Lock()
+	defer id.mu.Unlock()
+	// Forget about the responses whose window is over.
+	for k, rec := range id.responses {
+		if now.Sub(rec.created) > id.window {
+			delete(id.responses, k)
+		}
+	}
+	if rec := id.responses[key]; rec != nil {
+		return rec, false
+	}
+	rec := &recorded{done: make(chan struct{}), created: now}
+	id.responses[key] = rec
+	return rec, true
+}
+
+// forget removes the recorded response for a key.
+func (id *idempotency) forget(key string, rec *recorded) {
+	id.mu.Lock()
+	defer id.mu.Unlock()
+	if id.responses[key] == rec {
+		delete(id.responses, key)
+	}
+}
+
+// middleware handles a request with an Idempotency-Key header only
+// once per window. Repeated requests wait for the first one and get
+// its response. Requests without the header are always handled.
+func (id *idempotency) middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		key := req.Header.Get(idempotencyHeader)
+		if key == "" {
+			next(rw, req)
+			return
+		}
+		// The keys are only valid for the same endpoint.
+		endpointKey := req.Method + " " + req.URL.Path + " " + key
+		rec, first := id.lookup(endpointKey, time.Now())
+		if first {
+			defer func() {
+				// Don't replay failures a retry may not run into, like
+				// the cancellation of a build by a client going away.
+				// The status is not set if the handler panicked.
+				if rec.oversized || rec.status == 0 ||
+					rec.status >= http.StatusInternalServerError ||
+					req.Context().Err() != nil {
+					rec.discarded = true
+					id.forget(endpointKey, rec)
+				}
+				close(rec.done)
+			}()
+			r := &recorder{ResponseWriter: rw, rec: rec}
+			next(r, req)
+			// The server answers with 200 if nothing was written.
+			if !r.wroteHeader {
+				rec.status = http.StatusOK
+				rec.header = rw.Header().Clone()
+			}
+			if rec.oversized {
+				slog.Warn("response too large to replay", "path", req.URL.Path, "key", key)
+			}
+			return
+		}
+		select {
+		case <-rec.done:
+		case <-req.Context().Done():
+			return
+		}
+		if rec.discarded {
+			next(rw, req)
+			return
+		}
+		slog.Debug("replaying response", "path", req.URL.Path, "key", key)
+		for k, v := range rec.header {
+			rw.Header()[k] = v
+		}
+		rw.Header().Set("Idempotent-Replayed", "true")
+		rw.WriteHeader(rec.status)
+		rw.Write(rec.body.Bytes())
+	}
+}
@@ -0,0 +1,218 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// post sends a POST request with an idempotency key to a handler.
+func post(handler http.Handler, path, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, nil)
+	if key != "" {
+		req.Header.Set(idempotencyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyOneBuild(t *testing.T) {
+	var (
+		builds  atomic.Int32
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	build := func(rw http.ResponseWriter, _ *http.Request) {
+		n := builds.Add(1)
+		if n == 1 {
+			close(started)
+			<-release
+		}
+		fmt.Fprintf(rw, "build %d", n)
+	}
+	handler := newIdempotency(time.Minute).middleware(build)
+
+	// The retry arrives while the first request is still building.
+	var (
+		wg        sync.WaitGroup
+		responses [2]*httptest.ResponseRecorder
+	)
+	wg.Go(func() { responses[0] = post(handler, "/admin/rebuild", "key") })
+	<-started
+	wg.Go(func() { responses[1] = post(handler, "/admin/rebuild", "key") })
+	close(release)
+	wg.Wait()
+	// A later retry within the window is replayed, too.
+	later := post(handler, "/admin/rebuild", "key")
+
+	if n := builds.Load(); n != 1 {
+		t.Fatalf("got %d builds, want 1", n)
+	}
+	for i, rec := range []*httptest.ResponseRecorder{responses[0], responses[1], later} {
+		if got := rec.Body.String(); got != "build 1" {
+			t.Errorf("response %d: got body %q, want %q", i, got, "build 1")
+		}
+	}
+	if responses[0].Header().Get("Idempotent-Replayed") != "" {
+		t.Error("first response is marked as replayed")
+	}
+	for _, rec := range []*httptest.ResponseRecorder{responses[1], later} {
+		if rec.Header().Get("Idempotent-Replayed") != "true" {
+			t.Error("repeated response is not marked as replayed")
+		}
+	}
+
+	// Other keys, other endpoints and requests without a key build again.
+	post(handler, "/admin/rebuild", "other")
+	post(handler, "/admin/reload", "key")
+	post(handler, "/admin/rebuild", "")
+	if n := builds.Load(); n != 4 {
+		t.Errorf("got %d builds, want 4", n)
+	}
+}
+
+func TestIdempotencyWindow(t *testing.T) {
+	var builds int
+	id := newIdempotency(time.Minute)
+	handler := id.middleware(func(http.ResponseWriter, *http.Request) { builds++ })
+	post(handler, "/admin/rebuild", "key")
+	// Move the first request out of the window.
+	for _, rec := range id.responses {
+		rec.created = rec.created.Add(-2 * time.Minute)
+	}
+	post(handler, "/admin/rebuild", "key")
+	if builds != 2 {
+		t.Errorf("got %d builds, want 2", builds)
+	}
+}
+
+func TestIdempotencyOversized(t *testing.T) {
+	var builds int
+	body := bytes.Repeat([]byte{'x'}, maxRecordedBody+1)
+	id := newIdempotency(time.Minute)
+	handler := id.middleware(func(rw http.ResponseWriter, _ *http.Request) {
+		builds++
+		rw.Write(body[:maxRecordedBody])
+		rw.Write(body[maxRecordedBody:])
+	})
+	first := post(handler, "/admin/rebuild", "key")
+	second := post(handler, "/admin/rebuild", "key")
+	// Too large responses are not recorded but handled again.
+	if builds != 2 {
+		t.Errorf("got %d builds, want 2", builds)
+	}
+	if len(id.responses) != 0 {
+		t.Errorf("%d oversized responses recorded", len(id.responses))
+	}
+	for i, rec := range []*httptest.ResponseRecorder{first, second} {
+		if rec.Body.Len() != len(body) {
+			t.Errorf("response %d: got %d bytes, want %d", i, rec.Body.Len(), len(body))
+		}
+		if rec.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("response %d is marked as replayed", i)
+		}
+	}
+}
+
+func TestReloadIdempotent(t *testing.T) {
+	var reloads int
+	c := &Controller{idempotency: newIdempotency(time.Minute)}
+	c.SetReload(func(context.Context) error {
+		reloads++
+		return errors.New("broken config")
+	})
+	handler := c.idempotency.middleware(c.reloadConfig)
+	for range 2 {
+		rec := post(handler, "/admin/reload", "deploy-42")
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("got status %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+		}
+	}
+	if reloads != 1 {
+		t.Errorf("got %d reloads, want 1", reloads)
+	}
+}
+
+func TestIdempotencyFailures(t *testing.T) {
+	var builds int
+	fail := true
+	handler := newIdempotency(time.Minute).middleware(func(rw http.ResponseWriter, req *http.Request) {
+		builds++
+		switch {
+		case req.Context().Err() != nil:
+			http.Error(rw, req.Context().Err().Error(), http.StatusInternalServerError)
+		case fail:
+			http.Error(rw, "build failed", http.StatusInternalServerError)
+		default:
+			fmt.Fprintf(rw, "build %d", builds)
+		}
+	})
+
+	// The client of the first request went away, e.g. by a timeout.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/admin/rebuild", nil)
+	req.Header.Set(idempotencyHeader, "key")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	// The retry fails on the server.
+	if rec := post(handler, "/admin/rebuild", "key"); rec.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	fail = false
+	rec := post(handler, "/admin/rebuild", "key")
+	if got := rec.Body.String(); got != "build 3" {
+		t.Errorf("got body %q, want %q", got, "build 3")
+	}
+	if rec.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("failed response is replayed")
+	}
+	// The successful response is replayed.
+	if got := post(handler, "/admin/rebuild", "key").Body.String(); got != "build 3" || builds != 3 {
+		t.Errorf("got body %q after %d builds, want %q after 3", got, builds, "build 3")
+	}
+}
+
+func TestIdempotencyUnwritten(t *testing.T) {
+	var builds int
+	panics := true
+	handler := newIdempotency(time.Minute).middleware(func(http.ResponseWriter, *http.Request) {
+		builds++
+		if panics {
+			panic(http.ErrAbortHandler)
+		}
+	})
+	func() {
+		// The server recovers from the panic.
+		defer func() { recover() }()
+		post(handler, "/admin/rebuild", "key")
+	}()
+	// The request of the panicking handler is handled again.
+	panics = false
+	for range 2 {
+		if rec := post(handler, "/admin/rebuild", "key"); rec.Code != http.StatusOK {
+			t.Errorf("got status %d, want %d", rec.Code, http.StatusOK)
+		}
+	}
+	// The response without a body is replayed.
+	if builds != 2 {
+		t.Errorf("got %d builds, want 2", builds)
+	}
+}
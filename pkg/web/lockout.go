@@ -0,0 +1,106 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package web
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+// lockout locks out clients after repeated failed authentications.
+type lockout struct {
+	cfg *config.AuthLockout
+
+	mu      sync.Mutex
+	clients map[string]*failures
+}
+
+// failures are the failed authentications of a client.
+type failures struct {
+	count  int
+	start  time.Time
+	locked time.Time
+}
+
+func newLockout(cfg *config.AuthLockout) *lockout {
+	return &lockout{
+		cfg:     cfg,
+		clients: map[string]*failures{},
+	}
+}
+
+// lockedUntil returns until when a client is locked.
+func (lo *lockout) lockedUntil(client string, now time.Time) (time.Time, bool) {
+	lo.mu.Lock()
+	defer lo.mu.Unlock()
+	f := lo.clients[client]
+	if f == nil || !now.Before(f.locked) {
+		return time.Time{}, false
+	}
+	return f.locked, true
+}
+
+// failed records a failed authentication of a client.
+func (lo *lockout) failed(client string, now time.Time) {
+	lo.mu.Lock()
+	defer lo.mu.Unlock()
+	// Forget about clients whose window or lock is over.
+	for c, f := range lo.clients {
+		if f.expired(now, lo.cfg.Window) {
+			delete(lo.clients, c)
+		}
+	}
+	f := lo.clients[client]
+	if f == nil {
+		f = &failures{start: now}
+		lo.clients[client] = f
+	}
+	f.count++
+	if f.count >= lo.cfg.Attempts {
+		f.locked = now.Add(lo.cfg.Cooldown)
+		slog.Warn("locking out client", "client", client, "until", f.locked)
+	}
+}
+
+// expired checks if the counting window or the lock is over.
+func (f *failures) expired(now time.Time, window time.Duration) bool {
+	if f.locked.IsZero() {
+		return now.Sub(f.start) > window
+	}
+	return !now.Before(f.locked)
+}
+
+// succeeded resets the failed authentications of a client.
+func (lo *lockout) succeeded(client string) {
+	lo.mu.Lock()
+	defer lo.mu.Unlock()
+	delete(lo.clients, client)
+}
+
+// middleware rejects the requests of locked out clients. The failed
+// authentications are counted when accessing protected folders only.
+func (lo *lockout) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		now := time.Now()
+		if until, locked := lo.lockedUntil(clientIP(req), now); locked {
+			retry := int(until.Sub(now).Seconds()) + 1
+			rw.Header().Set("Retry-After", strconv.Itoa(retry))
+			http.Error(rw, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
@@ -0,0 +1,96 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+func TestLockout(t *testing.T) {
+	base := t.TempDir()
+	writeExport(t, base, "export")
+	c := newTestController(t, base)
+	const attempts = 3
+	c.lockout = newLockout(&config.AuthLockout{
+		Attempts: attempts,
+		Window:   time.Minute,
+		Cooldown: time.Minute,
+	})
+	handler := c.lockout.middleware(exportHandler(c, base))
+
+	get := func(path, user, password string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if user != "" {
+			req.SetBasicAuth(user, password)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for i := range attempts - 1 {
+		if code := get("/export/amber/secret.txt", "user", "wrong"); code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: got status %d, want %d", i, code, http.StatusUnauthorized)
+		}
+		// Bogus credentials to unprotected files must not reset the failures.
+		if code := get("/export/public.txt", "user", "wrong"); code != http.StatusOK {
+			t.Fatalf("public file: got status %d, want %d", code, http.StatusOK)
+		}
+	}
+	if code := get("/export/amber/secret.txt", "user", "wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("last attempt: got status %d, want %d", code, http.StatusUnauthorized)
+	}
+	// The client is locked out now, even with the right credentials.
+	if code := get("/export/amber/secret.txt", "user", "secret"); code != http.StatusTooManyRequests {
+		t.Fatalf("locked out: got status %d, want %d", code, http.StatusTooManyRequests)
+	}
+	if code := get("/export/public.txt", "", ""); code != http.StatusTooManyRequests {
+		t.Fatalf("locked out public file: got status %d, want %d", code, http.StatusTooManyRequests)
+	}
+}
+
+func TestLockoutReset(t *testing.T) {
+	base := t.TempDir()
+	writeExport(t, base, "export")
+	c := newTestController(t, base)
+	const attempts = 2
+	c.lockout = newLockout(&config.AuthLockout{
+		Attempts: attempts,
+		Window:   time.Minute,
+		Cooldown: time.Minute,
+	})
+	handler := c.lockout.middleware(exportHandler(c, base))
+
+	get := func(password string) int {
+		req := httptest.NewRequest(http.MethodGet, "/export/amber/secret.txt", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.SetBasicAuth("user", password)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// A successful authentication resets the failures.
+	for range 3 {
+		if code := get("wrong"); code != http.StatusUnauthorized {
+			t.Fatalf("got status %d, want %d", code, http.StatusUnauthorized)
+		}
+		if code := get("secret"); code != http.StatusOK {
+			t.Fatalf("got status %d, want %d", code, http.StatusOK)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package web
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// maintenanceRetryAfter are the seconds the clients are
+// asked to wait while the server is in maintenance.
+const maintenanceRetryAfter = "60"
+
+// maintained answers the content requests with 503 Service Unavailable
+// while the server is in maintenance. The health checks are still served.
+func (c *Controller) maintained(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if c.maintenance.Load() {
+			switch req.URL.Path {
+			case "/healthz", "/readyz":
+			default:
+				rw.Header().Set("Retry-After", maintenanceRetryAfter)
+				http.Error(rw, "Service Unavailable: maintenance", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// setMaintenance switches the maintenance mode on or off
+// by the query parameter enabled.
+func (c *Controller) setMaintenance(rw http.ResponseWriter, req *http.Request) {
+	enabled, err := strconv.ParseBool(req.URL.Query().Get("enabled"))
+	if err != nil {
+		http.Error(rw, "bad request: parameter enabled is not a boolean", http.StatusBadRequest)
+		return
+	}
+	if c.maintenance.Swap(enabled) != enabled {
+		slog.Info("maintenance mode switched", "enabled", enabled)
+	}
+	writeJSON(rw, http.StatusOK, struct {
+		Maintenance bool `json:"maintenance"`
+	}{
+		Maintenance: enabled,
+	})
+}
@@ -0,0 +1,49 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package web
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+func TestGitMetrics(t *testing.T) {
+	c := newTestServer(t, func(cfg *config.Config) {
+		cfg.Metrics.Enabled = true
+	})
+	public, admin := c.Bind(), c.BindAdmin()
+	if code, body := getPath(t, public, "/main/white/advisory.json"); code != http.StatusOK {
+		t.Fatalf("got status %d: %s", code, body)
+	}
+	// The metrics are only served on the admin listener.
+	if code, _ := getPath(t, public, "/metrics"); code != http.StatusNotFound {
+		t.Errorf("public metrics: got status %d, want %d", code, http.StatusNotFound)
+	}
+	code, body := getPath(t, admin, "/metrics")
+	if code != http.StatusOK {
+		t.Fatalf("metrics: got status %d", code)
+	}
+	for _, operation := range []string{"clone", "rev_parse", "archive"} {
+		m := regexp.MustCompile(`(?m)^contravider_git_operation_duration_seconds_sum\{operation="` +
+			operation + `"\} (\S+)$`).FindStringSubmatch(body)
+		if m == nil {
+			t.Errorf("no duration of %s", operation)
+			continue
+		}
+		if seconds, err := strconv.ParseFloat(m[1], 64); err != nil || seconds <= 0 {
+			t.Errorf("%s: got duration %q", operation, m[1])
+		}
+	}
+}
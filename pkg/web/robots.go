@@ -0,0 +1,54 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package web
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// crawlable checks if a profile may be indexed by crawlers.
+func (c *Controller) crawlable(profile string) bool {
+	p := c.sys.Profiles()[profile]
+	return p != nil && p.Crawlable
+}
+
+// robots serves a robots.txt. If none is configured one is generated
+// which only allows the crawlable profiles.
+func (c *Controller) robots(rw http.ResponseWriter, _ *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if txt := c.cfg.Web.RobotsTxt; txt != "" {
+		rw.Write([]byte(txt))
+		return
+	}
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+	for _, profile := range slices.Sorted(maps.Keys(c.sys.Profiles())) {
+		if c.crawlable(profile) {
+			b.WriteString("Allow: /" + profile + "/\n")
+		}
+	}
+	b.WriteString("Disallow: /\n")
+	rw.Write([]byte(b.String()))
+}
+
+// noIndex tells crawlers not to index the responses
+// of profiles which are not crawlable.
+func (c *Controller) noIndex(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if !c.crawlable(c.requestProfile(req)) {
+			rw.Header().Set("X-Robots-Tag", "noindex")
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
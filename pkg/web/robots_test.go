@@ -0,0 +1,56 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/csaf-testsuite/contravider/pkg/config"
+)
+
+func TestRobots(t *testing.T) {
+	c := newTestServer(t, func(cfg *config.Config) {
+		cfg.Providers.Profiles["crawlable"] = &config.Profile{
+			Branches:  []string{"main"},
+			Crawlable: true,
+		}
+	})
+	handler := c.Bind()
+	const want = "User-agent: *\nAllow: /crawlable/\nDisallow: /\n"
+	if code, body := getPath(t, handler, "/robots.txt"); code != http.StatusOK || body != want {
+		t.Errorf("got robots.txt %d %q, want %q", code, body, want)
+	}
+
+	for _, check := range []struct {
+		profile string
+		tag     string
+	}{
+		{"main", "noindex"},
+		{"crawlable", ""},
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+check.profile+"/white/advisory.json", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: got status %d", check.profile, rec.Code)
+		}
+		if got := rec.Header().Get("X-Robots-Tag"); got != check.tag {
+			t.Errorf("%s: got X-Robots-Tag %q, want %q", check.profile, got, check.tag)
+		}
+	}
+
+	// A configured robots.txt is served as it is.
+	c.cfg.Web.RobotsTxt = "User-agent: *\nAllow: /\n"
+	if _, body := getPath(t, handler, "/robots.txt"); body != c.cfg.Web.RobotsTxt {
+		t.Errorf("got configured robots.txt %q", body)
+	}
+}
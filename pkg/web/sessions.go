@@ -0,0 +1,117 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/csaf-testsuite/contravider/pkg/providers"
+)
+
+// sessionCookie is the name of the cookie holding the session.
+const sessionCookie = "contravider_session"
+
+// sessions issue signed cookies after successful authentications
+// to protected folders so that the clients don't have to send
+// their credentials with every request.
+type sessions struct {
+	key    []byte
+	maxAge time.Duration
+	secure bool
+}
+
+// sign returns the signature of a session of a user in a scope.
+// The password is part of it so that the session ends if the
+// credentials of the folder are changed.
+func (s *sessions) sign(scope, user, password string, expires int64) string {
+	mac := hmac.New(sha256.New, s.key)
+	for _, part := range []string{scope, user, password, strconv.FormatInt(expires, 10)} {
+		mac.Write([]byte(part))
+		mac.Write([]byte{0})
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// issue sets the session cookie for an authenticated user.
+// The cookie is only sent back to the requests below path.
+func (s *sessions) issue(rw http.ResponseWriter, scope, path, user, password string) {
+	expires := time.Now().Add(s.maxAge)
+	value := base64.RawURLEncoding.EncodeToString([]byte(user)) +
+		"." + strconv.FormatInt(expires.Unix(), 10) +
+		"." + s.sign(scope, user, password, expires.Unix())
+	http.SetCookie(rw, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    value,
+		Path:     path,
+		Expires:  expires,
+		MaxAge:   int(s.maxAge.Seconds()),
+		Secure:   s.secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// valid checks if the request has an unexpired session of one
+// of the users of the protection in the scope.
+func (s *sessions) valid(req *http.Request, scope string, protection *providers.Protection) bool {
+	cookie, err := req.Cookie(sessionCookie)
+	if err != nil {
+		return false
+	}
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	user, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() >= expires {
+		return false
+	}
+	for _, c := range protection.Users {
+		if c.Password != "" && c.User == string(user) &&
+			hmac.Equal([]byte(parts[2]), []byte(s.sign(scope, c.User, c.Password, expires))) {
+			return true
+		}
+	}
+	return false
+}
+
+// protectedPath returns the path of the protected folder in the URL
+// requested by the client. parts are the parts of the path with the
+// export first. Previews and profile headers rewrite the path, so the
+// folder is looked up in the requested one.
+func protectedPath(req *http.Request, dir *providers.Directory, parts []string) string {
+	requested := req.URL.Path
+	if u, err := url.ParseRequestURI(req.RequestURI); err == nil {
+		requested = u.Path
+	}
+	prefix, ok := strings.CutSuffix(requested, strings.Join(parts[1:], "/"))
+	if !ok {
+		return "/"
+	}
+	for depth := 1; depth < len(parts); depth++ {
+		if dir.FindProtection(parts[1:depth+1]) != nil {
+			return prefix + strings.Join(parts[1:depth+1], "/") + "/"
+		}
+	}
+	return "/"
+}
@@ -0,0 +1,218 @@
+// This file is Free Software under the Apache-2.0 License
+// without warranty, see README.md and LICENSE for details.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// SPDX-FileCopyrightText: 2025 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering:
+// * 2025 Intevation GmbH <https://intevation.de>
+// * 2025 Fraunhofer Institute for Applied an Integrated Security (AISEC) <https://aisec.fraunhofer.de>
+
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/csaf-testsuite/contravider/pkg/providers"
+)
+
+// newTestSessions returns sessions with a fixed key.
+func newTestSessions(maxAge time.Duration) *sessions {
+	return &sessions{key: make([]byte, 32), maxAge: maxAge}
+}
+
+// getWith requests a path with optional basic auth credentials and cookies.
+func getWith(
+	handler http.Handler,
+	path, user, password string,
+	cookies ...*http.Cookie,
+) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if user != "" {
+		req.SetBasicAuth(user, password)
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// sessionOf returns the session cookie set in a response.
+func sessionOf(t *testing.T, rec *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == sessionCookie {
+			return cookie
+		}
+	}
+	t.Fatal("no session cookie issued")
+	return nil
+}
+
+func TestSessions(t *testing.T) {
+	base := t.TempDir()
+	writeExport(t, base, "export")
+	writeExport(t, base, "other")
+	c := newTestController(t, base)
+	c.sessions = newTestSessions(time.Hour)
+	handler := exportHandler(c, base)
+
+	// Without credentials or a session the client is challenged.
+	if rec := getWith(handler, "/export/amber/secret.txt", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous: got status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	rec := getWith(handler, "/export/amber/secret.txt", "user", "secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: got status %d, want %d", rec.Code, http.StatusOK)
+	}
+	session := sessionOf(t, rec)
+	if !session.HttpOnly || session.MaxAge != int(time.Hour.Seconds()) {
+		t.Errorf("got cookie http only %t, max age %d", session.HttpOnly, session.MaxAge)
+	}
+	// The cookie is only sent to the protected folder.
+	if session.Path != "/export/amber/" {
+		t.Errorf("got cookie path %q, want %q", session.Path, "/export/amber/")
+	}
+
+	for _, check := range []struct {
+		name           string
+		path           string
+		user, password string
+		want           int
+	}{
+		{"session", "/export/amber/secret.txt", "", "", http.StatusOK},
+		// Credentials sent along are checked by themselves.
+		{"wrong credentials", "/export/amber/secret.txt", "user", "wrong", http.StatusUnauthorized},
+		// The session is only valid for the export it was issued for.
+		{"other export", "/other/amber/secret.txt", "", "", http.StatusUnauthorized},
+	} {
+		if rec := getWith(handler, check.path, check.user, check.password, session); rec.Code != check.want {
+			t.Errorf("%s: got status %d, want %d", check.name, rec.Code, check.want)
+		}
+	}
+
+	// Other sessions end with the server as the key changes.
+	restarted := newTestController(t, base)
+	restarted.sessions = &sessions{key: []byte("another key"), maxAge: time.Hour}
+	rec = getWith(exportHandler(restarted, base), "/export/amber/secret.txt", "", "", session)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("other key: got status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	// Changing the credentials of the folder ends the sessions.
+	writeProtection(t, base, "export", "user", "changed")
+	if rec := getWith(handler, "/export/amber/secret.txt", "", "", session); rec.Code != http.StatusUnauthorized {
+		t.Errorf("changed password: got status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestSessionsExpire(t *testing.T) {
+	base := t.TempDir()
+	writeExport(t, base, "export")
+	c := newTestController(t, base)
+	c.sessions = newTestSessions(time.Hour)
+	handler := exportHandler(c, base)
+
+	// cookie returns a session expiring at the given time.
+	cookie := func(expires time.Time) *http.Cookie {
+		return &http.Cookie{
+			Name: sessionCookie,
+			Value: base64.RawURLEncoding.EncodeToString([]byte("user")) +
+				"." + strconv.FormatInt(expires.Unix(), 10) +
+				"." + c.sessions.sign("export", "user", "secret", expires.Unix()),
+		}
+	}
+	if rec := getWith(handler, "/export/amber/secret.txt", "", "", cookie(time.Now().Add(time.Minute))); rec.Code != http.StatusOK {
+		t.Errorf("running session: got status %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec := getWith(handler, "/export/amber/secret.txt", "", "", cookie(time.Now().Add(-time.Second))); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expired session: got status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestSessionsTampered(t *testing.T) {
+	base := t.TempDir()
+	writeExport(t, base, "export")
+	// A second user whose session is forged from the one of the first.
+	writeDirectory(t, base, "export", &providers.Directory{Folders: []*providers.Directory{{
+		Name: "amber",
+		Protection: &providers.Protection{Users: []providers.Credentials{
+			{User: "user", Password: "secret"},
+			{User: "admin", Password: "other"},
+		}},
+	}}})
+	c := newTestController(t, base)
+	c.sessions = newTestSessions(time.Hour)
+	handler := exportHandler(c, base)
+	session := sessionOf(t, getWith(handler, "/export/amber/secret.txt", "user", "secret"))
+	parts := strings.Split(session.Value, ".")
+	if len(parts) != 3 {
+		t.Fatalf("got cookie value %q", session.Value)
+	}
+	// The signature is a hex encoded HMAC-SHA256.
+	if len(parts[2]) != 2*sha256.Size {
+		t.Errorf("got signature %q", parts[2])
+	}
+	flipped := []byte(parts[2])
+	if flipped[0] == '0' {
+		flipped[0] = '1'
+	} else {
+		flipped[0] = '0'
+	}
+	later := strconv.FormatInt(time.Now().Add(48*time.Hour).Unix(), 10)
+	admin := base64.RawURLEncoding.EncodeToString([]byte("admin"))
+	for _, check := range []struct {
+		name  string
+		value string
+		want  int
+	}{
+		{"valid", session.Value, http.StatusOK},
+		{"signature", parts[0] + "." + parts[1] + "." + string(flipped), http.StatusUnauthorized},
+		{"truncated signature", parts[0] + "." + parts[1] + "." + parts[2][:len(parts[2])-2], http.StatusUnauthorized},
+		{"expiry", parts[0] + "." + later + "." + parts[2], http.StatusUnauthorized},
+		{"user", admin + "." + parts[1] + "." + parts[2], http.StatusUnauthorized},
+		{"malformed", parts[0] + "." + parts[2], http.StatusUnauthorized},
+	} {
+		cookie := &http.Cookie{Name: sessionCookie, Value: check.value}
+		if rec := getWith(handler, "/export/amber/secret.txt", "", "", cookie); rec.Code != check.want {
+			t.Errorf("%s: got status %d, want %d", check.name, rec.Code, check.want)
+		}
+	}
+}
+
+func TestProtectedPath(t *testing.T) {
+	dir := &providers.Directory{Folders: []*providers.Directory{{
+		Name: "white",
+		Folders: []*providers.Directory{{
+			Name:       "amber",
+			Protection: &providers.Protection{User: "user", Password: "secret"},
+		}},
+	}}}
+	for _, check := range []struct {
+		name      string
+		requested string
+		parts     []string
+		want      string
+	}{
+		{"profile", "/main/white/amber/a.json", []string{"main", "white", "amber", "a.json"}, "/main/white/amber/"},
+		{"subfolder", "/main/white/amber/2025/a.json", []string{"main", "white", "amber", "2025", "a.json"}, "/main/white/amber/"},
+		{"preview", "/preview/TOKEN/white/amber/a.json", []string{"TOKEN", "white", "amber", "a.json"}, "/preview/TOKEN/white/amber/"},
+		{"profile header", "/white/amber/a.json", []string{"main", "white", "amber", "a.json"}, "/white/amber/"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, check.requested, nil)
+		// The handlers see the rewritten path of the export.
+		req.URL.Path = "/" + strings.Join(check.parts, "/")
+		if got := protectedPath(req, dir, check.parts); got != check.want {
+			t.Errorf("%s: got path %q, want %q", check.name, got, check.want)
+		}
+	}
+}